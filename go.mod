@@ -9,6 +9,7 @@ require (
 	github.com/go-git/go-git/v5 v5.1.0
 	github.com/google/go-github/v29 v29.0.3
 	github.com/googleapis/gnostic v0.2.0 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/oklog/run v1.1.0
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/alertmanager v0.21.0
@@ -14,16 +14,22 @@
 package main // import "github.com/prometheus/test-infra/infra"
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/test-infra/pkg/provider"
-	"github.com/prometheus/test-infra/pkg/provider/eks"
-	"github.com/prometheus/test-infra/pkg/provider/gke"
-	kind "github.com/prometheus/test-infra/pkg/provider/kind"
+	_ "github.com/prometheus/test-infra/pkg/provider/aks"
+	_ "github.com/prometheus/test-infra/pkg/provider/eks"
+	_ "github.com/prometheus/test-infra/pkg/provider/existing"
+	_ "github.com/prometheus/test-infra/pkg/provider/gke"
+	"github.com/prometheus/test-infra/pkg/provider/k8s"
+	_ "github.com/prometheus/test-infra/pkg/provider/kind"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -31,6 +37,7 @@ func main() {
 	log.SetFlags(log.Ltime | log.Lshortfile)
 
 	dr := provider.NewDeploymentResource()
+	dr.SetContext(cancelOnSignal())
 
 	app := kingpin.New(filepath.Base(os.Args[0]), "The prometheus/test-infra deployment tool")
 	app.HelpFlag.Short('h')
@@ -40,122 +47,101 @@ func main() {
 	app.Flag("vars", "When provided it will substitute the token holders in the yaml file. Follows the standard golang template formating - {{ .hashStable }}.").
 		Short('v').
 		StringMapVar(&dr.FlagDeploymentVars)
-
-	g := gke.New(dr)
-	k8sGKE := app.Command("gke", `Google container engine provider - https://cloud.google.com/kubernetes-engine/`).
-		Action(g.SetupDeploymentResources)
-	k8sGKE.Flag("auth", "json authentication for the project. Accepts a filepath or an env variable that inlcudes tha json data. If not set the tool will use the GOOGLE_APPLICATION_CREDENTIALS env variable (export GOOGLE_APPLICATION_CREDENTIALS=service-account.json). https://cloud.google.com/iam/docs/creating-managing-service-account-keys.").
-		PlaceHolder("service-account.json").
-		Short('a').
-		StringVar(&g.Auth)
-
-	k8sGKE.Command("info", "gke info -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(g.GetDeploymentVars)
-
-	// Cluster operations.
-	k8sGKECluster := k8sGKE.Command("cluster", "manage GKE clusters").
-		Action(g.NewGKEClient).
-		Action(g.GKEDeploymentsParse)
-	k8sGKECluster.Command("create", "gke cluster create -a service-account.json -f FileOrFolder").
-		Action(g.ClusterCreate)
-	k8sGKECluster.Command("delete", "gke cluster delete -a service-account.json -f FileOrFolder").
-		Action(g.ClusterDelete)
-
-	// Cluster node-pool operations
-	k8sGKENodePool := k8sGKE.Command("nodes", "manage GKE clusters nodepools").
-		Action(g.NewGKEClient).
-		Action(g.GKEDeploymentsParse)
-	k8sGKENodePool.Command("create", "gke nodes create -a service-account.json -f FileOrFolder").
-		Action(g.NodePoolCreate)
-	k8sGKENodePool.Command("delete", "gke nodes delete -a service-account.json -f FileOrFolder").
-		Action(g.NodePoolDelete)
-	k8sGKENodePool.Command("check-running", "gke nodes check-running -a service-account.json -f FileOrFolder").
-		Action(g.AllNodepoolsRunning)
-	k8sGKENodePool.Command("check-deleted", "gke nodes check-deleted -a service-account.json -f FileOrFolder").
-		Action(g.AllNodepoolsDeleted)
-
-	// K8s resource operations.
-	k8sGKEResource := k8sGKE.Command("resource", `Apply and delete different k8s resources - deployments, services, config maps etc.Required variables -v GKE_PROJECT_ID, -v ZONE: -west1-b -v CLUSTER_NAME`).
-		Action(g.NewGKEClient).
-		Action(g.K8SDeploymentsParse).
-		Action(g.NewK8sProvider)
-	k8sGKEResource.Command("apply", "gke resource apply -a service-account.json -f manifestsFileOrFolder -v GKE_PROJECT_ID:test -v ZONE:europe-west1-b -v CLUSTER_NAME:test -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(g.ResourceApply)
-	k8sGKEResource.Command("delete", "gke resource delete -a service-account.json -f manifestsFileOrFolder -v GKE_PROJECT_ID:test -v ZONE:europe-west1-b -v CLUSTER_NAME:test -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(g.ResourceDelete)
-
-	k := kind.New(dr)
-	k8sKIND := app.Command("kind", `Kubernetes In Docker (KIND) provider - https://kind.sigs.k8s.io/docs/user/quick-start/`).
-		Action(k.SetupDeploymentResources)
-
-	k8sKIND.Command("info", "kind info -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(k.GetDeploymentVars)
-
-	//Cluster operations.
-	k8sKINDCluster := k8sKIND.Command("cluster", "manage KIND clusters").
-		Action(k.KINDDeploymentsParse)
-	k8sKINDCluster.Command("create", "kind cluster create -f File -v PR_NUMBER:$PR_NUMBER -v CLUSTER_NAME:$CLUSTER_NAME").
-		Action(k.ClusterCreate)
-	k8sKINDCluster.Command("delete", "kind cluster delete -f File -v PR_NUMBER:$PR_NUMBER -v CLUSTER_NAME:$CLUSTER_NAME").
-		Action(k.ClusterDelete)
-
-	// K8s resource operations.
-	k8sKINDResource := k8sKIND.Command("resource", `Apply and delete different k8s resources - deployments, services, config maps etc.`).
-		Action(k.NewK8sProvider).
-		Action(k.K8SDeploymentsParse)
-	k8sKINDResource.Command("apply", "kind resource apply -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(k.ResourceApply)
-	k8sKINDResource.Command("delete", "kind resource delete -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(k.ResourceDelete)
-
-	// EKS based commands
-	e := eks.New(dr)
-	k8sEKS := app.Command("eks", "Amazon Elastic Kubernetes Service - https://aws.amazon.com/eks").
-		Action(e.SetupDeploymentResources)
-	k8sEKS.Flag("auth", "filename which consist eks credentials.").
-		PlaceHolder("credentials").
-		Short('a').
-		StringVar(&e.Auth)
-
-	k8sEKS.Command("info", "eks info -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(e.GetDeploymentVars)
-
-	// EKS Cluster operations
-	k8sEKSCluster := k8sEKS.Command("cluster", "manage EKS clusters").
-		Action(e.NewEKSClient).
-		Action(e.EKSDeploymentParse)
-	k8sEKSCluster.Command("create", "eks cluster create -a credentials -f FileOrFolder").
-		Action(e.ClusterCreate)
-	k8sEKSCluster.Command("delete", "eks cluster delete -a credentials -f FileOrFolder").
-		Action(e.ClusterDelete)
-
-	// Cluster node-pool operations
-	k8sEKSNodeGroup := k8sEKS.Command("nodes", "manage EKS clusters nodegroups").
-		Action(e.NewEKSClient).
-		Action(e.EKSDeploymentParse)
-	k8sEKSNodeGroup.Command("create", "eks nodes create -a authFile -f FileOrFolder -v ZONE:eu-west-1 -v CLUSTER_NAME:test -v EKS_SUBNET_IDS: subnetId1,subnetId2,subnetId3").
-		Action(e.NodeGroupCreate)
-	k8sEKSNodeGroup.Command("delete", "eks nodes delete -a authFile -f FileOrFolder -v ZONE:eu-west-1 -v CLUSTER_NAME:test -v EKS_SUBNET_IDS: subnetId1,subnetId2,subnetId3").
-		Action(e.NodeGroupDelete)
-	k8sEKSNodeGroup.Command("check-running", "eks nodes check-running -a credentails -f FileOrFolder -v ZONE:eu-west-1 -v CLUSTER_NAME:test -v EKS_SUBNET_IDS: subnetId1,subnetId2,subnetId3").
-		Action(e.AllNodeGroupsRunning)
-	k8sEKSNodeGroup.Command("check-deleted", "eks nodes check-deleted -a authFile -f FileOrFolder -v ZONE:eu-west-1 -v CLUSTER_NAME:test -v EKS_SUBNET_IDS: subnetId1,subnetId2,subnetId3").
-		Action(e.AllNodeGroupsDeleted)
-
-	// K8s resource operations.
-	k8sEKSResource := k8sEKS.Command("resource", `Apply and delete different k8s resources - deployments, services, config maps etc.Required variables -v ZONE:us-east-2 -v CLUSTER_NAME:test `).
-		Action(e.NewEKSClient).
-		Action(e.K8SDeploymentsParse).
-		Action(e.NewK8sProvider)
-	k8sEKSResource.Command("apply", "eks resource apply -a credentials -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(e.ResourceApply)
-	k8sEKSResource.Command("delete", "eks resource delete -a credentials -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2").
-		Action(e.ResourceDelete)
-
-	if _, err := app.Parse(os.Args[1:]); err != nil {
+	app.Flag("vars-file", "yaml or dotenv(KEY=VALUE) file with variables to substitute the token holders in the yaml file. Can be repeated, later files override earlier ones and --vars overrides all files.").
+		ExistingFilesVar(&dr.VarsFiles)
+	app.Flag("state", "json file that records the clusters, node pools and namespaces created by infra, with the variables used, so they can be deleted with infra destroy.").
+		PlaceHolder("FILE").
+		StringVar(&dr.StateFile)
+	app.Action(func(*kingpin.ParseContext) error {
+		return dr.LoadVarsFiles()
+	})
+
+	var renderDir string
+	k8sRender := app.Command("render", "render -f FileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2. Prints the files after applying the template variables without touching a cluster.").
+		Action(func(*kingpin.ParseContext) error {
+			if len(dr.DeploymentFiles) == 0 {
+				return fmt.Errorf("missing deployment file(s)")
+			}
+			deploymentVars, _ := provider.MergeVarsSources(dr.VarsSources(nil))
+			resources, err := provider.DeploymentsParse(dr.DeploymentFiles, deploymentVars)
+			if err != nil {
+				return fmt.Errorf("Couldn't parse deployment files: %v", err)
+			}
+			return provider.RenderResources(os.Stdout, renderDir, resources)
+		})
+	k8sRender.Flag("output-dir", "Write every rendered file to this folder, keeping the path of the source file, instead of printing them.").
+		Short('o').
+		StringVar(&renderDir)
+
+	var nodeFiles []string
+	k8sValidate := app.Command("validate", "validate -f manifestsFileOrFolder --nodes nodesFile -v hashStable:COMMIT1 -v hashTesting:COMMIT2. Checks the k8s manifests without touching a cluster.").
+		Action(func(*kingpin.ParseContext) error {
+			if len(dr.DeploymentFiles) == 0 {
+				return fmt.Errorf("missing deployment file(s)")
+			}
+			deploymentVars, _ := provider.MergeVarsSources(dr.VarsSources(nil))
+			resources, err := provider.DeploymentsParse(dr.DeploymentFiles, deploymentVars)
+			if err != nil {
+				return fmt.Errorf("Couldn't parse deployment files: %v", err)
+			}
+
+			var nodeNames map[string]bool
+			if len(nodeFiles) > 0 {
+				nodeResources, err := provider.DeploymentsParse(nodeFiles, deploymentVars)
+				if err != nil {
+					return fmt.Errorf("Couldn't parse node pool files: %v", err)
+				}
+				if nodeNames, err = k8s.NodeNames(nodeResources); err != nil {
+					return err
+				}
+			}
+
+			errs := k8s.DecodeAndValidate(resources, nodeNames)
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("found %d problem(s) in the deployment files", len(errs))
+			}
+			return nil
+		})
+	k8sValidate.Flag("nodes", "Cluster or node pool file or folder that defines the node-name labels used by the node selectors. Can be repeated. When not set the node selectors are not checked.").
+		ExistingFilesOrDirsVar(&nodeFiles)
+
+	provider.RegisterCommands(app, dr)
+
+	if err := run(app, dr); err != nil {
+		if err == provider.ErrPendingChanges {
+			os.Exit(3)
+		}
 		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error parsing commandline arguments"))
 		app.Usage(os.Args[1:])
 		os.Exit(2)
 	}
 
 }
+
+// run parses the command line and runs the selected command.
+// The context of the command is cancelled when it returns, which also stops the timer of --timeout.
+func run(app *kingpin.Application, dr *provider.DeploymentResource) error {
+	defer dr.Cancel()
+	_, err := app.Parse(os.Args[1:])
+	return err
+}
+
+// cancelOnSignal returns a context which is cancelled on SIGINT or SIGTERM
+// so that the running command stops waiting and returns.
+// A second signal exits right away.
+func cancelOnSignal() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	term := make(chan os.Signal, 2)
+	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-term
+		log.Printf("Received %v, cancelling the running command. Send it again to exit right away.", sig)
+		cancel()
+		<-term
+		os.Exit(1)
+	}()
+	return ctx
+}
@@ -0,0 +1,488 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/test-infra/pkg/provider"
+	k8sProvider "github.com/prometheus/test-infra/pkg/provider/k8s"
+	"gopkg.in/alecthomas/kingpin.v2"
+	yamlGo "gopkg.in/yaml.v2"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func init() {
+	provider.Register("aks", "Azure Kubernetes Service - https://azure.microsoft.com/services/kubernetes-service/", func(dr *provider.DeploymentResource) provider.Provider {
+		return New(dr)
+	})
+}
+
+var (
+	_ provider.ClusterProvider    = (*AKS)(nil)
+	_ provider.NodePoolProvider   = (*AKS)(nil)
+	_ provider.ResourceProvider   = (*AKS)(nil)
+	_ provider.KubeconfigProvider = (*AKS)(nil)
+	_ provider.Destroyer          = (*AKS)(nil)
+	_ provider.NodePoolCoster     = (*AKS)(nil)
+)
+
+// The provisioning states of the clusters and agent pools.
+const (
+	stateSucceeded = "Succeeded"
+	stateFailed    = "Failed"
+	stateCanceled  = "Canceled"
+)
+
+// aksCluster is the format of the cluster and node pool deployment files.
+type aksCluster struct {
+	ResourceGroup string
+	Cluster       Cluster
+}
+
+// AKS holds the fields used to generate an API request.
+type AKS struct {
+	k8sProvider.Base
+
+	// The auth used to authenticate the cli.
+	// Can be a file path or the json data of a service principal.
+	Auth string
+	// The client used when performing the AKS requests.
+	clientAKS Client
+	// backoff is used when waiting for the AKS operations to complete.
+	backoff provider.Backoff
+
+	ctx context.Context
+}
+
+// New is the AKS constructor.
+func New(dr *provider.DeploymentResource) *AKS {
+	return &AKS{
+		Base:    k8sProvider.NewBase(dr, "AKS_RESOURCE_GROUP", "CLUSTER_NAME"),
+		backoff: provider.DefaultBackoff,
+	}
+}
+
+// Flags adds the AKS specific flags.
+func (c *AKS) Flags(cmd *kingpin.CmdClause) {
+	cmd.Flag("auth", "json authentication of a service principal created with `az ad sp create-for-rbac --sdk-auth`. Accepts a filepath or the json data. If not set the tool will use the AZURE_AUTH_LOCATION env variable.").
+		PlaceHolder("credentials.json").
+		Short('a').
+		StringVar(&c.Auth)
+}
+
+// NewClient sets the AKS client used when performing AKS requests.
+func (c *AKS) NewClient(*kingpin.ParseContext) error {
+	if c.Auth != "" {
+	} else if c.Auth = os.Getenv("AZURE_AUTH_LOCATION"); c.Auth == "" {
+		return errors.Errorf("no auth provided set the auth flag or the AZURE_AUTH_LOCATION env variable")
+	}
+
+	// When the auth variable points to a file
+	// put the file content in the variable.
+	if content, err := ioutil.ReadFile(c.Auth); err == nil {
+		c.Auth = string(content)
+	}
+
+	var creds credentials
+	if err := json.Unmarshal([]byte(c.Auth), &creds); err != nil {
+		return errors.Wrap(err, "could not parse the auth data")
+	}
+	c.ctx = c.DeploymentResource.Context()
+	cl, err := newRESTClient(c.ctx, creds)
+	if err != nil {
+		return errors.Wrap(err, "could not create the aks client")
+	}
+	c.clientAKS = cl
+	return nil
+}
+
+// parse parses a cluster or node pool deployment file.
+func parse(deployment provider.Resource) (*aksCluster, error) {
+	req := &aksCluster{}
+	if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
+		return nil, fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
+	}
+	return req, nil
+}
+
+// ClusterCreate creates a new cluster with the agent pools of the cluster deployment files.
+func (c *AKS) ClusterCreate(*kingpin.ParseContext) error {
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Cluster create request: name:'%v', resource group:'%v', location:'%v'", req.Cluster.Name, req.ResourceGroup, req.Cluster.Location)
+		if err := c.clientAKS.CreateCluster(c.ctx, req.ResourceGroup, req.Cluster); err != nil {
+			return fmt.Errorf("Couldn't create cluster '%v', file:%v ,err: %v", req.Cluster.Name, deployment.FileName, err)
+		}
+
+		err = provider.RetryUntilTrue(
+			c.ctx,
+			fmt.Sprintf("creating cluster:%v", req.Cluster.Name),
+			c.backoff,
+			func() (bool, error) { return c.clusterRunning(req.ResourceGroup, req.Cluster.Name) },
+		)
+		if err != nil {
+			return fmt.Errorf("creating cluster err:%v", err)
+		}
+
+		// The agent pools of the cluster are deleted with it so only the cluster is recorded.
+		if err := c.Record(provider.StateEntry{
+			Kind:     provider.StateCluster,
+			Name:     req.Cluster.Name,
+			Settings: stateSettings(req.ResourceGroup),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stateSettings returns the resource group recorded with the cluster objects in the state file.
+func stateSettings(resourceGroup string) map[string]string {
+	return map[string]string{"resourceGroup": resourceGroup}
+}
+
+// ClusterDelete deletes a k8s cluster.
+func (c *AKS) ClusterDelete(*kingpin.ParseContext) error {
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return err
+		}
+
+		if err := c.deleteCluster(req.ResourceGroup, req.Cluster.Name); err != nil {
+			return fmt.Errorf("Couldn't delete cluster '%v', file:%v ,err: %v", req.Cluster.Name, deployment.FileName, err)
+		}
+	}
+	return nil
+}
+
+// deleteCluster deletes a cluster and waits for it to be gone.
+func (c *AKS) deleteCluster(resourceGroup, name string) error {
+	log.Printf("Removing cluster '%v', resource group '%v'", name, resourceGroup)
+	err := provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("delete request for cluster:%v", name),
+		c.backoff,
+		func() (bool, error) {
+			return retryConflict(ignoreNotFound(c.clientAKS.DeleteCluster(c.ctx, resourceGroup, name)))
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("deleting cluster:%v", name),
+		c.backoff,
+		func() (bool, error) { return c.clusterDeleted(resourceGroup, name) },
+	)
+	if err != nil {
+		return fmt.Errorf("removing cluster err:%v", err)
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateCluster, Name: name})
+}
+
+// clusterRunning checks whether a cluster is provisioned.
+func (c *AKS) clusterRunning(resourceGroup, name string) (bool, error) {
+	cluster, err := c.clientAKS.GetCluster(c.ctx, resourceGroup, name)
+	if err != nil {
+		// We don't consider none existing cluster error a failure. So don't return an error here.
+		if errors.Cause(err) == ErrNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("Couldn't get cluster status:%v", err)
+	}
+	return provisioned(fmt.Sprintf("Cluster '%v'", name), cluster.ProvisioningState)
+}
+
+// clusterDeleted checks whether a cluster has been deleted.
+func (c *AKS) clusterDeleted(resourceGroup, name string) (bool, error) {
+	cluster, err := c.clientAKS.GetCluster(c.ctx, resourceGroup, name)
+	if err != nil {
+		if errors.Cause(err) == ErrNotFound {
+			return true, nil
+		}
+		return false, fmt.Errorf("Couldn't get cluster status:%v", err)
+	}
+	log.Printf("Cluster '%v' status:%v", name, cluster.ProvisioningState)
+	return false, nil
+}
+
+// NodesCreate creates the agent pools of the node pool deployment files in an existing cluster.
+func (c *AKS) NodesCreate(*kingpin.ParseContext) error {
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return err
+		}
+
+		for _, pool := range req.Cluster.AgentPools {
+			pool := pool
+			log.Printf("Cluster agent pool create request: cluster '%v', agent pool '%v', resource group '%v'", req.Cluster.Name, pool.Name, req.ResourceGroup)
+			err := provider.RetryUntilTrue(
+				c.ctx,
+				fmt.Sprintf("agent pool creation:%v", pool.Name),
+				c.backoff,
+				func() (bool, error) {
+					return retryConflict(c.clientAKS.CreateAgentPool(c.ctx, req.ResourceGroup, req.Cluster.Name, pool))
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("Couldn't create cluster agent pool '%v', file:%v ,err: %v", pool.Name, deployment.FileName, err)
+			}
+
+			err = provider.RetryUntilTrue(
+				c.ctx,
+				fmt.Sprintf("checking agent pool running status for:%v", pool.Name),
+				c.backoff,
+				func() (bool, error) { return c.agentPoolRunning(req.ResourceGroup, req.Cluster.Name, pool.Name) },
+			)
+			if err != nil {
+				return fmt.Errorf("Couldn't create cluster agent pool '%v', file:%v ,err: %v", pool.Name, deployment.FileName, err)
+			}
+
+			if err := c.Record(provider.StateEntry{
+				Kind:     provider.StateNodePool,
+				Name:     pool.Name,
+				Cluster:  req.Cluster.Name,
+				Settings: stateSettings(req.ResourceGroup),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NodesDelete deletes the agent pools of the node pool deployment files.
+func (c *AKS) NodesDelete(*kingpin.ParseContext) error {
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return err
+		}
+
+		for _, pool := range req.Cluster.AgentPools {
+			if err := c.deleteAgentPool(req.ResourceGroup, req.Cluster.Name, pool.Name); err != nil {
+				return fmt.Errorf("Couldn't delete cluster agent pool '%v', file:%v ,err: %v", pool.Name, deployment.FileName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteAgentPool deletes an agent pool and waits for it to be gone.
+func (c *AKS) deleteAgentPool(resourceGroup, clusterName, name string) error {
+	log.Printf("Removing cluster agent pool: '%v', cluster '%v', resource group '%v'", name, clusterName, resourceGroup)
+	err := provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("delete request for agent pool:%v", name),
+		c.backoff,
+		func() (bool, error) {
+			return retryConflict(ignoreNotFound(c.clientAKS.DeleteAgentPool(c.ctx, resourceGroup, clusterName, name)))
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("deleting agent pool:%v", name),
+		c.backoff,
+		func() (bool, error) { return c.agentPoolDeleted(resourceGroup, clusterName, name) },
+	)
+	if err != nil {
+		return err
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateNodePool, Name: name, Cluster: clusterName})
+}
+
+// retryConflict returns true when a create or delete request was accepted and false
+// when it has to be retried because another operation is running on the cluster.
+func retryConflict(err error) (bool, error) {
+	switch errors.Cause(err) {
+	case nil:
+		return true, nil
+	case ErrConflict:
+		// AKS cannot run two operations on a cluster at the same time,
+		// waiting for the ongoing operation to complete before starting a new one.
+		log.Printf("Cluster has an operation in progress '%s'", err)
+		return false, nil
+	}
+	return false, err
+}
+
+// ignoreNotFound returns nil for ErrNotFound as deleting something that doesn't exist is not an error.
+func ignoreNotFound(err error) error {
+	if errors.Cause(err) == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// agentPoolRunning checks whether an agent pool has been created and is provisioned.
+func (c *AKS) agentPoolRunning(resourceGroup, clusterName, name string) (bool, error) {
+	pool, err := c.clientAKS.GetAgentPool(c.ctx, resourceGroup, clusterName, name)
+	if err != nil {
+		// We don't consider none existing agent pool a failure. So don't return an error here.
+		if errors.Cause(err) == ErrNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("Couldn't get agent pool status:%v", err)
+	}
+	return provisioned(fmt.Sprintf("Cluster agent pool '%v'", name), pool.ProvisioningState)
+}
+
+// agentPoolDeleted checks whether an agent pool has been deleted.
+func (c *AKS) agentPoolDeleted(resourceGroup, clusterName, name string) (bool, error) {
+	pool, err := c.clientAKS.GetAgentPool(c.ctx, resourceGroup, clusterName, name)
+	if err != nil {
+		if errors.Cause(err) == ErrNotFound {
+			return true, nil
+		}
+		return false, fmt.Errorf("Couldn't get agent pool status:%v", err)
+	}
+	log.Printf("Cluster agent pool '%v' status:%v", name, pool.ProvisioningState)
+	return false, nil
+}
+
+// provisioned returns true when the provisioning state is Succeeded
+// and an error when it is in a state that won't become ready.
+func provisioned(name, state string) (bool, error) {
+	switch state {
+	case stateSucceeded:
+		return true, nil
+	case stateFailed, stateCanceled:
+		return false, fmt.Errorf("%v not in a status to become ready - %v", name, state)
+	}
+	log.Printf("%v status:%v", name, state)
+	return false, nil
+}
+
+// AllNodesRunning returns an error if at least one agent pool is not running.
+func (c *AKS) AllNodesRunning(*kingpin.ParseContext) error {
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return err
+		}
+		for _, pool := range req.Cluster.AgentPools {
+			isRunning, err := c.agentPoolRunning(req.ResourceGroup, req.Cluster.Name, pool.Name)
+			if err != nil {
+				return fmt.Errorf("error fetching agent pool info: %v", err)
+			}
+			if !isRunning {
+				return fmt.Errorf("agent pool not running name: %v", pool.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// AllNodesDeleted returns an error if at least one agent pool is not deleted.
+func (c *AKS) AllNodesDeleted(*kingpin.ParseContext) error {
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return err
+		}
+		for _, pool := range req.Cluster.AgentPools {
+			isDeleted, err := c.agentPoolDeleted(req.ResourceGroup, req.Cluster.Name, pool.Name)
+			if err != nil {
+				return fmt.Errorf("error fetching agent pool info: %v", err)
+			}
+			if !isDeleted {
+				return fmt.Errorf("agent pool not deleted name: %v", pool.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// NodePoolSpecs returns the vm size and the number of nodes of every agent pool in the deployment files.
+func (c *AKS) NodePoolSpecs() ([]provider.NodePoolSpec, error) {
+	var specs []provider.NodePoolSpec
+	for _, deployment := range c.ProviderResources {
+		req, err := parse(deployment)
+		if err != nil {
+			return nil, err
+		}
+		for _, pool := range req.Cluster.AgentPools {
+			specs = append(specs, provider.NodePoolSpec{
+				Name:        pool.Name,
+				MachineType: pool.VMSize,
+				Nodes:       int(pool.Count),
+			})
+		}
+	}
+	return specs, nil
+}
+
+// Kubeconfig returns the kubeconfig of the cluster user.
+func (c *AKS) Kubeconfig() ([]byte, error) {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return nil, err
+	}
+	kubeconfig, err := c.clientAKS.Kubeconfig(c.ctx, c.DeploymentVars["AKS_RESOURCE_GROUP"], c.DeploymentVars["CLUSTER_NAME"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the cluster kubeconfig: %v", err)
+	}
+	return kubeconfig, nil
+}
+
+// NewK8sProvider sets the k8s provider used for deploying k8s manifests.
+func (c *AKS) NewK8sProvider(*kingpin.ParseContext) error {
+	kubeconfig, err := c.Kubeconfig()
+	if err != nil {
+		return err
+	}
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to parse the cluster kubeconfig: %v", err)
+	}
+	c.K8sProvider, err = k8sProvider.New(c.ctx, config)
+	if err != nil {
+		return fmt.Errorf("k8s provider error %v", err)
+	}
+	return nil
+}
+
+// Destroy deletes a cluster, agent pool or namespace recorded in the state file.
+func (c *AKS) Destroy(e provider.StateEntry) error {
+	switch e.Kind {
+	case provider.StateCluster:
+		return c.deleteCluster(e.Settings["resourceGroup"], e.Name)
+	case provider.StateNodePool:
+		return c.deleteAgentPool(e.Settings["resourceGroup"], e.Cluster, e.Name)
+	case provider.StateNamespace:
+		if err := c.NewK8sProvider(nil); err != nil {
+			return err
+		}
+		return c.K8sProvider.NamespaceDelete(e.Name)
+	}
+	return fmt.Errorf("unknown state entry kind %q", e.Kind)
+}
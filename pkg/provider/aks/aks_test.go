@@ -0,0 +1,452 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+// fakeSteps is the number of get requests it takes for an operation of the fake client to complete.
+const fakeSteps = 2
+
+// fakeClient is an in memory Client where the create and delete operations complete after a few get requests.
+type fakeClient struct {
+	clusters map[string]*Cluster
+	pools    map[string]*AgentPool
+	// pending is the number of get requests left before the operation on the object completes.
+	pending map[string]int
+	// conflicts is the number of create and delete requests that fail with ErrConflict before one is accepted.
+	conflicts int
+	// failPool is the name of an agent pool that fails to be provisioned.
+	failPool string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		clusters: map[string]*Cluster{},
+		pools:    map[string]*AgentPool{},
+		pending:  map[string]int{},
+	}
+}
+
+func (f *fakeClient) conflict() error {
+	if f.conflicts > 0 {
+		f.conflicts--
+		return errors.Wrap(ErrConflict, "fake operation in progress")
+	}
+	return nil
+}
+
+// advance returns the provisioning state after a get request, empty when the object is gone.
+func (f *fakeClient) advance(key, state string) string {
+	if f.pending[key] > 0 {
+		f.pending[key]--
+		return state
+	}
+	switch state {
+	case "Creating":
+		return stateSucceeded
+	case "Deleting":
+		return ""
+	}
+	return state
+}
+
+func (f *fakeClient) CreateCluster(_ context.Context, resourceGroup string, cluster Cluster) error {
+	if err := f.conflict(); err != nil {
+		return err
+	}
+	key := resourceGroup + "/" + cluster.Name
+	for _, pool := range cluster.AgentPools {
+		pool.ProvisioningState = stateSucceeded
+		f.pools[key+"/"+pool.Name] = &pool
+	}
+	cluster.ProvisioningState = "Creating"
+	f.clusters[key] = &cluster
+	f.pending[key] = fakeSteps
+	return nil
+}
+
+func (f *fakeClient) GetCluster(_ context.Context, resourceGroup, name string) (*Cluster, error) {
+	key := resourceGroup + "/" + name
+	cluster, ok := f.clusters[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if cluster.ProvisioningState = f.advance(key, cluster.ProvisioningState); cluster.ProvisioningState == "" {
+		delete(f.clusters, key)
+		for k := range f.pools {
+			if strings.HasPrefix(k, key+"/") {
+				delete(f.pools, k)
+			}
+		}
+		return nil, ErrNotFound
+	}
+	c := *cluster
+	return &c, nil
+}
+
+func (f *fakeClient) DeleteCluster(_ context.Context, resourceGroup, name string) error {
+	if err := f.conflict(); err != nil {
+		return err
+	}
+	key := resourceGroup + "/" + name
+	cluster, ok := f.clusters[key]
+	if !ok {
+		return ErrNotFound
+	}
+	cluster.ProvisioningState = "Deleting"
+	f.pending[key] = fakeSteps
+	return nil
+}
+
+func (f *fakeClient) CreateAgentPool(_ context.Context, resourceGroup, clusterName string, pool AgentPool) error {
+	if err := f.conflict(); err != nil {
+		return err
+	}
+	if _, ok := f.clusters[resourceGroup+"/"+clusterName]; !ok {
+		return ErrNotFound
+	}
+	key := resourceGroup + "/" + clusterName + "/" + pool.Name
+	pool.ProvisioningState = "Creating"
+	if pool.Name == f.failPool {
+		pool.ProvisioningState = stateFailed
+	}
+	f.pools[key] = &pool
+	f.pending[key] = fakeSteps
+	return nil
+}
+
+func (f *fakeClient) GetAgentPool(_ context.Context, resourceGroup, clusterName, name string) (*AgentPool, error) {
+	key := resourceGroup + "/" + clusterName + "/" + name
+	pool, ok := f.pools[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if pool.ProvisioningState = f.advance(key, pool.ProvisioningState); pool.ProvisioningState == "" {
+		delete(f.pools, key)
+		return nil, ErrNotFound
+	}
+	p := *pool
+	return &p, nil
+}
+
+func (f *fakeClient) DeleteAgentPool(_ context.Context, resourceGroup, clusterName, name string) error {
+	if err := f.conflict(); err != nil {
+		return err
+	}
+	key := resourceGroup + "/" + clusterName + "/" + name
+	pool, ok := f.pools[key]
+	if !ok {
+		return ErrNotFound
+	}
+	pool.ProvisioningState = "Deleting"
+	f.pending[key] = fakeSteps
+	return nil
+}
+
+func (f *fakeClient) Kubeconfig(context.Context, string, string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+// newTestAKS returns an AKS provider that uses the fake client and the deployment file content.
+func newTestAKS(f *fakeClient, content string) *AKS {
+	c := New(provider.NewDeploymentResource())
+	c.clientAKS = f
+	c.ctx = context.Background()
+	c.backoff = provider.Backoff{Initial: time.Millisecond, Factor: 1, Timeout: 10 * time.Second}
+	c.ProviderResources = []provider.Resource{{FileName: "cluster.yaml", Content: []byte(content)}}
+	return c
+}
+
+const clusterFile = `
+resourcegroup: prombench
+cluster:
+  name: test
+  location: eastus
+  kubernetesversion: 1.18.8
+  agentpools:
+  - name: main
+    mode: System
+    count: 1
+    vmsize: Standard_D4s_v3
+    nodelabels:
+      node-name: main-node
+`
+
+const nodesFile = `
+resourcegroup: prombench
+cluster:
+  name: test
+  agentpools:
+  - name: prom1
+    count: 2
+    vmsize: Standard_E8ds_v4
+    nodelabels:
+      node-name: prometheus-1
+  - name: nodes1
+    count: 1
+    vmsize: Standard_F16s_v2
+    nodelabels:
+      node-name: nodes-1
+`
+
+func TestClusterLifecycle(t *testing.T) {
+	f := newFakeClient()
+	c := newTestAKS(f, clusterFile)
+
+	if err := c.ClusterCreate(nil); err != nil {
+		t.Fatal(err)
+	}
+	cluster := f.clusters["prombench/test"]
+	if cluster == nil || cluster.ProvisioningState != stateSucceeded {
+		t.Fatalf("expected a provisioned cluster, got %+v", cluster)
+	}
+	if cluster.Location != "eastus" || len(cluster.AgentPools) != 1 || cluster.AgentPools[0].NodeLabels["node-name"] != "main-node" {
+		t.Errorf("the cluster doesn't match the deployment file: %+v", cluster)
+	}
+
+	f.conflicts = 1
+	if err := c.ClusterDelete(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.clusters) != 0 || len(f.pools) != 0 {
+		t.Errorf("expected the cluster and its agent pools to be deleted, got %v and %v", f.clusters, f.pools)
+	}
+	// Deleting a missing cluster is not an error.
+	if err := c.ClusterDelete(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNodesLifecycle(t *testing.T) {
+	f := newFakeClient()
+	f.clusters["prombench/test"] = &Cluster{Name: "test", ProvisioningState: stateSucceeded}
+	c := newTestAKS(f, nodesFile)
+
+	// Another operation is still running on the cluster for the first requests.
+	f.conflicts = 2
+	if err := c.NodesCreate(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"prom1", "nodes1"} {
+		if p := f.pools["prombench/test/"+name]; p == nil || p.ProvisioningState != stateSucceeded {
+			t.Errorf("expected agent pool %v to be provisioned, got %+v", name, p)
+		}
+	}
+	if err := c.AllNodesRunning(nil); err != nil {
+		t.Errorf("expected all agent pools to be running: %v", err)
+	}
+	if err := c.AllNodesDeleted(nil); err == nil {
+		t.Error("expected an error when the agent pools are not deleted")
+	}
+
+	f.conflicts = 1
+	if err := c.NodesDelete(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.pools) != 0 {
+		t.Errorf("expected the agent pools to be deleted, got %v", f.pools)
+	}
+	if err := c.AllNodesDeleted(nil); err != nil {
+		t.Errorf("expected all agent pools to be deleted: %v", err)
+	}
+	if err := c.AllNodesRunning(nil); err == nil {
+		t.Error("expected an error when the agent pools are not running")
+	}
+}
+
+func TestNodesCreateErrors(t *testing.T) {
+	f := newFakeClient()
+	c := newTestAKS(f, nodesFile)
+	if err := c.NodesCreate(nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a not found error without the cluster, got %v", err)
+	}
+
+	f.clusters["prombench/test"] = &Cluster{Name: "test", ProvisioningState: stateSucceeded}
+	f.failPool = "nodes1"
+	err := c.NodesCreate(nil)
+	if err == nil || !strings.Contains(err.Error(), "nodes1") || !strings.Contains(err.Error(), stateFailed) {
+		t.Errorf("expected the failed agent pool in the error, got %v", err)
+	}
+}
+
+func TestNodePoolSpecs(t *testing.T) {
+	c := newTestAKS(newFakeClient(), nodesFile)
+	specs, err := c.NodePoolSpecs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []provider.NodePoolSpec{
+		{Name: "prom1", MachineType: "Standard_E8ds_v4", Nodes: 2},
+		{Name: "nodes1", MachineType: "Standard_F16s_v2", Nodes: 1},
+	}
+	if !reflect.DeepEqual(expected, specs) {
+		t.Errorf("\nexpect node pools %v\ngot %v", expected, specs)
+	}
+}
+
+func TestStateDestroy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := newFakeClient()
+	f.clusters["prombench/test"] = &Cluster{Name: "test", ProvisioningState: stateSucceeded}
+	c := newTestAKS(f, nodesFile)
+	c.DeploymentResource.ProviderName = "aks"
+	c.DeploymentResource.StateFile = filepath.Join(dir, "state.json")
+	if err := c.NodesCreate(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := provider.LoadState(c.DeploymentResource.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded []string
+	for _, e := range state.Entries {
+		recorded = append(recorded, e.String()+" "+e.Settings["resourceGroup"])
+	}
+	expected := []string{"aks nodepool test/prom1 prombench", "aks nodepool test/nodes1 prombench"}
+	if !reflect.DeepEqual(expected, recorded) {
+		t.Fatalf("\nexpect recorded %v\ngot %v", expected, recorded)
+	}
+
+	// Destroy doesn't need the deployment files.
+	c.ProviderResources = nil
+	for _, e := range state.Entries {
+		if err := c.Destroy(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(f.pools) != 0 {
+		t.Errorf("expected the agent pools to be deleted, got %v", f.pools)
+	}
+	if state, err = provider.LoadState(c.DeploymentResource.StateFile); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Entries) != 0 {
+		t.Errorf("expected the deleted agent pools to be removed from the state file, got %v", state.Entries)
+	}
+}
+
+func TestRESTClient(t *testing.T) {
+	var requests []string
+	bodies := map[string]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api-version") != apiVersion {
+			t.Errorf("unexpected api version in %v", r.URL)
+		}
+		req := r.Method + " " + r.URL.Path
+		requests = append(requests, req)
+		if b, _ := ioutil.ReadAll(r.Body); len(b) > 0 {
+			var body map[string]interface{}
+			if err := json.Unmarshal(b, &body); err != nil {
+				t.Errorf("%v: invalid json body: %v", req, err)
+			}
+			bodies[req] = body
+		}
+
+		cluster := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/test"
+		switch req {
+		case "PUT " + cluster:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case "GET " + cluster:
+			w.Write([]byte(`{"location":"eastus","properties":{"provisioningState":"Creating","agentPoolProfiles":[{"name":"main","count":1}]}}`))
+		case "PUT " + cluster + "/agentPools/prom1":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":{"code":"OperationNotAllowed","message":"Operation is not allowed because there's an in progress create managed cluster operation"}}`))
+		case "GET " + cluster + "/agentPools/prom1":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"NotFound","message":"agent pool not found"}}`))
+		case "POST " + cluster + "/listClusterUserCredential":
+			w.Write([]byte(`{"kubeconfigs":[{"name":"clusterUser","value":"YXBpVmVyc2lvbjogdjE="}]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := &restClient{http: srv.Client(), endpoint: srv.URL, subscriptionID: "sub"}
+	ctx := context.Background()
+
+	err := c.CreateCluster(ctx, "rg", Cluster{
+		Name:       "test",
+		Location:   "eastus",
+		AgentPools: []AgentPool{{Name: "main", Mode: "System", Count: 1, NodeLabels: map[string]string{"node-name": "main-node"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := bodies[requests[0]]
+	expected := map[string]interface{}{
+		"location": "eastus",
+		"identity": map[string]interface{}{"type": "SystemAssigned"},
+		"properties": map[string]interface{}{
+			"dnsPrefix": "test",
+			"agentPoolProfiles": []interface{}{map[string]interface{}{
+				"name": "main", "mode": "System", "count": 1.0, "type": "VirtualMachineScaleSets", "osType": "Linux",
+				"nodeLabels": map[string]interface{}{"node-name": "main-node"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(expected, body) {
+		t.Errorf("unexpected cluster request body:\n%v\nexpected:\n%v", body, expected)
+	}
+
+	cluster, err := c.GetCluster(ctx, "rg", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster.ProvisioningState != "Creating" || len(cluster.AgentPools) != 1 || cluster.AgentPools[0].Name != "main" {
+		t.Errorf("unexpected cluster %+v", cluster)
+	}
+
+	err = c.CreateAgentPool(ctx, "rg", "test", AgentPool{Name: "prom1", Count: 2})
+	if errors.Cause(err) != ErrConflict || !strings.Contains(err.Error(), "OperationNotAllowed") {
+		t.Errorf("expected a conflict error with the api message, got %v", err)
+	}
+	if props := bodies[requests[2]]["properties"].(map[string]interface{}); props["name"] != nil || props["count"] != 2.0 {
+		t.Errorf("unexpected agent pool properties %v", props)
+	}
+
+	if _, err := c.GetAgentPool(ctx, "rg", "test", "prom1"); errors.Cause(err) != ErrNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	kubeconfig, err := c.Kubeconfig(ctx, "rg", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kubeconfig) != "apiVersion: v1" {
+		t.Errorf("unexpected kubeconfig %q", kubeconfig)
+	}
+}
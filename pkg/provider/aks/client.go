@@ -0,0 +1,299 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// apiVersion is the version of the Microsoft.ContainerService api used by the rest client.
+const apiVersion = "2020-09-01"
+
+var (
+	// ErrNotFound is returned by the Client when the cluster or the agent pool doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned by the Client when another operation is running on the cluster.
+	// AKS runs only one operation at a time on a cluster, like GKE, so the request should be retried.
+	ErrConflict = errors.New("another operation is in progress")
+)
+
+// Client is the part of the AKS api used by the provider.
+// The provider only uses this interface so that the lifecycle and the retries can be tested with a fake client.
+//
+// The create and delete requests only start the operation,
+// its progress is followed through the ProvisioningState returned by the get requests.
+type Client interface {
+	CreateCluster(ctx context.Context, resourceGroup string, cluster Cluster) error
+	GetCluster(ctx context.Context, resourceGroup, name string) (*Cluster, error)
+	DeleteCluster(ctx context.Context, resourceGroup, name string) error
+	CreateAgentPool(ctx context.Context, resourceGroup, clusterName string, pool AgentPool) error
+	GetAgentPool(ctx context.Context, resourceGroup, clusterName, name string) (*AgentPool, error)
+	DeleteAgentPool(ctx context.Context, resourceGroup, clusterName, name string) error
+	// Kubeconfig returns the kubeconfig of the cluster user.
+	Kubeconfig(ctx context.Context, resourceGroup, clusterName string) ([]byte, error)
+}
+
+// Cluster is an AKS managed cluster as defined in the cluster deployment files.
+type Cluster struct {
+	Name              string
+	Location          string
+	KubernetesVersion string
+	DNSPrefix         string
+	Tags              map[string]string
+	// AgentPools are created with the cluster from the cluster deployment files
+	// and one by one from the node pool deployment files.
+	AgentPools []AgentPool
+	// ProvisioningState is set by the api, Succeeded once the cluster is ready.
+	ProvisioningState string `yaml:"-"`
+}
+
+// AgentPool is an AKS agent pool, the node pool of a managed cluster.
+// The name can only contain lowercase letters and numbers and can't be longer than 12 characters.
+type AgentPool struct {
+	Name                string            `json:"name,omitempty"`
+	Mode                string            `json:"mode,omitempty"`
+	Count               int32             `json:"count"`
+	VMSize              string            `json:"vmSize,omitempty"`
+	OSDiskSizeGB        int32             `json:"osDiskSizeGB,omitempty"`
+	OrchestratorVersion string            `json:"orchestratorVersion,omitempty"`
+	NodeLabels          map[string]string `json:"nodeLabels,omitempty"`
+	NodeTaints          []string          `json:"nodeTaints,omitempty"`
+	// ProvisioningState is set by the api, Succeeded once the agent pool is ready.
+	ProvisioningState string `json:"provisioningState,omitempty" yaml:"-"`
+}
+
+// credentials is the service principal file created with `az ad sp create-for-rbac --sdk-auth`.
+type credentials struct {
+	ClientID                   string `json:"clientId"`
+	ClientSecret               string `json:"clientSecret"`
+	SubscriptionID             string `json:"subscriptionId"`
+	TenantID                   string `json:"tenantId"`
+	ActiveDirectoryEndpointURL string `json:"activeDirectoryEndpointUrl"`
+	ResourceManagerEndpointURL string `json:"resourceManagerEndpointUrl"`
+}
+
+// restClient implements the Client with the Azure Resource Manager rest api.
+type restClient struct {
+	http           *http.Client
+	endpoint       string
+	subscriptionID string
+}
+
+// newRESTClient returns a Client that authenticates as the service principal of the credentials.
+func newRESTClient(ctx context.Context, creds credentials) (*restClient, error) {
+	if creds.ClientID == "" || creds.ClientSecret == "" || creds.SubscriptionID == "" || creds.TenantID == "" {
+		return nil, errors.New("the auth must have the clientId, clientSecret, subscriptionId and tenantId fields")
+	}
+	ad := creds.ActiveDirectoryEndpointURL
+	if ad == "" {
+		ad = "https://login.microsoftonline.com"
+	}
+	rm := creds.ResourceManagerEndpointURL
+	if rm == "" {
+		rm = "https://management.azure.com/"
+	}
+	conf := clientcredentials.Config{
+		ClientID:       creds.ClientID,
+		ClientSecret:   creds.ClientSecret,
+		TokenURL:       strings.TrimSuffix(ad, "/") + "/" + creds.TenantID + "/oauth2/token",
+		EndpointParams: url.Values{"resource": {rm}},
+	}
+	return &restClient{
+		http:           conf.Client(ctx),
+		endpoint:       strings.TrimSuffix(rm, "/"),
+		subscriptionID: creds.SubscriptionID,
+	}, nil
+}
+
+// armCluster is the managed cluster resource of the rest api.
+type armCluster struct {
+	Location string            `json:"location,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Identity *armIdentity      `json:"identity,omitempty"`
+	// Properties of the managed cluster.
+	Properties struct {
+		ProvisioningState string                `json:"provisioningState,omitempty"`
+		KubernetesVersion string                `json:"kubernetesVersion,omitempty"`
+		DNSPrefix         string                `json:"dnsPrefix,omitempty"`
+		AgentPoolProfiles []armAgentPoolProfile `json:"agentPoolProfiles,omitempty"`
+	} `json:"properties"`
+}
+
+type armIdentity struct {
+	Type string `json:"type"`
+}
+
+// armAgentPoolProfile adds the fields that are the same for all agent pools.
+type armAgentPoolProfile struct {
+	AgentPool
+	Type   string `json:"type,omitempty"`
+	OSType string `json:"osType,omitempty"`
+}
+
+// armAgentPool is the agent pool resource of the rest api.
+type armAgentPool struct {
+	Name       string              `json:"name,omitempty"`
+	Properties armAgentPoolProfile `json:"properties"`
+}
+
+func agentPoolProfile(pool AgentPool) armAgentPoolProfile {
+	pool.ProvisioningState = ""
+	return armAgentPoolProfile{AgentPool: pool, Type: "VirtualMachineScaleSets", OSType: "Linux"}
+}
+
+func (c *restClient) clusterPath(resourceGroup, name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s",
+		url.PathEscape(c.subscriptionID), url.PathEscape(resourceGroup), url.PathEscape(name))
+}
+
+func (c *restClient) agentPoolPath(resourceGroup, clusterName, name string) string {
+	return c.clusterPath(resourceGroup, clusterName) + "/agentPools/" + url.PathEscape(name)
+}
+
+func (c *restClient) CreateCluster(ctx context.Context, resourceGroup string, cluster Cluster) error {
+	var req armCluster
+	req.Location = cluster.Location
+	req.Tags = cluster.Tags
+	req.Identity = &armIdentity{Type: "SystemAssigned"}
+	req.Properties.KubernetesVersion = cluster.KubernetesVersion
+	req.Properties.DNSPrefix = cluster.DNSPrefix
+	if req.Properties.DNSPrefix == "" {
+		req.Properties.DNSPrefix = cluster.Name
+	}
+	for _, pool := range cluster.AgentPools {
+		req.Properties.AgentPoolProfiles = append(req.Properties.AgentPoolProfiles, agentPoolProfile(pool))
+	}
+	return c.do(ctx, http.MethodPut, c.clusterPath(resourceGroup, cluster.Name), req, nil)
+}
+
+func (c *restClient) GetCluster(ctx context.Context, resourceGroup, name string) (*Cluster, error) {
+	var rep armCluster
+	if err := c.do(ctx, http.MethodGet, c.clusterPath(resourceGroup, name), nil, &rep); err != nil {
+		return nil, err
+	}
+	cluster := &Cluster{
+		Name:              name,
+		Location:          rep.Location,
+		KubernetesVersion: rep.Properties.KubernetesVersion,
+		DNSPrefix:         rep.Properties.DNSPrefix,
+		Tags:              rep.Tags,
+		ProvisioningState: rep.Properties.ProvisioningState,
+	}
+	for _, p := range rep.Properties.AgentPoolProfiles {
+		cluster.AgentPools = append(cluster.AgentPools, p.AgentPool)
+	}
+	return cluster, nil
+}
+
+func (c *restClient) DeleteCluster(ctx context.Context, resourceGroup, name string) error {
+	return c.do(ctx, http.MethodDelete, c.clusterPath(resourceGroup, name), nil, nil)
+}
+
+func (c *restClient) CreateAgentPool(ctx context.Context, resourceGroup, clusterName string, pool AgentPool) error {
+	profile := agentPoolProfile(pool)
+	// The name is part of the path and is not a property of the agent pool resource.
+	profile.Name = ""
+	return c.do(ctx, http.MethodPut, c.agentPoolPath(resourceGroup, clusterName, pool.Name), armAgentPool{Properties: profile}, nil)
+}
+
+func (c *restClient) GetAgentPool(ctx context.Context, resourceGroup, clusterName, name string) (*AgentPool, error) {
+	var rep armAgentPool
+	if err := c.do(ctx, http.MethodGet, c.agentPoolPath(resourceGroup, clusterName, name), nil, &rep); err != nil {
+		return nil, err
+	}
+	pool := rep.Properties.AgentPool
+	pool.Name = name
+	return &pool, nil
+}
+
+func (c *restClient) DeleteAgentPool(ctx context.Context, resourceGroup, clusterName, name string) error {
+	return c.do(ctx, http.MethodDelete, c.agentPoolPath(resourceGroup, clusterName, name), nil, nil)
+}
+
+func (c *restClient) Kubeconfig(ctx context.Context, resourceGroup, clusterName string) ([]byte, error) {
+	var rep struct {
+		Kubeconfigs []struct {
+			Name  string `json:"name"`
+			Value []byte `json:"value"`
+		} `json:"kubeconfigs"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.clusterPath(resourceGroup, clusterName)+"/listClusterUserCredential", nil, &rep); err != nil {
+		return nil, err
+	}
+	if len(rep.Kubeconfigs) == 0 {
+		return nil, fmt.Errorf("no kubeconfig for cluster:%v", clusterName)
+	}
+	return rep.Kubeconfigs[0].Value, nil
+}
+
+// do sends the request with in as the json body and decodes the json response into out.
+// It returns ErrNotFound and ErrConflict, wrapped with the message of the api,
+// for the responses with the 404 and 409 status codes.
+func (c *restClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.endpoint+path+"?api-version="+apiVersion, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var rep struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		// The body is only used for the error message so a body that is not json is not an error.
+		json.NewDecoder(resp.Body).Decode(&rep)
+		msg := fmt.Sprintf("%v %v: %v %v %v", method, path, resp.Status, rep.Error.Code, rep.Error.Message)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return errors.Wrap(ErrNotFound, msg)
+		case http.StatusConflict:
+			return errors.Wrap(ErrConflict, msg)
+		}
+		return errors.New(msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yamlGo "gopkg.in/yaml.v2"
+)
+
+// NodePoolSpec is the machine type and the number of nodes of a node pool in the deployment files.
+type NodePoolSpec struct {
+	Name        string
+	MachineType string
+	Nodes       int
+}
+
+// PriceTable is the hourly price of the machine types of every provider.
+type PriceTable struct {
+	// Version identifies the prices, like the date they were taken from the price lists of the providers.
+	Version  string `yaml:"version"`
+	Currency string `yaml:"currency"`
+	// Prices is the hourly price by provider name and machine type.
+	Prices map[string]map[string]float64 `yaml:"prices"`
+}
+
+// LoadPriceTable reads a yaml price table.
+func LoadPriceTable(path string) (*PriceTable, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the price table")
+	}
+	t := &PriceTable{}
+	if err := yamlGo.UnmarshalStrict(content, t); err != nil {
+		return nil, errors.Wrapf(err, "parsing the price table %v", path)
+	}
+	if t.Version == "" {
+		return nil, fmt.Errorf("the price table %v has no version", path)
+	}
+	return t, nil
+}
+
+// WriteNodesCost writes the hourly cost of the node pools and the cost of running them for the given duration.
+// It is a markdown table so that it can be posted in a GitHub comment.
+// All machine types have to be in the price table for the provider.
+func WriteNodesCost(w io.Writer, provider string, pools []NodePoolSpec, prices *PriceTable, duration time.Duration) error {
+	var missing []string
+	for _, p := range pools {
+		if _, ok := prices.Prices[provider][p.MachineType]; !ok {
+			missing = append(missing, p.MachineType)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no %v price for the machine types: %v, add them to the price table", provider, strings.Join(missing, ", "))
+	}
+
+	var (
+		nodes int
+		total float64
+	)
+	fmt.Fprintln(w, "| Node pool | Machine type | Nodes | Hourly cost |")
+	fmt.Fprintln(w, "|---|---|--:|--:|")
+	for _, p := range pools {
+		cost := prices.Prices[provider][p.MachineType] * float64(p.Nodes)
+		nodes += p.Nodes
+		total += cost
+		fmt.Fprintf(w, "| %v | %v | %d | %.2f %v |\n", p.Name, p.MachineType, p.Nodes, cost, prices.Currency)
+	}
+	fmt.Fprintf(w, "| **Total** | | %d | **%.2f %v** |\n", nodes, total, prices.Currency)
+	fmt.Fprintf(w, "\nProjected cost for %gh: **%.2f %v** (compute only, %v prices %v)\n",
+		duration.Hours(), total*duration.Hours(), prices.Currency, provider, prices.Version)
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPriceTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "prices.yaml")
+	if err := ioutil.WriteFile(path, []byte("version: \"2020-10-01\"\ncurrency: USD\nprices:\n  eks:\n    c5.4xlarge: 0.68\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prices, err := LoadPriceTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prices.Version != "2020-10-01" || prices.Prices["eks"]["c5.4xlarge"] != 0.68 {
+		t.Errorf("unexpected price table: %+v", prices)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("prices:\n  eks:\n    c5.4xlarge: 0.68\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPriceTable(path); err == nil {
+		t.Error("expected an error for a price table without a version")
+	}
+}
+
+func TestWriteNodesCost(t *testing.T) {
+	prices := &PriceTable{
+		Version:  "2020-10-01",
+		Currency: "USD",
+		Prices: map[string]map[string]float64{
+			"eks": {"r5d.2xlarge": 0.576, "c5.4xlarge": 0.68},
+		},
+	}
+	pools := []NodePoolSpec{
+		{Name: "prometheus-1234", MachineType: "r5d.2xlarge", Nodes: 2},
+		{Name: "nodes-1234", MachineType: "c5.4xlarge", Nodes: 1},
+	}
+
+	var b strings.Builder
+	if err := WriteNodesCost(&b, "eks", pools, prices, 72*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	expected := `| Node pool | Machine type | Nodes | Hourly cost |
+|---|---|--:|--:|
+| prometheus-1234 | r5d.2xlarge | 2 | 1.15 USD |
+| nodes-1234 | c5.4xlarge | 1 | 0.68 USD |
+| **Total** | | 3 | **1.83 USD** |
+
+Projected cost for 72h: **131.90 USD** (compute only, eks prices 2020-10-01)
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, b.String())
+	}
+
+	pools = append(pools, NodePoolSpec{Name: "main-node", MachineType: "t3.xlarge", Nodes: 1})
+	err := WriteNodesCost(&b, "eks", pools, prices, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "t3.xlarge") {
+		t.Errorf("expected an error naming the missing machine type, got %v", err)
+	}
+	if err := WriteNodesCost(&b, "gke", pools[:1], prices, time.Hour); err == nil {
+		t.Error("expected an error for a provider without prices")
+	}
+}
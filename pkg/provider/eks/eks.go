@@ -21,7 +21,6 @@ import (
 	"log"
 	"os"
 	"regexp"
-	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -35,13 +34,29 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 	yamlGo "gopkg.in/yaml.v2"
 
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/client-go/kubernetes/scheme"
+	"golang.org/x/oauth2"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/transport"
 	awsToken "sigs.k8s.io/aws-iam-authenticator/pkg/token"
 )
 
-type Resource = provider.Resource
+func init() {
+	provider.Register("eks", "Amazon Elastic Kubernetes Service - https://aws.amazon.com/eks", func(dr *provider.DeploymentResource) provider.Provider {
+		return New(dr)
+	})
+}
+
+var (
+	_ provider.ClusterProvider    = (*EKS)(nil)
+	_ provider.NodePoolProvider   = (*EKS)(nil)
+	_ provider.ResourceProvider   = (*EKS)(nil)
+	_ provider.KubeconfigProvider = (*EKS)(nil)
+	_ provider.Destroyer          = (*EKS)(nil)
+	_ provider.GarbageCollector   = (*EKS)(nil)
+	_ provider.NodePoolCoster     = (*EKS)(nil)
+)
 
 type eksCluster struct {
 	Cluster    eks.CreateClusterInput
@@ -50,6 +65,8 @@ type eksCluster struct {
 
 // EKS holds the fields used to generate an API request.
 type EKS struct {
+	k8sProvider.Base
+
 	Auth string
 
 	ClusterName string
@@ -57,18 +74,6 @@ type EKS struct {
 	clientEKS *eks.EKS
 	// The aws session used in abstraction of aws credentials.
 	sessionAWS *awsSession.Session
-	// The k8s provider used when we work with the manifest files.
-	k8sProvider *k8sProvider.K8s
-	// Final DeploymentFiles files.
-	DeploymentFiles []string
-	// Final DeploymentVars.
-	DeploymentVars map[string]string
-	// DeployResource to construct DeploymentVars and DeploymentFiles
-	DeploymentResource *provider.DeploymentResource
-	// Content bytes after parsing the template variables, grouped by filename.
-	eksResources []Resource
-	// K8s resource.runtime objects after parsing the template variables, grouped by filename.
-	k8sResources []k8sProvider.Resource
 
 	ctx context.Context
 }
@@ -76,13 +81,21 @@ type EKS struct {
 // New is the EKS constructor
 func New(dr *provider.DeploymentResource) *EKS {
 	eks := &EKS{
-		DeploymentResource: dr,
+		Base: k8sProvider.NewBase(dr, "ZONE", "CLUSTER_NAME"),
 	}
 	return eks
 }
 
-// NewEKSClient sets the EKS client used when performing the GKE requests.
-func (c *EKS) NewEKSClient(*kingpin.ParseContext) error {
+// Flags adds the EKS specific flags.
+func (c *EKS) Flags(cmd *kingpin.CmdClause) {
+	cmd.Flag("auth", "filename which consist eks credentials.").
+		PlaceHolder("credentials").
+		Short('a').
+		StringVar(&c.Auth)
+}
+
+// NewClient sets the EKS client used when performing the EKS requests.
+func (c *EKS) NewClient(*kingpin.ParseContext) error {
 	if c.Auth != "" {
 	} else if c.Auth = os.Getenv("AWS_APPLICATION_CREDENTIALS"); c.Auth == "" {
 		return errors.Errorf("no auth provided set the auth flag or the AWS_APPLICATION_CREDENTIALS env variable")
@@ -119,108 +132,29 @@ func (c *EKS) NewEKSClient(*kingpin.ParseContext) error {
 
 	c.sessionAWS = awsSess
 	c.clientEKS = eks.New(awsSess)
-	c.ctx = context.Background()
-	return nil
-}
-
-// checkDeploymentVarsAndFiles checks whether the requied deployment vars are passed.
-func (c *EKS) checkDeploymentVarsAndFiles() error {
-	reqDepVars := []string{"ZONE", "CLUSTER_NAME"}
-	for _, k := range reqDepVars {
-		if v := c.DeploymentVars[k]; v == "" {
-			return fmt.Errorf("missing required %v variable", k)
-		}
-	}
-	if len(c.DeploymentFiles) == 0 {
-		return fmt.Errorf("missing deployment file(s)")
-	}
-	return nil
-}
-
-// SetupDeploymentResources Sets up DeploymentVars and DeploymentFiles
-func (c *EKS) SetupDeploymentResources(*kingpin.ParseContext) error {
-	c.DeploymentFiles = c.DeploymentResource.DeploymentFiles
-	c.DeploymentVars = provider.MergeDeploymentVars(
-		c.DeploymentResource.DefaultDeploymentVars,
-		c.DeploymentResource.FlagDeploymentVars,
-	)
-	return nil
-}
-
-// EKSDeploymentParse parses the cluster/nodegroups deployment file and saves the result as bytes grouped by the filename.
-// Any variables passed to the cli will be replaced in the resource files following the golang text template format.
-func (c *EKS) EKSDeploymentParse(*kingpin.ParseContext) error {
-	if err := c.checkDeploymentVarsAndFiles(); err != nil {
-		return err
-	}
-
-	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
-	if err != nil {
-		return fmt.Errorf("Couldn't parse deployment files: %v", err)
-	}
-
-	c.eksResources = deploymentResource
-	return nil
-}
-
-// K8SDeploymentsParse parses the k8s objects deployment files and saves the result as k8s objects grouped by the filename.
-// Any variables passed to the cli will be replaced in the resources files following the golang text template format.
-func (c *EKS) K8SDeploymentsParse(*kingpin.ParseContext) error {
-	if err := c.checkDeploymentVarsAndFiles(); err != nil {
-		return err
-	}
-
-	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
-	if err != nil {
-		return fmt.Errorf("Couldn't parse deployment files: %v", err)
-	}
-
-	for _, deployment := range deploymentResource {
-
-		decode := scheme.Codecs.UniversalDeserializer().Decode
-		k8sObjects := make([]runtime.Object, 0)
-
-		for _, text := range strings.Split(string(deployment.Content), provider.Separator) {
-			text = strings.TrimSpace(text)
-			if len(text) == 0 {
-				continue
-			}
-
-			resource, _, err := decode([]byte(text), nil, nil)
-
-			if err != nil {
-				return errors.Wrapf(err, "decoding the resource file:%v, section:%v...", deployment.FileName, text[:100])
-			}
-			if resource == nil {
-				continue
-			}
-			k8sObjects = append(k8sObjects, resource)
-		}
-		if len(k8sObjects) > 0 {
-			c.k8sResources = append(c.k8sResources, k8sProvider.Resource{FileName: deployment.FileName, Objects: k8sObjects})
-		}
-	}
+	c.ctx = c.DeploymentResource.Context()
 	return nil
 }
 
 // ClusterCreate create a new cluster or applies changes to an existing cluster.
 func (c *EKS) ClusterCreate(*kingpin.ParseContext) error {
 	req := &eksCluster{}
-	for _, deployment := range c.eksResources {
+	for _, deployment := range c.ProviderResources {
 
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			return fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
 
 		log.Printf("Cluster create request: name:'%s'", *req.Cluster.Name)
-		_, err := c.clientEKS.CreateCluster(&req.Cluster)
+		_, err := c.clientEKS.CreateClusterWithContext(c.ctx, &req.Cluster)
 		if err != nil {
 			return fmt.Errorf("Couldn't create cluster '%v', file:%v ,err: %v", *req.Cluster.Name, deployment.FileName, err)
 		}
 
 		err = provider.RetryUntilTrue(
+			c.ctx,
 			fmt.Sprintf("creating cluster:%v", *req.Cluster.Name),
-			provider.EKSRetryCount,
+			provider.EKSBackoff,
 			func() (bool, error) { return c.clusterRunning(*req.Cluster.Name) },
 		)
 
@@ -231,14 +165,15 @@ func (c *EKS) ClusterCreate(*kingpin.ParseContext) error {
 		for _, nodegroupReq := range req.NodeGroups {
 			nodegroupReq.ClusterName = req.Cluster.Name
 			log.Printf("Nodegroup create request: NodeGroupName: '%s', ClusterName: '%s'", *nodegroupReq.NodegroupName, *req.Cluster.Name)
-			_, err := c.clientEKS.CreateNodegroup(&nodegroupReq)
+			_, err := c.clientEKS.CreateNodegroupWithContext(c.ctx, &nodegroupReq)
 			if err != nil {
 				return fmt.Errorf("Couldn't create nodegroup '%v' for cluster '%v, file:%v ,err: %v", nodegroupReq.NodegroupName, req.Cluster.Name, deployment.FileName, err)
 			}
 
 			err = provider.RetryUntilTrue(
+				c.ctx,
 				fmt.Sprintf("creating nodegroup:%s for cluster:%s", *nodegroupReq.NodegroupName, *req.Cluster.Name),
-				provider.EKSRetryCount,
+				provider.EKSBackoff,
 				func() (bool, error) { return c.nodeGroupCreated(*nodegroupReq.NodegroupName, *req.Cluster.Name) },
 			)
 
@@ -246,6 +181,11 @@ func (c *EKS) ClusterCreate(*kingpin.ParseContext) error {
 				return fmt.Errorf("creating nodegroup err:%v", err)
 			}
 		}
+
+		// The node groups of the cluster are deleted with it so only the cluster is recorded.
+		if err := c.Record(provider.StateEntry{Kind: provider.StateCluster, Name: *req.Cluster.Name}); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -253,76 +193,101 @@ func (c *EKS) ClusterCreate(*kingpin.ParseContext) error {
 // ClusterDelete deletes a eks Cluster
 func (c *EKS) ClusterDelete(*kingpin.ParseContext) error {
 	req := &eksCluster{}
-	for _, deployment := range c.eksResources {
+	for _, deployment := range c.ProviderResources {
 
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			return fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
 
-		// To delete a cluster we have to manually delete all cluster
-		log.Printf("Removing all nodepools for '%s'", *req.Cluster.Name)
-
-		// Listing all nodepools for cluster
-		reqL := &eks.ListNodegroupsInput{
-			ClusterName: req.Cluster.Name,
+		if err := c.deleteCluster(req.Cluster.Name); err != nil {
+			return fmt.Errorf("Couldn't delete cluster '%v', file:%v ,err: %v", *req.Cluster.Name, deployment.FileName, err)
 		}
+	}
+	return nil
+}
 
-		for {
-			resL, err := c.clientEKS.ListNodegroups(reqL)
-			if err != nil {
-				return fmt.Errorf("listing nodepools err:%v", err)
-			}
+// deleteCluster deletes all node groups of a cluster and then the cluster and waits for it to be gone.
+func (c *EKS) deleteCluster(name *string) error {
+	// To delete a cluster we have to manually delete all cluster
+	log.Printf("Removing all nodepools for '%s'", *name)
 
-			for _, nodegroup := range resL.Nodegroups {
-				log.Printf("Removing nodepool '%s' in cluster '%s'", *nodegroup, *req.Cluster.Name)
-
-				reqD := eks.DeleteNodegroupInput{
-					ClusterName:   req.Cluster.Name,
-					NodegroupName: nodegroup,
-				}
-				_, err := c.clientEKS.DeleteNodegroup(&reqD)
-				if err != nil {
-					return fmt.Errorf("Couldn't create nodegroup '%v' for cluster '%v ,err: %v", *nodegroup, req.Cluster.Name, err)
-				}
-
-				err = provider.RetryUntilTrue(
-					fmt.Sprintf("deleting nodegroup:%v for cluster:%v", *nodegroup, *req.Cluster.Name),
-					provider.GlobalRetryCount,
-					func() (bool, error) { return c.nodeGroupDeleted(*nodegroup, *req.Cluster.Name) },
-				)
-
-				if err != nil {
-					return fmt.Errorf("deleting nodegroup err:%v", err)
-				}
-			}
+	// Listing all nodepools for cluster
+	reqL := &eks.ListNodegroupsInput{
+		ClusterName: name,
+	}
 
-			if resL.NextToken == nil {
-				break
-			} else {
-				reqL.NextToken = resL.NextToken
+	for {
+		resL, err := c.clientEKS.ListNodegroupsWithContext(c.ctx, reqL)
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eks.ErrCodeResourceNotFoundException {
+				// The cluster is already gone.
+				return c.Forget(provider.StateEntry{Kind: provider.StateCluster, Name: *name})
 			}
+			return fmt.Errorf("listing nodepools err:%v", err)
 		}
 
-		reqD := &eks.DeleteClusterInput{
-			Name: req.Cluster.Name,
+		for _, nodegroup := range resL.Nodegroups {
+			if err := c.deleteNodeGroup(name, nodegroup); err != nil {
+				return err
+			}
 		}
 
-		log.Printf("Removing cluster '%v'", *reqD.Name)
-		_, err := c.clientEKS.DeleteCluster(reqD)
-		if err != nil {
-			return fmt.Errorf("Couldn't delete cluster '%v', file:%v ,err: %v", *req.Cluster.Name, deployment.FileName, err)
+		if resL.NextToken == nil {
+			break
+		} else {
+			reqL.NextToken = resL.NextToken
 		}
+	}
 
-		err = provider.RetryUntilTrue(
-			fmt.Sprintf("deleting cluster:%v", *reqD.Name),
-			provider.GlobalRetryCount,
-			func() (bool, error) { return c.clusterDeleted(*reqD.Name) })
+	reqD := &eks.DeleteClusterInput{
+		Name: name,
+	}
 
-		if err != nil {
-			return fmt.Errorf("removing cluster err:%v", err)
+	log.Printf("Removing cluster '%v'", *reqD.Name)
+	_, err := c.clientEKS.DeleteClusterWithContext(c.ctx, reqD)
+	if err != nil {
+		return err
+	}
+
+	err = provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("deleting cluster:%v", *reqD.Name),
+		provider.DefaultBackoff,
+		func() (bool, error) { return c.clusterDeleted(*reqD.Name) })
+
+	if err != nil {
+		return fmt.Errorf("removing cluster err:%v", err)
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateCluster, Name: *name})
+}
+
+// deleteNodeGroup deletes a node group and waits for it to be gone.
+func (c *EKS) deleteNodeGroup(clusterName, name *string) error {
+	log.Printf("Removing nodepool '%s' in cluster '%s'", *name, *clusterName)
+
+	reqD := eks.DeleteNodegroupInput{
+		ClusterName:   clusterName,
+		NodegroupName: name,
+	}
+	_, err := c.clientEKS.DeleteNodegroupWithContext(c.ctx, &reqD)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eks.ErrCodeResourceNotFoundException {
+			return c.Forget(provider.StateEntry{Kind: provider.StateNodePool, Name: *name, Cluster: *clusterName})
 		}
+		return fmt.Errorf("Couldn't delete nodegroup '%v' for cluster '%v ,err: %v", *name, *clusterName, err)
 	}
-	return nil
+
+	err = provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("deleting nodegroup:%v for cluster:%v", *name, *clusterName),
+		provider.DefaultBackoff,
+		func() (bool, error) { return c.nodeGroupDeleted(*name, *clusterName) },
+	)
+
+	if err != nil {
+		return fmt.Errorf("deleting nodegroup err:%v", err)
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateNodePool, Name: *name, Cluster: *clusterName})
 }
 
 // clusterRunning checks whether a cluster is in a active state.
@@ -330,7 +295,7 @@ func (c *EKS) clusterRunning(name string) (bool, error) {
 	req := &eks.DescribeClusterInput{
 		Name: aws.String(name),
 	}
-	clusterRes, err := c.clientEKS.DescribeCluster(req)
+	clusterRes, err := c.clientEKS.DescribeClusterWithContext(c.ctx, req)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eks.ErrCodeNotFoundException {
 			return false, nil
@@ -351,7 +316,7 @@ func (c *EKS) clusterDeleted(name string) (bool, error) {
 	req := &eks.DescribeClusterInput{
 		Name: aws.String(name),
 	}
-	clusterRes, err := c.clientEKS.DescribeCluster(req)
+	clusterRes, err := c.clientEKS.DescribeClusterWithContext(c.ctx, req)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eks.ErrCodeResourceNotFoundException {
 			return true, nil
@@ -363,10 +328,10 @@ func (c *EKS) clusterDeleted(name string) (bool, error) {
 	return false, nil
 }
 
-// NodeGroupCreate creates a new k8s nodegroup in an existing cluster.
-func (c *EKS) NodeGroupCreate(*kingpin.ParseContext) error {
+// NodesCreate creates a new k8s nodegroup in an existing cluster.
+func (c *EKS) NodesCreate(*kingpin.ParseContext) error {
 	req := &eksCluster{}
-	for _, deployment := range c.eksResources {
+	for _, deployment := range c.ProviderResources {
 
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			return fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
@@ -375,54 +340,46 @@ func (c *EKS) NodeGroupCreate(*kingpin.ParseContext) error {
 		for _, nodegroupReq := range req.NodeGroups {
 			nodegroupReq.ClusterName = req.Cluster.Name
 			log.Printf("Nodegroup create request: NodeGroupName: '%s', ClusterName: '%s'", *nodegroupReq.NodegroupName, *req.Cluster.Name)
-			_, err := c.clientEKS.CreateNodegroup(&nodegroupReq)
+			_, err := c.clientEKS.CreateNodegroupWithContext(c.ctx, &nodegroupReq)
 			if err != nil {
 				return fmt.Errorf("Couldn't create nodegroup '%s' for cluster '%s', file:%v ,err: %v", *nodegroupReq.NodegroupName, *req.Cluster.Name, deployment.FileName, err)
 			}
 
 			err = provider.RetryUntilTrue(
+				c.ctx,
 				fmt.Sprintf("creating nodegroup:%s for cluster:%s", *nodegroupReq.NodegroupName, *req.Cluster.Name),
-				provider.GlobalRetryCount,
+				provider.DefaultBackoff,
 				func() (bool, error) { return c.nodeGroupCreated(*nodegroupReq.NodegroupName, *req.Cluster.Name) },
 			)
 
 			if err != nil {
 				return fmt.Errorf("creating nodegroup err:%v", err)
 			}
+
+			if err := c.Record(provider.StateEntry{
+				Kind:    provider.StateNodePool,
+				Name:    *nodegroupReq.NodegroupName,
+				Cluster: *req.Cluster.Name,
+			}); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-// NodeGroupDelete deletes a k8s nodegroup in an existing cluster
-func (c *EKS) NodeGroupDelete(*kingpin.ParseContext) error {
+// NodesDelete deletes a k8s nodegroup in an existing cluster
+func (c *EKS) NodesDelete(*kingpin.ParseContext) error {
 	req := &eksCluster{}
-	for _, deployment := range c.eksResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			return fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
 
 		for _, nodegroupReq := range req.NodeGroups {
-			nodegroupReq.ClusterName = req.Cluster.Name
-			log.Printf("Nodegroup delete request: NodeGroupName: '%s', ClusterName: '%s'", *nodegroupReq.NodegroupName, *req.Cluster.Name)
-			reqD := eks.DeleteNodegroupInput{
-				ClusterName:   req.Cluster.Name,
-				NodegroupName: nodegroupReq.NodegroupName,
-			}
-			_, err := c.clientEKS.DeleteNodegroup(&reqD)
-			if err != nil {
-				return fmt.Errorf("Couldn't delete nodegroup '%s' for cluster '%s, file:%v ,err: %v", *nodegroupReq.NodegroupName, *req.Cluster.Name, deployment.FileName, err)
-			}
-			err = provider.RetryUntilTrue(
-				fmt.Sprintf("deleting nodegroup:%s for cluster:%s", *nodegroupReq.NodegroupName, *req.Cluster.Name),
-				provider.GlobalRetryCount,
-				func() (bool, error) { return c.nodeGroupDeleted(*nodegroupReq.NodegroupName, *req.Cluster.Name) },
-			)
-
-			if err != nil {
-				return fmt.Errorf("deleting nodegroup err:%v", err)
+			if err := c.deleteNodeGroup(req.Cluster.Name, nodegroupReq.NodegroupName); err != nil {
+				return fmt.Errorf("%v, file:%v", err, deployment.FileName)
 			}
-
 		}
 	}
 	return nil
@@ -433,7 +390,7 @@ func (c *EKS) nodeGroupCreated(nodegroupName, clusterName string) (bool, error)
 		ClusterName:   aws.String(clusterName),
 		NodegroupName: aws.String(nodegroupName),
 	}
-	nodegroupRes, err := c.clientEKS.DescribeNodegroup(req)
+	nodegroupRes, err := c.clientEKS.DescribeNodegroupWithContext(c.ctx, req)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eks.ErrCodeNotFoundException {
 			return false, nil
@@ -454,7 +411,7 @@ func (c *EKS) nodeGroupDeleted(nodegroupName, clusterName string) (bool, error)
 		ClusterName:   aws.String(clusterName),
 		NodegroupName: aws.String(nodegroupName),
 	}
-	nodegroupRes, err := c.clientEKS.DescribeNodegroup(req)
+	nodegroupRes, err := c.clientEKS.DescribeNodegroupWithContext(c.ctx, req)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == eks.ErrCodeResourceNotFoundException {
 			return true, nil
@@ -466,10 +423,10 @@ func (c *EKS) nodeGroupDeleted(nodegroupName, clusterName string) (bool, error)
 	return false, nil
 }
 
-// AllNodeGroupsRunning returns an error if at least one node pool is not running
-func (c *EKS) AllNodeGroupsRunning(*kingpin.ParseContext) error {
+// AllNodesRunning returns an error if at least one node pool is not running
+func (c *EKS) AllNodesRunning(*kingpin.ParseContext) error {
 	req := &eksCluster{}
-	for _, deployment := range c.eksResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			return fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
@@ -486,10 +443,10 @@ func (c *EKS) AllNodeGroupsRunning(*kingpin.ParseContext) error {
 	return nil
 }
 
-// AllNodeGroupsDeleted returns an error if at least one node pool is not deleted
-func (c *EKS) AllNodeGroupsDeleted(*kingpin.ParseContext) error {
+// AllNodesDeleted returns an error if at least one node pool is not deleted
+func (c *EKS) AllNodesDeleted(*kingpin.ParseContext) error {
 	req := &eksCluster{}
-	for _, deployment := range c.eksResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			return fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
@@ -506,71 +463,83 @@ func (c *EKS) AllNodeGroupsDeleted(*kingpin.ParseContext) error {
 	return nil
 }
 
-// EKSK8sToken returns aws iam authenticator token which is used to access eks k8s cluster from outside.
-func (c *EKS) EKSK8sToken(clusterName, region string) awsToken.Token {
-
-	gen, err := awsToken.NewGenerator(true, false)
-
-	if err != nil {
-		log.Fatalf("Token abstraction error: %v", err)
-	}
-
-	opts := &awsToken.GetTokenOptions{
-		ClusterID: clusterName,
-		Session:   c.sessionAWS,
-	}
-
-	tok, err := gen.GetWithOptions(opts)
-
-	if err != nil {
-		log.Fatalf("Token abstraction error: %v", err)
+// NodePoolSpecs returns the instance type and the desired number of nodes of every node group in the deployment files.
+// Only the first instance type of a node group is used and EKS uses t3.medium when none is set.
+func (c *EKS) NodePoolSpecs() ([]provider.NodePoolSpec, error) {
+	var specs []provider.NodePoolSpec
+	for _, deployment := range c.ProviderResources {
+		req := &eksCluster{}
+		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
+			return nil, fmt.Errorf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
+		}
+		for _, nodegroup := range req.NodeGroups {
+			instanceType := "t3.medium"
+			if len(nodegroup.InstanceTypes) > 0 {
+				instanceType = aws.StringValue(nodegroup.InstanceTypes[0])
+			}
+			var nodes int64
+			if nodegroup.ScalingConfig != nil {
+				nodes = aws.Int64Value(nodegroup.ScalingConfig.DesiredSize)
+			}
+			specs = append(specs, provider.NodePoolSpec{
+				Name:        aws.StringValue(nodegroup.NodegroupName),
+				MachineType: instanceType,
+				Nodes:       int(nodes),
+			})
+		}
 	}
-
-	return tok
+	return specs, nil
 }
 
-// NewK8sProvider sets the k8s provider used for deploying k8s manifests
-func (c *EKS) NewK8sProvider(*kingpin.ParseContext) error {
-
+// clusterConfig returns the rest config with the endpoint and the ca certificate of the cluster
+// and the source of the tokens used to access it.
+func (c *EKS) clusterConfig() (*rest.Config, oauth2.TokenSource, error) {
 	clusterName := c.DeploymentVars["CLUSTER_NAME"]
-	region := c.DeploymentVars["ZONE"]
 
 	req := &eks.DescribeClusterInput{
 		Name: &clusterName,
 	}
 
-	rep, err := c.clientEKS.DescribeCluster(req)
+	rep, err := c.clientEKS.DescribeClusterWithContext(c.ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to get cluster details: %v", err)
+		return nil, nil, fmt.Errorf("failed to get cluster details: %v", err)
 	}
 
-	arnRole := *rep.Cluster.Arn
-
 	caCert, err := base64.StdEncoding.DecodeString(*rep.Cluster.CertificateAuthority.Data)
 	if err != nil {
-		return fmt.Errorf("failed to decode certificate: %v", err.Error())
+		return nil, nil, fmt.Errorf("failed to decode certificate: %v", err.Error())
 	}
 
-	cluster := clientcmdapi.NewCluster()
-	cluster.CertificateAuthorityData = []byte(caCert)
-	cluster.Server = *rep.Cluster.Endpoint
+	gen, err := awsToken.NewGenerator(true, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("token generator error: %v", err)
+	}
+	tokens := newTokenSource(gen, &awsToken.GetTokenOptions{
+		ClusterID: clusterName,
+		Session:   c.sessionAWS,
+	})
+	// Check the credentials before making any requests.
+	if _, err := tokens.Token(); err != nil {
+		return nil, nil, err
+	}
 
-	clusterContext := clientcmdapi.NewContext()
-	clusterContext.Cluster = arnRole
-	clusterContext.AuthInfo = arnRole
+	config := &rest.Config{
+		Host:            *rep.Cluster.Endpoint,
+		TLSClientConfig: rest.TLSClientConfig{CAData: caCert},
+	}
+	return config, tokens, nil
+}
 
-	authInfo := clientcmdapi.NewAuthInfo()
-	authInfo.Token = c.EKSK8sToken(clusterName, region).Token
+// NewK8sProvider sets the k8s provider used for deploying k8s manifests
+func (c *EKS) NewK8sProvider(*kingpin.ParseContext) error {
+	config, tokens, err := c.clusterConfig()
+	if err != nil {
+		return err
+	}
+	// The token is refreshed before it expires so that long running commands keep working.
+	config.WrapTransport = transport.TokenSourceWrapTransport(tokens)
 
-	config := clientcmdapi.NewConfig()
-	config.AuthInfos[arnRole] = authInfo
-	config.Contexts[arnRole] = clusterContext
-	config.Clusters[arnRole] = cluster
-	config.CurrentContext = arnRole
-	config.Kind = "Config"
-	config.APIVersion = "v1"
-
-	c.k8sProvider, err = k8sProvider.New(c.ctx, config)
+	c.K8sProvider, err = k8sProvider.NewWithRESTConfig(c.ctx, config)
 	if err != nil {
 		return fmt.Errorf("k8s provider error %v", err)
 	}
@@ -578,28 +547,57 @@ func (c *EKS) NewK8sProvider(*kingpin.ParseContext) error {
 	return nil
 }
 
-// ResourceApply calls k8s.ResourceApply to apply the k8s objects in the manifest files.
-func (c *EKS) ResourceApply(*kingpin.ParseContext) error {
-	if err := c.k8sProvider.ResourceApply(c.k8sResources); err != nil {
-		return fmt.Errorf("error while applying a resource err: %v", err)
+// Kubeconfig returns the kubeconfig of the cluster.
+// kubectl gets a new token for every request with `aws eks get-token`,
+// which uses the credentials of the aws cli, not the ones given to infra.
+func (c *EKS) Kubeconfig() ([]byte, error) {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return nil, err
 	}
-	return nil
+	restConfig, _, err := c.clusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubeconfig(c.DeploymentVars["CLUSTER_NAME"], c.DeploymentVars["ZONE"], restConfig)
 }
 
-// ResourceDelete calls k8s.ResourceDelete to apply the k8s objects in the manifest files.
-func (c *EKS) ResourceDelete(*kingpin.ParseContext) error {
-	if err := c.k8sProvider.ResourceDelete(c.k8sResources); err != nil {
-		return fmt.Errorf("error while deleting objects from a manifest file err: %v", err)
+// kubeconfig returns a kubeconfig for the cluster with an exec credential entry that runs `aws eks get-token`.
+func kubeconfig(name, region string, restConfig *rest.Config) ([]byte, error) {
+	cluster := clientcmdapi.NewCluster()
+	cluster.CertificateAuthorityData = restConfig.CAData
+	cluster.Server = restConfig.Host
+
+	clusterContext := clientcmdapi.NewContext()
+	clusterContext.Cluster = name
+	clusterContext.AuthInfo = name
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Exec = &clientcmdapi.ExecConfig{
+		APIVersion: "client.authentication.k8s.io/v1beta1",
+		Command:    "aws",
+		Args:       []string{"eks", "get-token", "--cluster-name", name, "--region", region},
 	}
-	return nil
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = cluster
+	config.Contexts[name] = clusterContext
+	config.AuthInfos[name] = authInfo
+	config.CurrentContext = name
+	return clientcmd.Write(*config)
 }
 
-// GetDeploymentVars shows deployment variables.
-func (c *EKS) GetDeploymentVars(*kingpin.ParseContext) error {
-	fmt.Print("-------------------\n   DeploymentVars   \n------------------- \n")
-	for key, value := range c.DeploymentVars {
-		fmt.Println(key, " : ", value)
+// Destroy deletes a cluster, node group or namespace recorded in the state file.
+func (c *EKS) Destroy(e provider.StateEntry) error {
+	switch e.Kind {
+	case provider.StateCluster:
+		return c.deleteCluster(aws.String(e.Name))
+	case provider.StateNodePool:
+		return c.deleteNodeGroup(aws.String(e.Cluster), aws.String(e.Name))
+	case provider.StateNamespace:
+		if err := c.NewK8sProvider(nil); err != nil {
+			return err
+		}
+		return c.K8sProvider.NamespaceDelete(e.Name)
 	}
-
-	return nil
+	return fmt.Errorf("unknown state entry kind %q", e.Kind)
 }
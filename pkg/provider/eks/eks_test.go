@@ -0,0 +1,52 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eks
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestKubeconfig(t *testing.T) {
+	content, err := kubeconfig("prombench", "us-east-1", &rest.Config{
+		Host:            "https://prombench.eks.amazonaws.com",
+		TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := clientcmd.Load(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.CurrentContext != "prombench" {
+		t.Fatalf("expected the prombench context, got: %v", config.CurrentContext)
+	}
+	if c := config.Clusters["prombench"]; c.Server != "https://prombench.eks.amazonaws.com" || string(c.CertificateAuthorityData) != "ca" {
+		t.Errorf("unexpected cluster: %v", c)
+	}
+	// The token expires after 15 minutes so kubectl gets a new one for every request instead.
+	auth := config.AuthInfos["prombench"]
+	if auth.Token != "" {
+		t.Errorf("expected no static token, got: %v", auth.Token)
+	}
+	expected := []string{"eks", "get-token", "--cluster-name", "prombench", "--region", "us-east-1"}
+	if auth.Exec == nil || auth.Exec.Command != "aws" || !reflect.DeepEqual(expected, auth.Exec.Args) {
+		t.Errorf("expected the credentials from aws %v, got: %v", expected, auth.Exec)
+	}
+}
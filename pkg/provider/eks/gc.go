@@ -0,0 +1,69 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eks
+
+import (
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	eks "github.com/aws/aws-sdk-go/service/eks"
+	"github.com/pkg/errors"
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+// GC deletes the benchmark node groups and namespaces of the cluster that are older than the options allow.
+func (c *EKS) GC(opts provider.GCOptions) error {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return err
+	}
+	if err := c.NewK8sProvider(nil); err != nil {
+		return err
+	}
+	objects, err := c.K8sProvider.BenchmarkNamespaces()
+	if err != nil {
+		return err
+	}
+
+	cluster := aws.String(c.DeploymentVars["CLUSTER_NAME"])
+	var names []*string
+	err = c.clientEKS.ListNodegroupsPagesWithContext(c.ctx, &eks.ListNodegroupsInput{ClusterName: cluster}, func(page *eks.ListNodegroupsOutput, _ bool) bool {
+		names = append(names, page.Nodegroups...)
+		return true
+	})
+	if err != nil {
+		return errors.Wrapf(err, "listing the nodegroups of cluster:%v", *cluster)
+	}
+	for _, name := range names {
+		if _, ok := provider.NewBenchmarkObject(provider.StateNodePool, *name, time.Time{}); !ok {
+			continue
+		}
+		rep, err := c.clientEKS.DescribeNodegroupWithContext(c.ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   cluster,
+			NodegroupName: name,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "getting nodegroup:%v", *name)
+		}
+		o, _ := provider.NewBenchmarkObject(provider.StateNodePool, *name, aws.TimeValue(rep.Nodegroup.CreatedAt))
+		objects = append(objects, o)
+	}
+
+	return provider.CollectGarbage(os.Stdout, objects, opts, func(o provider.BenchmarkObject) error {
+		if o.Kind == provider.StateNamespace {
+			return c.GCNamespace(o.Name)
+		}
+		return c.deleteNodeGroup(cluster, aws.String(o.Name))
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eks
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	awsToken "sigs.k8s.io/aws-iam-authenticator/pkg/token"
+)
+
+// tokenExpiryLeeway is how long before its expiration a token is replaced
+// so that a request doesn't reach the api server with an expired token.
+const tokenExpiryLeeway = time.Minute
+
+// tokenSource generates the aws-iam-authenticator tokens used to access the EKS cluster.
+type tokenSource struct {
+	gen  awsToken.Generator
+	opts *awsToken.GetTokenOptions
+}
+
+// Token generates a new token.
+func (ts *tokenSource) Token() (*oauth2.Token, error) {
+	tok, err := ts.gen.GetWithOptions(ts.opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating the eks token")
+	}
+	return &oauth2.Token{
+		AccessToken: tok.Token,
+		TokenType:   "Bearer",
+		Expiry:      tok.Expiration.Add(-tokenExpiryLeeway),
+	}, nil
+}
+
+// newTokenSource returns a token source that reuses the token of the cluster until it is about to expire
+// and then generates a new one. The tokens expire after 15 minutes which is shorter than
+// a resource apply that waits for many objects to be ready.
+func newTokenSource(gen awsToken.Generator, opts *awsToken.GetTokenOptions) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &tokenSource{gen: gen, opts: opts})
+}
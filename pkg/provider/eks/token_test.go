@@ -0,0 +1,80 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/transport"
+	awsToken "sigs.k8s.io/aws-iam-authenticator/pkg/token"
+)
+
+// fakeGenerator returns a new token on every call which expires after ttl.
+type fakeGenerator struct {
+	awsToken.Generator
+	ttl   time.Duration
+	calls int
+}
+
+func (g *fakeGenerator) GetWithOptions(opts *awsToken.GetTokenOptions) (awsToken.Token, error) {
+	g.calls++
+	return awsToken.Token{
+		Token:      fmt.Sprintf("k8s-aws-v1.%v.%v", opts.ClusterID, g.calls),
+		Expiration: time.Now().Add(g.ttl),
+	}, nil
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		ttl      time.Duration
+		expected []string
+	}{
+		{
+			// Valid tokens are reused.
+			ttl:      15 * time.Minute,
+			expected: []string{"Bearer k8s-aws-v1.test.1", "Bearer k8s-aws-v1.test.1"},
+		},
+		{
+			// Tokens that expire within the leeway are replaced.
+			ttl:      tokenExpiryLeeway / 2,
+			expected: []string{"Bearer k8s-aws-v1.test.1", "Bearer k8s-aws-v1.test.2"},
+		},
+	} {
+		auth = nil
+		gen := &fakeGenerator{ttl: tc.ttl}
+		client := &http.Client{
+			Transport: transport.TokenSourceWrapTransport(newTokenSource(gen, &awsToken.GetTokenOptions{ClusterID: "test"}))(http.DefaultTransport),
+		}
+		for range tc.expected {
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+		if fmt.Sprint(auth) != fmt.Sprint(tc.expected) {
+			t.Errorf("ttl %v: expected authorization headers %v, got %v", tc.ttl, tc.expected, auth)
+		}
+	}
+}
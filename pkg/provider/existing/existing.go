@@ -0,0 +1,154 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package existing
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+	k8sProvider "github.com/prometheus/test-infra/pkg/provider/k8s"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func init() {
+	provider.Register("k8s", "Existing k8s cluster from a kubeconfig context, like an on-prem cluster. The cluster and its nodes are not managed.", func(dr *provider.DeploymentResource) provider.Provider {
+		return New(dr)
+	})
+}
+
+var (
+	_ provider.NodePoolProvider   = (*Existing)(nil)
+	_ provider.ResourceProvider   = (*Existing)(nil)
+	_ provider.KubeconfigProvider = (*Existing)(nil)
+	_ provider.Destroyer          = (*Existing)(nil)
+)
+
+// Existing deploys the k8s manifests to an existing cluster.
+// It doesn't manage the cluster or its nodes, the node pool commands only check
+// that the cluster has the nodes that the node pool files expect.
+type Existing struct {
+	k8sProvider.Base
+
+	// The kubeconfig file, the default loading rules of kubectl are used when empty.
+	kubeconfig string
+	// The kubeconfig context, the current context is used when empty.
+	context string
+}
+
+// New is the Existing constructor.
+func New(dr *provider.DeploymentResource) *Existing {
+	return &Existing{
+		Base: k8sProvider.NewBase(dr),
+	}
+}
+
+// Flags adds the flags that select the cluster.
+func (c *Existing) Flags(cmd *kingpin.CmdClause) {
+	cmd.Flag("kubeconfig", "kubeconfig file of the cluster. Defaults to the KUBECONFIG env variable or ~/.kube/config like kubectl.").
+		PlaceHolder("file").
+		StringVar(&c.kubeconfig)
+	cmd.Flag("context", "kubeconfig context of the cluster. Defaults to the current context.").
+		StringVar(&c.context)
+}
+
+// NewK8sProvider sets the k8s provider used for deploying k8s manifests.
+func (c *Existing) NewK8sProvider(*kingpin.ParseContext) error {
+	config, err := k8sProvider.LoadKubeconfig(c.kubeconfig, c.context)
+	if err != nil {
+		return err
+	}
+	log.Printf("Using the kubeconfig context %q", config.CurrentContext)
+
+	// The selected context is recorded with the namespaces so destroy uses the same cluster
+	// even when the current context has changed since.
+	c.StateSettings = map[string]string{"context": config.CurrentContext}
+	if c.kubeconfig != "" {
+		kubeconfig, err := filepath.Abs(c.kubeconfig)
+		if err != nil {
+			return err
+		}
+		c.StateSettings["kubeconfig"] = kubeconfig
+	}
+
+	c.K8sProvider, err = k8sProvider.New(c.DeploymentResource.Context(), config)
+	return err
+}
+
+// Destroy deletes a namespace recorded in the state file.
+func (c *Existing) Destroy(e provider.StateEntry) error {
+	if e.Kind != provider.StateNamespace {
+		return fmt.Errorf("unknown state entry kind %q", e.Kind)
+	}
+	c.kubeconfig = e.Settings["kubeconfig"]
+	c.context = e.Settings["context"]
+	if err := c.NewK8sProvider(nil); err != nil {
+		return err
+	}
+	return c.K8sProvider.NamespaceDelete(e.Name)
+}
+
+// Kubeconfig returns the selected context of the kubeconfig with everything it needs embedded.
+func (c *Existing) Kubeconfig() ([]byte, error) {
+	config, err := k8sProvider.LoadKubeconfig(c.kubeconfig, c.context)
+	if err != nil {
+		return nil, err
+	}
+	return k8sProvider.MinifyKubeconfig(config)
+}
+
+// NodesCreate doesn't create any nodes, it checks that the cluster has the nodes of the node pool files.
+func (c *Existing) NodesCreate(*kingpin.ParseContext) error {
+	return c.checkNodes()
+}
+
+// NodesDelete doesn't delete any nodes as they are not managed by the provider.
+func (c *Existing) NodesDelete(*kingpin.ParseContext) error {
+	log.Print("The nodes of an existing cluster are not deleted")
+	return nil
+}
+
+// AllNodesRunning checks that the cluster has the nodes of the node pool files.
+func (c *Existing) AllNodesRunning(*kingpin.ParseContext) error {
+	return c.checkNodes()
+}
+
+// AllNodesDeleted returns right away as the nodes are never deleted.
+func (c *Existing) AllNodesDeleted(*kingpin.ParseContext) error {
+	return nil
+}
+
+// checkNodes returns an error when no node of the cluster has one of the node-name labels that the node pool files define.
+func (c *Existing) checkNodes() error {
+	names, err := k8sProvider.NodeNames(c.ProviderResources)
+	if err != nil {
+		return err
+	}
+	if c.K8sProvider == nil {
+		if err := c.NewK8sProvider(nil); err != nil {
+			return err
+		}
+	}
+	missing, err := c.K8sProvider.MissingNodeNames(names)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no nodes with the %v label(s): %v", k8sProvider.NodeNameLabel, strings.Join(missing, ", "))
+	}
+	log.Printf("All %d %v label(s) are present", len(names), k8sProvider.NodeNameLabel)
+	return nil
+}
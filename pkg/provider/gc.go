@@ -0,0 +1,125 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GCOptions changes the behaviour of GarbageCollector.GC.
+type GCOptions struct {
+	// OlderThan is the age after which the benchmark objects are deleted.
+	OlderThan time.Duration
+	// OpenPRs are the numbers of the pull requests that are still open,
+	// their benchmarks are kept whatever their age.
+	OpenPRs []string
+	// DryRun only reports the objects that would be deleted.
+	DryRun bool
+}
+
+// The names of the node pools and namespaces created for the benchmark of a pull request.
+var (
+	benchmarkNodePoolName  = regexp.MustCompile(`^(?:prometheus|nodes)-(\d+)$`)
+	benchmarkNamespaceName = regexp.MustCompile(`^prombench-(\d+)$`)
+)
+
+// BenchmarkObject is a node pool or namespace created for the benchmark of a pull request.
+type BenchmarkObject struct {
+	// Kind is StateNodePool or StateNamespace.
+	Kind string
+	Name string
+	// PR is the number of the pull request from the name of the object.
+	PR string
+	// Created is zero when the age of the object is not known.
+	Created time.Time
+}
+
+// NewBenchmarkObject returns the benchmark object with the given name,
+// false when the name doesn't follow the naming of the benchmark node pools or namespaces.
+func NewBenchmarkObject(kind, name string, created time.Time) (BenchmarkObject, bool) {
+	re := benchmarkNodePoolName
+	if kind == StateNamespace {
+		re = benchmarkNamespaceName
+	}
+	m := re.FindStringSubmatch(name)
+	if m == nil {
+		return BenchmarkObject{}, false
+	}
+	return BenchmarkObject{Kind: kind, Name: name, PR: m[1], Created: created}, true
+}
+
+// CollectGarbage writes a report of the benchmark objects and deletes the stale ones with del.
+// The namespaces are deleted before the node pools that their pods run on.
+// With DryRun it only writes the report and returns ErrPendingChanges when any object is stale.
+func CollectGarbage(w io.Writer, objects []BenchmarkObject, opts GCOptions, del func(BenchmarkObject) error) error {
+	stale := staleObjects(w, objects, opts, time.Now())
+	if opts.DryRun {
+		if len(stale) > 0 {
+			return ErrPendingChanges
+		}
+		return nil
+	}
+	for _, o := range stale {
+		if err := del(o); err != nil {
+			return errors.Wrapf(err, "deleting %v:%v", o.Kind, o.Name)
+		}
+	}
+	return nil
+}
+
+// staleObjects writes why every object is deleted or kept
+// and returns the deleted ones, namespaces first.
+func staleObjects(w io.Writer, objects []BenchmarkObject, opts GCOptions, now time.Time) []BenchmarkObject {
+	open := map[string]bool{}
+	for _, prs := range opts.OpenPRs {
+		for _, pr := range strings.Split(prs, ",") {
+			open[strings.TrimPrefix(strings.TrimSpace(pr), "#")] = true
+		}
+	}
+
+	objects = append([]BenchmarkObject{}, objects...)
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].Kind != objects[j].Kind {
+			return objects[i].Kind == StateNamespace
+		}
+		return objects[i].Name < objects[j].Name
+	})
+
+	var stale []BenchmarkObject
+	for _, o := range objects {
+		var reason string
+		switch age := now.Sub(o.Created).Truncate(time.Minute); {
+		case open[o.PR]:
+			reason = fmt.Sprintf("PR %v is open", o.PR)
+		case o.Created.IsZero():
+			reason = "unknown age"
+		case age < opts.OlderThan:
+			reason = fmt.Sprintf("age %v", age)
+		default:
+			stale = append(stale, o)
+			fmt.Fprintf(w, "- %-6v %v:%v (age %v)\n", "delete", o.Kind, o.Name, age)
+			continue
+		}
+		fmt.Fprintf(w, "= %-6v %v:%v (%v)\n", "keep", o.Kind, o.Name, reason)
+	}
+	fmt.Fprintf(w, "\nGC: %d to delete older than %v, %d kept.\n", len(stale), opts.OlderThan, len(objects)-len(stale))
+	return stale
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewBenchmarkObject(t *testing.T) {
+	for _, tc := range []struct {
+		kind, name string
+		pr         string
+		ok         bool
+	}{
+		{kind: StateNodePool, name: "prometheus-1234", pr: "1234", ok: true},
+		{kind: StateNodePool, name: "nodes-1234", pr: "1234", ok: true},
+		{kind: StateNodePool, name: "main-node"},
+		{kind: StateNodePool, name: "prombench-1234"},
+		{kind: StateNamespace, name: "prombench-1234", pr: "1234", ok: true},
+		{kind: StateNamespace, name: "prombench-test"},
+		{kind: StateNamespace, name: "nodes-1234"},
+	} {
+		o, ok := NewBenchmarkObject(tc.kind, tc.name, time.Time{})
+		if ok != tc.ok || o.PR != tc.pr {
+			t.Errorf("%v %v: expected %v and PR %q, got %v and PR %q", tc.kind, tc.name, tc.ok, tc.pr, ok, o.PR)
+		}
+	}
+}
+
+func TestStaleObjects(t *testing.T) {
+	now := time.Date(2020, 7, 4, 10, 0, 0, 0, time.UTC)
+	object := func(kind, name string, age time.Duration) BenchmarkObject {
+		var created time.Time
+		if age > 0 {
+			created = now.Add(-age)
+		}
+		o, ok := NewBenchmarkObject(kind, name, created)
+		if !ok {
+			t.Fatalf("%v is not a benchmark object", name)
+		}
+		return o
+	}
+	objects := []BenchmarkObject{
+		object(StateNodePool, "prometheus-1", 80*time.Hour),
+		object(StateNodePool, "nodes-1", 80*time.Hour),
+		object(StateNodePool, "prometheus-2", 2*time.Hour),
+		object(StateNodePool, "prometheus-3", 0),
+		object(StateNodePool, "prometheus-4", 100*time.Hour),
+		object(StateNamespace, "prombench-1", 79*time.Hour+30*time.Second),
+		object(StateNamespace, "prombench-5", 100*time.Hour),
+	}
+
+	var report strings.Builder
+	stale := staleObjects(&report, objects, GCOptions{OlderThan: 72 * time.Hour, OpenPRs: []string{"4, #5"}}, now)
+
+	var names []string
+	for _, o := range stale {
+		names = append(names, o.Name)
+	}
+	if expected := []string{"prombench-1", "nodes-1", "prometheus-1"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("\nexpect deleted %v\ngot %v", expected, names)
+	}
+
+	expected := `- delete namespace:prombench-1 (age 79h0m0s)
+= keep   namespace:prombench-5 (PR 5 is open)
+- delete nodepool:nodes-1 (age 80h0m0s)
+- delete nodepool:prometheus-1 (age 80h0m0s)
+= keep   nodepool:prometheus-2 (age 2h0m0s)
+= keep   nodepool:prometheus-3 (unknown age)
+= keep   nodepool:prometheus-4 (PR 4 is open)
+
+GC: 3 to delete older than 72h0m0s, 4 kept.
+`
+	if report.String() != expected {
+		t.Errorf("expected report:\n%v\ngot:\n%v", expected, report.String())
+	}
+}
+
+func TestCollectGarbageDryRun(t *testing.T) {
+	o, _ := NewBenchmarkObject(StateNamespace, "prombench-1", time.Now().Add(-time.Hour))
+	del := func(BenchmarkObject) error {
+		t.Error("nothing should be deleted with a dry run")
+		return nil
+	}
+	var report strings.Builder
+	if err := CollectGarbage(&report, []BenchmarkObject{o}, GCOptions{DryRun: true}, del); err != ErrPendingChanges {
+		t.Errorf("expected ErrPendingChanges when objects are stale, got %v", err)
+	}
+	if err := CollectGarbage(&report, []BenchmarkObject{o}, GCOptions{OlderThan: 2 * time.Hour, DryRun: true}, del); err != nil {
+		t.Errorf("expected no error when nothing is stale, got %v", err)
+	}
+}
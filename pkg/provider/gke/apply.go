@@ -0,0 +1,405 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gke
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/test-infra/pkg/provider"
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	yamlGo "gopkg.in/yaml.v2"
+)
+
+// nodePoolLabel is the label that GKE sets on the k8s nodes with the name of their node pool.
+const nodePoolLabel = "cloud.google.com/gke-nodepool"
+
+// nodePoolAction is what applying a node pool deployment file does to the live node pool.
+type nodePoolAction string
+
+// The possible node pool actions.
+const (
+	nodePoolCreate      nodePoolAction = "create"
+	nodePoolUpdate      nodePoolAction = "update"
+	nodePoolRecreate    nodePoolAction = "recreate"
+	nodePoolUnsupported nodePoolAction = "unsupported"
+	nodePoolUnchanged   nodePoolAction = "unchanged"
+)
+
+// nodePoolDiff is a field that differs between the live and the desired node pool.
+type nodePoolDiff struct {
+	Path    string
+	Live    string
+	Desired string
+	// Unsupported is set for the fields that GKE can update in place, but not the version of its api used here.
+	Unsupported bool
+}
+
+// nodePoolPlan is the change that applying a node pool from the deployment files makes.
+type nodePoolPlan struct {
+	Action nodePoolAction
+	Diff   []nodePoolDiff
+
+	desired *containerpb.NodePool
+	live    *containerpb.NodePool
+	// The updates that are done in place.
+	upgrade, autoscaling, resize bool
+}
+
+// diffNodePool compares the desired node pool with the live one, nil when it doesn't exist.
+// liveSize is the current number of nodes per zone, the initial node count of the live node pool
+// doesn't change when the node pool is resized.
+//
+// The node version, image type, autoscaling and size are updated in place.
+// A change of the immutable fields, like the machine type or the disk, needs the node pool to be recreated.
+// The labels and the taints can't be updated in place with this version of the GKE api,
+// a change of these is unsupported and the node pool is only recreated when asked for.
+// GKE sets defaults for the fields that are not set so only the fields set in the deployment files are compared,
+// except for the ones where not set is a meaningful value like no labels or taints.
+func diffNodePool(desired, live *containerpb.NodePool, liveSize int32) nodePoolPlan {
+	p := nodePoolPlan{desired: desired, live: live}
+	if live == nil {
+		p.Action = nodePoolCreate
+		return p
+	}
+	add := func(path string, live, desired interface{}) {
+		p.Diff = append(p.Diff, nodePoolDiff{Path: path, Live: fmt.Sprint(live), Desired: fmt.Sprint(desired)})
+	}
+
+	dc, lc := desired.GetConfig(), live.GetConfig()
+	if dc.GetMachineType() != "" && dc.GetMachineType() != lc.GetMachineType() {
+		add("config.machinetype", lc.GetMachineType(), dc.GetMachineType())
+	}
+	if dc.GetDiskSizeGb() != 0 && dc.GetDiskSizeGb() != lc.GetDiskSizeGb() {
+		add("config.disksizegb", lc.GetDiskSizeGb(), dc.GetDiskSizeGb())
+	}
+	if dc.GetDiskType() != "" && dc.GetDiskType() != lc.GetDiskType() {
+		add("config.disktype", lc.GetDiskType(), dc.GetDiskType())
+	}
+	if dc.GetLocalSsdCount() != lc.GetLocalSsdCount() {
+		add("config.localssdcount", lc.GetLocalSsdCount(), dc.GetLocalSsdCount())
+	}
+	if dc.GetPreemptible() != lc.GetPreemptible() {
+		add("config.preemptible", lc.GetPreemptible(), dc.GetPreemptible())
+	}
+	recreate := len(p.Diff) > 0
+
+	var unsupported bool
+	if l, d := formatLabels(lc.GetLabels()), formatLabels(dc.GetLabels()); l != d {
+		p.Diff = append(p.Diff, nodePoolDiff{Path: "config.labels", Live: l, Desired: d, Unsupported: true})
+		unsupported = true
+	}
+	if l, d := formatTaints(lc.GetTaints()), formatTaints(dc.GetTaints()); l != d {
+		p.Diff = append(p.Diff, nodePoolDiff{Path: "config.taints", Live: l, Desired: d, Unsupported: true})
+		unsupported = true
+	}
+
+	if dc.GetImageType() != "" && !strings.EqualFold(dc.GetImageType(), lc.GetImageType()) {
+		add("config.imagetype", lc.GetImageType(), dc.GetImageType())
+		p.upgrade = true
+	}
+	if desired.GetVersion() != "" && desired.GetVersion() != live.GetVersion() {
+		add("version", live.GetVersion(), desired.GetVersion())
+		p.upgrade = true
+	}
+	if l, d := formatAutoscaling(live.GetAutoscaling()), formatAutoscaling(desired.GetAutoscaling()); l != d {
+		add("autoscaling", l, d)
+		p.autoscaling = true
+	}
+	if !desired.GetAutoscaling().GetEnabled() && desired.GetInitialNodeCount() != liveSize {
+		add("size", liveSize, desired.GetInitialNodeCount())
+		p.resize = true
+	}
+
+	switch {
+	case recreate:
+		p.Action = nodePoolRecreate
+	case unsupported:
+		p.Action = nodePoolUnsupported
+	case len(p.Diff) > 0:
+		p.Action = nodePoolUpdate
+	default:
+		p.Action = nodePoolUnchanged
+	}
+	return p
+}
+
+func formatLabels(labels map[string]string) string {
+	var l []string
+	for k, v := range labels {
+		l = append(l, k+"="+v)
+	}
+	sort.Strings(l)
+	return "[" + strings.Join(l, " ") + "]"
+}
+
+func formatTaints(taints []*containerpb.NodeTaint) string {
+	var t []string
+	for _, taint := range taints {
+		t = append(t, fmt.Sprintf("%v=%v:%v", taint.Key, taint.Value, taint.Effect))
+	}
+	sort.Strings(t)
+	return "[" + strings.Join(t, " ") + "]"
+}
+
+func formatAutoscaling(a *containerpb.NodePoolAutoscaling) string {
+	if !a.GetEnabled() {
+		return "disabled"
+	}
+	return fmt.Sprintf("%d-%d nodes", a.GetMinNodeCount(), a.GetMaxNodeCount())
+}
+
+// printNodePoolPlans writes a summary of the plans in the same format as the resource plan
+// and returns whether any of the node pools would be changed.
+func printNodePoolPlans(w io.Writer, plans []nodePoolPlan) bool {
+	counts := map[nodePoolAction]int{}
+	for _, p := range plans {
+		counts[p.Action]++
+
+		var symbol string
+		switch p.Action {
+		case nodePoolCreate:
+			symbol = "+"
+		case nodePoolUpdate:
+			symbol = "~"
+		case nodePoolRecreate, nodePoolUnsupported:
+			symbol = "!"
+		default:
+			symbol = "="
+		}
+		fmt.Fprintf(w, "%v %-11v nodepool:%v\n", symbol, p.Action, p.desired.Name)
+		for _, d := range p.Diff {
+			fmt.Fprintf(w, "    ~ %v: %v => %v", d.Path, d.Live, d.Desired)
+			if d.Unsupported && p.Action == nodePoolUnsupported {
+				fmt.Fprint(w, " (can't be updated in place, recreate the node pool with --recreate)")
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to recreate, %d unsupported, %d unchanged.\n",
+		counts[nodePoolCreate], counts[nodePoolUpdate], counts[nodePoolRecreate], counts[nodePoolUnsupported], counts[nodePoolUnchanged])
+
+	return counts[nodePoolCreate]+counts[nodePoolUpdate]+counts[nodePoolRecreate]+counts[nodePoolUnsupported] > 0
+}
+
+// NodesApply compares the node pools of the deployment files with the live ones and
+// creates the missing ones, updates the changed ones and recreates the ones with a change of an immutable field.
+// The node pools with an unsupported change are only recreated with opts.Recreate, otherwise nothing is applied.
+// The live node pools that are not in the deployment files are left alone.
+// With opts.DryRun it only prints the changes and returns provider.ErrPendingChanges when there are any.
+func (c *GKE) NodesApply(opts provider.NodesApplyOptions) error {
+	var pending bool
+	for _, deployment := range c.ProviderResources {
+		reqC := &containerpb.CreateClusterRequest{}
+		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
+			return errors.Errorf("error parsing the cluster deployment file %s:%v", deployment.FileName, err)
+		}
+
+		var (
+			plans []nodePoolPlan
+			sizes map[string]int
+		)
+		for _, node := range reqC.Cluster.NodePools {
+			live, err := c.clientGKE.GetNodePool(c.ctx, &containerpb.GetNodePoolRequest{
+				ProjectId:  reqC.ProjectId,
+				Zone:       reqC.Zone,
+				ClusterId:  reqC.Cluster.Name,
+				NodePoolId: node.Name,
+			})
+			if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+				live, err = nil, nil
+			}
+			if err != nil {
+				return errors.Wrapf(err, "getting nodepool:%v", node.Name)
+			}
+
+			var size int32
+			if live != nil {
+				if sizes == nil {
+					if sizes, err = c.nodePoolSizes(); err != nil {
+						return err
+					}
+				}
+				// The node count of a node pool is per zone and there is an instance group in every zone.
+				zones := len(live.InstanceGroupUrls)
+				if zones == 0 {
+					zones = 1
+				}
+				size = int32(sizes[node.Name] / zones)
+			}
+			plans = append(plans, diffNodePool(node, live, size))
+		}
+
+		if printNodePoolPlans(os.Stdout, plans) {
+			pending = true
+		}
+		if opts.DryRun {
+			continue
+		}
+		for i, p := range plans {
+			if p.Action != nodePoolUnsupported {
+				continue
+			}
+			if !opts.Recreate {
+				return fmt.Errorf("the labels or taints of nodepool:%v can't be updated in place, file:%v, run again with --recreate to delete and create the node pool", p.desired.Name, deployment.FileName)
+			}
+			plans[i].Action = nodePoolRecreate
+		}
+		for _, p := range plans {
+			if err := c.applyNodePool(reqC, p); err != nil {
+				return fmt.Errorf("Couldn't apply cluster nodepool '%v', file:%v ,err: %v", p.desired.Name, deployment.FileName, err)
+			}
+		}
+	}
+	if opts.DryRun && pending {
+		return provider.ErrPendingChanges
+	}
+	return nil
+}
+
+// nodePoolSizes returns the number of k8s nodes of every node pool.
+func (c *GKE) nodePoolSizes() (map[string]int, error) {
+	if c.K8sProvider == nil {
+		if err := c.NewK8sProvider(nil); err != nil {
+			return nil, err
+		}
+	}
+	return c.K8sProvider.NodeCounts(nodePoolLabel)
+}
+
+// applyNodePool makes the changes of the plan to the node pool.
+func (c *GKE) applyNodePool(reqC *containerpb.CreateClusterRequest, p nodePoolPlan) error {
+	reqN := &containerpb.CreateNodePoolRequest{
+		ProjectId: reqC.ProjectId,
+		Zone:      reqC.Zone,
+		ClusterId: reqC.Cluster.Name,
+		NodePool:  p.desired,
+	}
+	switch p.Action {
+	case nodePoolCreate:
+		return c.createNodePool(reqN)
+	case nodePoolRecreate:
+		err := c.deleteNodePool(&containerpb.DeleteNodePoolRequest{
+			ProjectId:  reqC.ProjectId,
+			Zone:       reqC.Zone,
+			ClusterId:  reqC.Cluster.Name,
+			NodePoolId: p.desired.Name,
+		})
+		if err != nil {
+			return err
+		}
+		return c.createNodePool(reqN)
+	case nodePoolUnchanged:
+		return nil
+	}
+
+	name := p.desired.Name
+	if p.upgrade {
+		version, imageType := p.desired.Version, p.desired.GetConfig().GetImageType()
+		if version == "" {
+			version = p.live.Version
+		}
+		if imageType == "" {
+			imageType = p.live.GetConfig().GetImageType()
+		}
+		err := c.nodePoolOperation(reqN, "updating nodepool version and image type", func() (*containerpb.Operation, error) {
+			return c.clientGKE.UpdateNodePool(c.ctx, &containerpb.UpdateNodePoolRequest{
+				ProjectId:   reqC.ProjectId,
+				Zone:        reqC.Zone,
+				ClusterId:   reqC.Cluster.Name,
+				NodePoolId:  name,
+				NodeVersion: version,
+				ImageType:   imageType,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if p.autoscaling {
+		autoscaling := p.desired.Autoscaling
+		if autoscaling == nil {
+			autoscaling = &containerpb.NodePoolAutoscaling{}
+		}
+		err := c.nodePoolOperation(reqN, "setting nodepool autoscaling", func() (*containerpb.Operation, error) {
+			return c.clientGKE.SetNodePoolAutoscaling(c.ctx, &containerpb.SetNodePoolAutoscalingRequest{
+				ProjectId:   reqC.ProjectId,
+				Zone:        reqC.Zone,
+				ClusterId:   reqC.Cluster.Name,
+				NodePoolId:  name,
+				Autoscaling: autoscaling,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if p.resize {
+		err := c.nodePoolOperation(reqN, "resizing nodepool", func() (*containerpb.Operation, error) {
+			return c.clientGKE.SetNodePoolSize(c.ctx, &containerpb.SetNodePoolSizeRequest{
+				ProjectId:  reqC.ProjectId,
+				Zone:       reqC.Zone,
+				ClusterId:  reqC.Cluster.Name,
+				NodePoolId: name,
+				NodeCount:  p.desired.InitialNodeCount,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodePoolOperation starts the node pool operation, retrying while another operation is running on the cluster,
+// and waits for the node pool to be running again.
+func (c *GKE) nodePoolOperation(reqN *containerpb.CreateNodePoolRequest, name string, op func() (*containerpb.Operation, error)) error {
+	log.Printf("%v:%v, cluster '%v', project '%v', zone '%v'", name, reqN.NodePool.Name, reqN.ClusterId, reqN.ProjectId, reqN.Zone)
+	err := provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("%v:%v", name, reqN.NodePool.Name),
+		provider.DefaultBackoff,
+		func() (bool, error) {
+			rep, err := op()
+			if err != nil {
+				if st, ok := status.FromError(err); ok && st.Code() == codes.FailedPrecondition {
+					// GKE cannot have two simultaneous nodepool operations running on it
+					// Waiting for any ongoing operation to complete before starting new one
+					log.Printf("Cluster in 'FailedPrecondition' state '%s'", err)
+					return false, nil
+				}
+				return false, err
+			}
+			log.Printf("cluster node pool status: `%v`", rep.Status)
+			return true, nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("checking nodepool running status for:%v", reqN.NodePool.Name),
+		provider.DefaultBackoff,
+		func() (bool, error) {
+			return c.nodePoolRunning(reqN.Zone, reqN.ProjectId, reqN.ClusterId, reqN.NodePool.Name)
+		})
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gke
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+	yamlGo "gopkg.in/yaml.v2"
+)
+
+func nodePool(t *testing.T, content string) *containerpb.NodePool {
+	p := &containerpb.NodePool{}
+	if err := yamlGo.UnmarshalStrict([]byte(content), p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const desiredPool = `
+name: prometheus-1
+initialnodecount: 2
+config:
+  machinetype: n1-highmem-8
+  imagetype: COS
+  disksizegb: 100
+  localssdcount: 1
+  labels:
+    isolation: prometheus
+    node-name: prometheus-1
+`
+
+func TestDiffNodePool(t *testing.T) {
+	// The live node pool has the defaults set by GKE.
+	live := nodePool(t, `
+name: prometheus-1
+initialnodecount: 1
+version: 1.16.13-gke.1
+config:
+  machinetype: n1-highmem-8
+  imagetype: COS
+  disksizegb: 100
+  disktype: pd-standard
+  localssdcount: 1
+  oauthscopes:
+  - https://www.googleapis.com/auth/devstorage.read_only
+  labels:
+    isolation: prometheus
+    node-name: prometheus-1
+`)
+
+	for _, tc := range []struct {
+		name     string
+		desired  string
+		live     *containerpb.NodePool
+		liveSize int32
+
+		action                       nodePoolAction
+		diff                         []nodePoolDiff
+		upgrade, autoscaling, resize bool
+	}{
+		{
+			name:    "missing",
+			desired: desiredPool,
+			action:  nodePoolCreate,
+		},
+		{
+			name:     "unchanged",
+			desired:  desiredPool,
+			live:     live,
+			liveSize: 2,
+			action:   nodePoolUnchanged,
+		},
+		{
+			name:     "resized",
+			desired:  desiredPool,
+			live:     live,
+			liveSize: 3,
+			action:   nodePoolUpdate,
+			diff:     []nodePoolDiff{{Path: "size", Live: "3", Desired: "2"}},
+			resize:   true,
+		},
+		{
+			name:        "autoscaling",
+			desired:     desiredPool + "autoscaling:\n  enabled: true\n  minnodecount: 1\n  maxnodecount: 5\n",
+			live:        live,
+			liveSize:    3,
+			action:      nodePoolUpdate,
+			diff:        []nodePoolDiff{{Path: "autoscaling", Live: "disabled", Desired: "1-5 nodes"}},
+			autoscaling: true,
+		},
+		{
+			name:     "upgrade",
+			desired:  desiredPool + "version: 1.17.9-gke.1504\n",
+			live:     live,
+			liveSize: 2,
+			action:   nodePoolUpdate,
+			diff:     []nodePoolDiff{{Path: "version", Live: "1.16.13-gke.1", Desired: "1.17.9-gke.1504"}},
+			upgrade:  true,
+		},
+		{
+			name:     "machine type and labels",
+			desired:  strings.Replace(strings.Replace(desiredPool, "n1-highmem-8", "n1-highmem-16", 1), "isolation: prometheus", "isolation: none", 1),
+			live:     live,
+			liveSize: 1,
+			action:   nodePoolRecreate,
+			diff: []nodePoolDiff{
+				{Path: "config.machinetype", Live: "n1-highmem-8", Desired: "n1-highmem-16"},
+				{Path: "config.labels", Live: "[isolation=prometheus node-name=prometheus-1]", Desired: "[isolation=none node-name=prometheus-1]", Unsupported: true},
+				{Path: "size", Live: "1", Desired: "2"},
+			},
+			resize: true,
+		},
+		{
+			name:     "taints",
+			desired:  desiredPool + "  taints:\n  - key: dedicated\n    value: prometheus\n    effect: 1\n",
+			live:     live,
+			liveSize: 2,
+			action:   nodePoolUnsupported,
+			diff:     []nodePoolDiff{{Path: "config.taints", Live: "[]", Desired: "[dedicated=prometheus:NO_SCHEDULE]", Unsupported: true}},
+		},
+		{
+			name:     "labels and size",
+			desired:  strings.Replace(desiredPool, "isolation: prometheus", "isolation: none", 1),
+			live:     live,
+			liveSize: 1,
+			action:   nodePoolUnsupported,
+			diff: []nodePoolDiff{
+				{Path: "config.labels", Live: "[isolation=prometheus node-name=prometheus-1]", Desired: "[isolation=none node-name=prometheus-1]", Unsupported: true},
+				{Path: "size", Live: "1", Desired: "2"},
+			},
+			resize: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := diffNodePool(nodePool(t, tc.desired), tc.live, tc.liveSize)
+			if p.Action != tc.action {
+				t.Errorf("expected action %v, got %v", tc.action, p.Action)
+			}
+			if !reflect.DeepEqual(tc.diff, p.Diff) {
+				t.Errorf("expected diff %v, got %v", tc.diff, p.Diff)
+			}
+			if p.upgrade != tc.upgrade || p.autoscaling != tc.autoscaling || p.resize != tc.resize {
+				t.Errorf("expected upgrade:%v autoscaling:%v resize:%v, got upgrade:%v autoscaling:%v resize:%v",
+					tc.upgrade, tc.autoscaling, tc.resize, p.upgrade, p.autoscaling, p.resize)
+			}
+		})
+	}
+}
+
+func TestPrintNodePoolPlans(t *testing.T) {
+	var b strings.Builder
+	changed := printNodePoolPlans(&b, []nodePoolPlan{
+		{Action: nodePoolCreate, desired: &containerpb.NodePool{Name: "nodes-1"}},
+		{Action: nodePoolUpdate, desired: &containerpb.NodePool{Name: "prometheus-1"}, Diff: []nodePoolDiff{{Path: "size", Live: "1", Desired: "2"}}},
+		{Action: nodePoolUnsupported, desired: &containerpb.NodePool{Name: "prometheus-2"}, Diff: []nodePoolDiff{{Path: "config.labels", Live: "[]", Desired: "[isolation=prometheus]", Unsupported: true}}},
+		{Action: nodePoolUnchanged, desired: &containerpb.NodePool{Name: "main-node"}},
+	})
+	expected := `+ create      nodepool:nodes-1
+~ update      nodepool:prometheus-1
+    ~ size: 1 => 2
+! unsupported nodepool:prometheus-2
+    ~ config.labels: [] => [isolation=prometheus] (can't be updated in place, recreate the node pool with --recreate)
+= unchanged   nodepool:main-node
+
+Plan: 1 to create, 1 to update, 0 to recreate, 1 unsupported, 1 unchanged.
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, b.String())
+	}
+	if !changed {
+		t.Error("expected the plans to have changes")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gke
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/test-infra/pkg/provider"
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+// GC deletes the benchmark node pools and namespaces of the cluster that are older than the options allow.
+// GKE doesn't keep the creation time of the node pools so their age is the age of their oldest instance group,
+// or of their oldest k8s node when the instance groups can't be read.
+func (c *GKE) GC(opts provider.GCOptions) error {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return err
+	}
+	if err := c.NewK8sProvider(nil); err != nil {
+		return err
+	}
+	objects, err := c.K8sProvider.BenchmarkNamespaces()
+	if err != nil {
+		return err
+	}
+
+	project, zone, cluster := c.DeploymentVars["GKE_PROJECT_ID"], c.DeploymentVars["ZONE"], c.DeploymentVars["CLUSTER_NAME"]
+	rep, err := c.clientGKE.ListNodePools(c.ctx, &containerpb.ListNodePoolsRequest{
+		ProjectId: project,
+		Zone:      zone,
+		ClusterId: cluster,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "listing the nodepools of cluster:%v", cluster)
+	}
+	created, err := c.K8sProvider.NodesCreated(nodePoolLabel)
+	if err != nil {
+		return err
+	}
+	for _, pool := range rep.NodePools {
+		if o, ok := provider.NewBenchmarkObject(provider.StateNodePool, pool.Name, c.instanceGroupsCreated(pool, created[pool.Name])); ok {
+			objects = append(objects, o)
+		}
+	}
+
+	return provider.CollectGarbage(os.Stdout, objects, opts, func(o provider.BenchmarkObject) error {
+		if o.Kind == provider.StateNamespace {
+			return c.GCNamespace(o.Name)
+		}
+		return c.deleteNodePool(&containerpb.DeleteNodePoolRequest{
+			ProjectId:  project,
+			Zone:       zone,
+			ClusterId:  cluster,
+			NodePoolId: o.Name,
+		})
+	})
+}
+
+// instanceGroupURL matches the project, zone and name of the instance group managers of a node pool.
+var instanceGroupURL = regexp.MustCompile(`/projects/([^/]+)/zones/([^/]+)/instanceGroupManagers/([^/]+)$`)
+
+// instanceGroupsCreated returns the creation time of the oldest instance group of the node pool.
+// The instance groups are kept when the nodes are repaired, upgraded or scaled to zero.
+// It returns nodesCreated when an instance group can't be read.
+func (c *GKE) instanceGroupsCreated(pool *containerpb.NodePool, nodesCreated time.Time) time.Time {
+	var created time.Time
+	for _, url := range pool.InstanceGroupUrls {
+		m := instanceGroupURL.FindStringSubmatch(url)
+		if m == nil {
+			log.Printf("node pool %v: unknown instance group url %v, using the age of its nodes", pool.Name, url)
+			return nodesCreated
+		}
+		group, err := c.clientCompute.InstanceGroupManagers.Get(m[1], m[2], m[3]).Context(c.ctx).Do()
+		if err != nil {
+			log.Printf("node pool %v: getting the instance group %v, using the age of its nodes: %v", pool.Name, m[3], err)
+			return nodesCreated
+		}
+		t, err := time.Parse(time.RFC3339, group.CreationTimestamp)
+		if err != nil {
+			log.Printf("node pool %v: parsing the creation time of the instance group %v, using the age of its nodes: %v", pool.Name, m[3], err)
+			return nodesCreated
+		}
+		if created.IsZero() || t.Before(created) {
+			created = t
+		}
+	}
+	if created.IsZero() {
+		return nodesCreated
+	}
+	return created
+}
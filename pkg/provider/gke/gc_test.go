@@ -0,0 +1,90 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gke
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	containerpb "google.golang.org/genproto/googleapis/container/v1"
+)
+
+func TestInstanceGroupsCreated(t *testing.T) {
+	// The creation times of the instance groups by zone.
+	groups := map[string]string{
+		"us-east1-b": "2020-07-01T10:00:00.000-07:00",
+		"us-east1-c": "2020-06-30T10:00:00.000-07:00",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for zone, created := range groups {
+			if r.URL.Path == "/my-project/zones/"+zone+"/instanceGroupManagers/gke-prombench-prometheus-1-grp" {
+				fmt.Fprintf(w, `{"creationTimestamp": %q}`, created)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	cc, err := compute.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &GKE{clientCompute: cc, ctx: ctx}
+
+	url := func(zone string) string {
+		return "https://www.googleapis.com/compute/v1/projects/my-project/zones/" + zone + "/instanceGroupManagers/gke-prombench-prometheus-1-grp"
+	}
+	nodesCreated := time.Date(2020, 7, 2, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name string
+		urls []string
+		exp  time.Time
+	}{
+		{
+			name: "oldest instance group",
+			urls: []string{url("us-east1-b"), url("us-east1-c")},
+			exp:  time.Date(2020, 6, 30, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "no instance groups",
+			exp:  nodesCreated,
+		},
+		{
+			name: "missing instance group",
+			urls: []string{url("us-east1-b"), url("us-east1-d")},
+			exp:  nodesCreated,
+		},
+		{
+			name: "unknown url",
+			urls: []string{"gke-prombench-prometheus-1-grp"},
+			exp:  nodesCreated,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := &containerpb.NodePool{Name: "prometheus-1", InstanceGroupUrls: tc.urls}
+			if got := c.instanceGroupsCreated(pool, nodesCreated); !got.Equal(tc.exp) {
+				t.Fatalf("expected %v, got %v", tc.exp, got)
+			}
+		})
+	}
+}
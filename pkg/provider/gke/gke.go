@@ -21,37 +21,54 @@ import (
 	"log"
 	"os"
 	"regexp"
-	"strings"
+	"time"
 
 	gke "cloud.google.com/go/container/apiv1"
 	"github.com/pkg/errors"
 	k8sProvider "github.com/prometheus/test-infra/pkg/provider/k8s"
 
 	"github.com/prometheus/test-infra/pkg/provider"
+	"golang.org/x/oauth2/google"
 	containerpb "google.golang.org/genproto/googleapis/container/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gopkg.in/alecthomas/kingpin.v2"
 	yamlGo "gopkg.in/yaml.v2"
 
+	compute "google.golang.org/api/compute/v1"
 	"google.golang.org/api/option"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+func init() {
+	provider.Register("gke", "Google container engine provider - https://cloud.google.com/kubernetes-engine/", func(dr *provider.DeploymentResource) provider.Provider {
+		return New(dr)
+	})
+}
+
+var (
+	_ provider.ClusterProvider    = (*GKE)(nil)
+	_ provider.NodePoolProvider   = (*GKE)(nil)
+	_ provider.ResourceProvider   = (*GKE)(nil)
+	_ provider.KubeconfigProvider = (*GKE)(nil)
+	_ provider.Destroyer          = (*GKE)(nil)
+	_ provider.GarbageCollector   = (*GKE)(nil)
+	_ provider.NodePoolCoster     = (*GKE)(nil)
+)
+
 // New is the GKE constructor.
 func New(dr *provider.DeploymentResource) *GKE {
 	return &GKE{
-		DeploymentResource: dr,
+		Base: k8sProvider.NewBase(dr, "GKE_PROJECT_ID", "ZONE", "CLUSTER_NAME"),
 	}
 }
 
-type Resource = provider.Resource
-
 // GKE holds the fields used to generate an API request.
 type GKE struct {
+	k8sProvider.Base
+
 	// The auth used to authenticate the cli.
 	// Can be a file path or an env variable that includes the json data.
 	Auth string
@@ -59,24 +76,22 @@ type GKE struct {
 	ProjectID string
 	// The gke client used when performing GKE requests.
 	clientGKE *gke.ClusterManagerClient
-	// The k8s provider used when we work with the manifest files.
-	k8sProvider *k8sProvider.K8s
-	// Final DeploymentFiles files.
-	DeploymentFiles []string
-	// Final DeploymentVars.
-	DeploymentVars map[string]string
-	// DeployResource to construct DeploymentVars and DeploymentFiles
-	DeploymentResource *provider.DeploymentResource
-	// Content bytes after parsing the template variables, grouped by filename.
-	gkeResources []Resource
-	// K8s resource.runtime objects after parsing the template variables, grouped by filename.
-	k8sResources []k8sProvider.Resource
+	// The compute client used to find the instance groups of the node pools.
+	clientCompute *compute.Service
 
 	ctx context.Context
 }
 
-// NewGKEClient sets the GKE client used when performing GKE requests.
-func (c *GKE) NewGKEClient(*kingpin.ParseContext) error {
+// Flags adds the GKE specific flags.
+func (c *GKE) Flags(cmd *kingpin.CmdClause) {
+	cmd.Flag("auth", "json authentication for the project. Accepts a filepath or an env variable that inlcudes tha json data. If not set the tool will use the GOOGLE_APPLICATION_CREDENTIALS env variable (export GOOGLE_APPLICATION_CREDENTIALS=service-account.json). https://cloud.google.com/iam/docs/creating-managing-service-account-keys.").
+		PlaceHolder("service-account.json").
+		Short('a').
+		StringVar(&c.Auth)
+}
+
+// NewClient sets the GKE client used when performing GKE requests.
+func (c *GKE) NewClient(*kingpin.ParseContext) error {
 	// Set the auth env variable needed to the gke client.
 	if c.Auth != "" {
 	} else if c.Auth = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); c.Auth == "" {
@@ -119,99 +134,27 @@ func (c *GKE) NewGKEClient(*kingpin.ParseContext) error {
 
 	opts := option.WithCredentialsJSON([]byte(c.Auth))
 
-	cl, err := gke.NewClusterManagerClient(context.Background(), opts)
+	c.ctx = c.DeploymentResource.Context()
+	cl, err := gke.NewClusterManagerClient(c.ctx, opts)
 	if err != nil {
 		return errors.Wrap(err, "could not create the gke client")
 	}
 	c.clientGKE = cl
-	c.ctx = context.Background()
-
-	return nil
-}
-
-// SetupDeploymentResources Sets up DeploymentVars and DeploymentFiles
-func (c *GKE) SetupDeploymentResources(*kingpin.ParseContext) error {
-	c.DeploymentFiles = c.DeploymentResource.DeploymentFiles
-	c.DeploymentVars = provider.MergeDeploymentVars(
-		c.DeploymentResource.DefaultDeploymentVars,
-		c.DeploymentResource.FlagDeploymentVars,
-	)
-	return nil
-}
 
-// GKEDeploymentsParse parses the cluster/nodepool deployment files and saves the result as bytes grouped by the filename.
-// Any variables passed to the cli will be replaced in the resources files following the golang text template format.
-func (c *GKE) GKEDeploymentsParse(*kingpin.ParseContext) error {
-	if err := c.checkDeploymentVarsAndFiles(); err != nil {
-		return err
-	}
-
-	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
+	cc, err := compute.NewService(c.ctx, opts)
 	if err != nil {
-		log.Fatalf("Couldn't parse deployment files: %v", err)
+		return errors.Wrap(err, "could not create the compute client")
 	}
+	c.clientCompute = cc
 
-	c.gkeResources = deploymentResource
 	return nil
 }
 
-// K8SDeploymentsParse parses the k8s objects deployment files and saves the result as k8s objects grouped by the filename.
-// Any variables passed to the cli will be replaced in the resources files following the golang text template format.
-func (c *GKE) K8SDeploymentsParse(*kingpin.ParseContext) error {
-	if err := c.checkDeploymentVarsAndFiles(); err != nil {
-		return err
-	}
-
-	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
-	if err != nil {
-		log.Fatalf("Couldn't parse deployment files: %v", err)
-	}
-
-	for _, deployment := range deploymentResource {
-
-		decode := scheme.Codecs.UniversalDeserializer().Decode
-		k8sObjects := make([]runtime.Object, 0)
-
-		for _, text := range strings.Split(string(deployment.Content), provider.Separator) {
-			text = strings.TrimSpace(text)
-			if len(text) == 0 {
-				continue
-			}
-
-			resource, _, err := decode([]byte(text), nil, nil)
-			if err != nil {
-				return errors.Wrapf(err, "decoding the resource file:%v, section:%v...", deployment.FileName, text[:100])
-			}
-			if resource == nil {
-				continue
-			}
-			k8sObjects = append(k8sObjects, resource)
-		}
-		if len(k8sObjects) > 0 {
-			c.k8sResources = append(c.k8sResources, k8sProvider.Resource{FileName: deployment.FileName, Objects: k8sObjects})
-		}
-	}
-	return nil
-}
-
-// checkDeploymentVarsAndFiles checks whether the requied deployment vars are passed.
-func (c *GKE) checkDeploymentVarsAndFiles() error {
-	reqDepVars := []string{"GKE_PROJECT_ID", "ZONE", "CLUSTER_NAME"}
-	for _, k := range reqDepVars {
-		if v, ok := c.DeploymentVars[k]; !ok || v == "" {
-			return fmt.Errorf("missing required %v variable", k)
-		}
-	}
-	if len(c.DeploymentFiles) == 0 {
-		return fmt.Errorf("missing deployment file(s)")
-	}
-	return nil
-}
-
-// ClusterCreate create a new cluster or applies changes to an existing cluster.
+// ClusterCreate creates a new cluster.
+// Use NodesApply to change the node pools of an existing cluster.
 func (c *GKE) ClusterCreate(*kingpin.ParseContext) error {
 	req := &containerpb.CreateClusterRequest{}
-	for _, deployment := range c.gkeResources {
+	for _, deployment := range c.ProviderResources {
 
 		if err := yamlGo.UnmarshalStrict(deployment.Content, req); err != nil {
 			log.Fatalf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
@@ -224,23 +167,38 @@ func (c *GKE) ClusterCreate(*kingpin.ParseContext) error {
 		}
 
 		err = provider.RetryUntilTrue(
+			c.ctx,
 			fmt.Sprintf("creating cluster:%v", req.Cluster.Name),
-			provider.GlobalRetryCount,
+			provider.DefaultBackoff,
 			func() (bool, error) { return c.clusterRunning(req.Zone, req.ProjectId, req.Cluster.Name) })
 
 		if err != nil {
 			log.Fatalf("creating cluster err:%v", err)
 		}
+
+		// The node pools of the cluster are deleted with it so only the cluster is recorded.
+		if err := c.Record(provider.StateEntry{
+			Kind:     provider.StateCluster,
+			Name:     req.Cluster.Name,
+			Settings: stateSettings(req.ProjectId, req.Zone),
+		}); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// stateSettings returns the location of the cluster recorded with its objects in the state file.
+func stateSettings(projectID, zone string) map[string]string {
+	return map[string]string{"project": projectID, "zone": zone}
+}
+
 // ClusterDelete deletes a k8s cluster.
 func (c *GKE) ClusterDelete(*kingpin.ParseContext) error {
 	// Use CreateClusterRequest struct to pass the UnmarshalStrict validation and
 	// than use the result to create the DeleteClusterRequest
 	reqC := &containerpb.CreateClusterRequest{}
-	for _, deployment := range c.gkeResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
 			log.Fatalf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
@@ -249,20 +207,28 @@ func (c *GKE) ClusterDelete(*kingpin.ParseContext) error {
 			Zone:      reqC.Zone,
 			ClusterId: reqC.Cluster.Name,
 		}
-		log.Printf("Removing cluster '%v', project '%v', zone '%v'", reqD.ClusterId, reqD.ProjectId, reqD.Zone)
-
-		err := provider.RetryUntilTrue(
-			fmt.Sprintf("deleting cluster:%v", reqD.ClusterId),
-			provider.GlobalRetryCount,
-			func() (bool, error) { return c.clusterDeleted(reqD) })
-
-		if err != nil {
+		if err := c.deleteCluster(reqD); err != nil {
 			log.Fatalf("removing cluster err:%v", err)
 		}
 	}
 	return nil
 }
 
+// deleteCluster deletes a cluster and waits for it to be gone.
+func (c *GKE) deleteCluster(reqD *containerpb.DeleteClusterRequest) error {
+	log.Printf("Removing cluster '%v', project '%v', zone '%v'", reqD.ClusterId, reqD.ProjectId, reqD.Zone)
+
+	err := provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("deleting cluster:%v", reqD.ClusterId),
+		provider.DefaultBackoff,
+		func() (bool, error) { return c.clusterDeleted(reqD) })
+	if err != nil {
+		return err
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateCluster, Name: reqD.ClusterId})
+}
+
 // clusterDeleted checks whether a cluster has been deleted.
 func (c *GKE) clusterDeleted(req *containerpb.DeleteClusterRequest) (bool, error) {
 	rep, err := c.clientGKE.DeleteCluster(c.ctx, req)
@@ -311,11 +277,11 @@ func (c *GKE) clusterRunning(zone, projectID, clusterID string) (bool, error) {
 	return false, nil
 }
 
-// NodePoolCreate creates a new k8s node-pool in an existing cluster.
-func (c *GKE) NodePoolCreate(*kingpin.ParseContext) error {
+// NodesCreate creates a new k8s node-pool in an existing cluster.
+func (c *GKE) NodesCreate(*kingpin.ParseContext) error {
 	reqC := &containerpb.CreateClusterRequest{}
 
-	for _, deployment := range c.gkeResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
 			log.Fatalf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
@@ -327,27 +293,7 @@ func (c *GKE) NodePoolCreate(*kingpin.ParseContext) error {
 				ClusterId: reqC.Cluster.Name,
 				NodePool:  node,
 			}
-			log.Printf("Cluster nodepool create request: cluster '%v', nodepool '%v' , project `%s`,zone `%s`", reqN.ClusterId, reqN.NodePool.Name, reqN.ProjectId, reqN.Zone)
-
-			err := provider.RetryUntilTrue(
-				fmt.Sprintf("nodepool creation:%v", reqN.NodePool.Name),
-				provider.GlobalRetryCount,
-				func() (bool, error) {
-					return c.nodePoolCreated(reqN)
-				})
-
-			if err != nil {
-				log.Fatalf("Couldn't create cluster nodepool '%v', file:%v ,err: %v", node.Name, deployment.FileName, err)
-			}
-
-			err = provider.RetryUntilTrue(
-				fmt.Sprintf("checking nodepool running status for:%v", reqN.NodePool.Name),
-				provider.GlobalRetryCount,
-				func() (bool, error) {
-					return c.nodePoolRunning(reqN.Zone, reqN.ProjectId, reqN.ClusterId, reqN.NodePool.Name)
-				})
-
-			if err != nil {
+			if err := c.createNodePool(reqN); err != nil {
 				log.Fatalf("Couldn't create cluster nodepool '%v', file:%v ,err: %v", node.Name, deployment.FileName, err)
 			}
 		}
@@ -355,6 +301,39 @@ func (c *GKE) NodePoolCreate(*kingpin.ParseContext) error {
 	return nil
 }
 
+// createNodePool creates a node pool and waits for it to be running.
+func (c *GKE) createNodePool(reqN *containerpb.CreateNodePoolRequest) error {
+	log.Printf("Cluster nodepool create request: cluster '%v', nodepool '%v' , project `%s`,zone `%s`", reqN.ClusterId, reqN.NodePool.Name, reqN.ProjectId, reqN.Zone)
+
+	err := provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("nodepool creation:%v", reqN.NodePool.Name),
+		provider.DefaultBackoff,
+		func() (bool, error) {
+			return c.nodePoolCreated(reqN)
+		})
+	if err != nil {
+		return err
+	}
+
+	err = provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("checking nodepool running status for:%v", reqN.NodePool.Name),
+		provider.DefaultBackoff,
+		func() (bool, error) {
+			return c.nodePoolRunning(reqN.Zone, reqN.ProjectId, reqN.ClusterId, reqN.NodePool.Name)
+		})
+	if err != nil {
+		return err
+	}
+	return c.Record(provider.StateEntry{
+		Kind:     provider.StateNodePool,
+		Name:     reqN.NodePool.Name,
+		Cluster:  reqN.ClusterId,
+		Settings: stateSettings(reqN.ProjectId, reqN.Zone),
+	})
+}
+
 // nodePoolCreated checks if there is any ongoing NodePool operation on the cluster
 // when creating a NodePool.
 func (c *GKE) nodePoolCreated(req *containerpb.CreateNodePoolRequest) (bool, error) {
@@ -378,12 +357,12 @@ func (c *GKE) nodePoolCreated(req *containerpb.CreateNodePoolRequest) (bool, err
 	return true, nil
 }
 
-// NodePoolDelete deletes a new k8s node-pool in an existing cluster.
-func (c *GKE) NodePoolDelete(*kingpin.ParseContext) error {
+// NodesDelete deletes a new k8s node-pool in an existing cluster.
+func (c *GKE) NodesDelete(*kingpin.ParseContext) error {
 	// Use CreateNodePoolRequest struct to pass the UnmarshalStrict validation and
 	// than use the result to create the DeleteNodePoolRequest
 	reqC := &containerpb.CreateClusterRequest{}
-	for _, deployment := range c.gkeResources {
+	for _, deployment := range c.ProviderResources {
 
 		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
 			log.Fatalf("Error parsing the cluster deployment file %s:%v", deployment.FileName, err)
@@ -396,14 +375,7 @@ func (c *GKE) NodePoolDelete(*kingpin.ParseContext) error {
 				ClusterId:  reqC.Cluster.Name,
 				NodePoolId: node.Name,
 			}
-			log.Printf("Removing cluster node pool: `%v`,  cluster '%v', project '%v', zone '%v'", reqD.NodePoolId, reqD.ClusterId, reqD.ProjectId, reqD.Zone)
-
-			err := provider.RetryUntilTrue(
-				fmt.Sprintf("deleting nodepool:%v", reqD.NodePoolId),
-				provider.GlobalRetryCount,
-				func() (bool, error) { return c.nodePoolDeleted(reqD) })
-
-			if err != nil {
+			if err := c.deleteNodePool(reqD); err != nil {
 				log.Fatalf("Couldn't delete cluster nodepool '%v', file:%v ,err: %v", node.Name, deployment.FileName, err)
 			}
 		}
@@ -411,6 +383,21 @@ func (c *GKE) NodePoolDelete(*kingpin.ParseContext) error {
 	return nil
 }
 
+// deleteNodePool deletes a node pool and waits for it to be gone.
+func (c *GKE) deleteNodePool(reqD *containerpb.DeleteNodePoolRequest) error {
+	log.Printf("Removing cluster node pool: `%v`,  cluster '%v', project '%v', zone '%v'", reqD.NodePoolId, reqD.ClusterId, reqD.ProjectId, reqD.Zone)
+
+	err := provider.RetryUntilTrue(
+		c.ctx,
+		fmt.Sprintf("deleting nodepool:%v", reqD.NodePoolId),
+		provider.DefaultBackoff,
+		func() (bool, error) { return c.nodePoolDeleted(reqD) })
+	if err != nil {
+		return err
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateNodePool, Name: reqD.NodePoolId, Cluster: reqD.ClusterId})
+}
+
 // nodePoolDeleted checks whether a nodepool has been deleted.
 func (c *GKE) nodePoolDeleted(req *containerpb.DeleteNodePoolRequest) (bool, error) {
 
@@ -468,11 +455,11 @@ func (c *GKE) nodePoolRunning(zone, projectID, clusterID, poolName string) (bool
 	return false, nil
 }
 
-// AllNodepoolsRunning returns an error if at least one node pool is not running.
-func (c *GKE) AllNodepoolsRunning(*kingpin.ParseContext) error {
+// AllNodesRunning returns an error if at least one node pool is not running.
+func (c *GKE) AllNodesRunning(*kingpin.ParseContext) error {
 	reqC := &containerpb.CreateClusterRequest{}
 
-	for _, deployment := range c.gkeResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
 			return errors.Errorf("error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
@@ -491,11 +478,11 @@ func (c *GKE) AllNodepoolsRunning(*kingpin.ParseContext) error {
 	return nil
 }
 
-// AllNodepoolsDeleted returns an error if at least one nodepool is not deleted.
-func (c *GKE) AllNodepoolsDeleted(*kingpin.ParseContext) error {
+// AllNodesDeleted returns an error if at least one nodepool is not deleted.
+func (c *GKE) AllNodesDeleted(*kingpin.ParseContext) error {
 	reqC := &containerpb.CreateClusterRequest{}
 
-	for _, deployment := range c.gkeResources {
+	for _, deployment := range c.ProviderResources {
 		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
 			return errors.Errorf("error parsing the cluster deployment file %s:%v", deployment.FileName, err)
 		}
@@ -514,8 +501,34 @@ func (c *GKE) AllNodepoolsDeleted(*kingpin.ParseContext) error {
 	return nil
 }
 
-// NewK8sProvider sets the k8s provider used for deploying k8s manifests.
-func (c *GKE) NewK8sProvider(*kingpin.ParseContext) error {
+// NodePoolSpecs returns the machine type and the initial number of nodes of every node pool in the deployment files.
+// The node count of a node pool is per zone so it is multiplied by the number of zones of the cluster.
+func (c *GKE) NodePoolSpecs() ([]provider.NodePoolSpec, error) {
+	var specs []provider.NodePoolSpec
+	for _, deployment := range c.ProviderResources {
+		reqC := &containerpb.CreateClusterRequest{}
+		if err := yamlGo.UnmarshalStrict(deployment.Content, reqC); err != nil {
+			return nil, errors.Errorf("error parsing the cluster deployment file %s:%v", deployment.FileName, err)
+		}
+
+		for _, node := range reqC.Cluster.NodePools {
+			zones := len(reqC.Cluster.Locations)
+			if zones == 0 {
+				zones = 1
+			}
+			specs = append(specs, provider.NodePoolSpec{
+				Name:        node.Name,
+				MachineType: node.GetConfig().GetMachineType(),
+				Nodes:       int(node.InitialNodeCount) * zones,
+			})
+		}
+	}
+	return specs, nil
+}
+
+// kubeconfig returns the kubeconfig of the cluster which authenticates with
+// the application default credentials of the gcp auth provider.
+func (c *GKE) kubeconfig() (*clientcmdapi.Config, error) {
 	// Get the authentication certificate for the cluster using the GKE client.
 	req := &containerpb.GetClusterRequest{
 		ProjectId: c.DeploymentVars["GKE_PROJECT_ID"],
@@ -524,13 +537,13 @@ func (c *GKE) NewK8sProvider(*kingpin.ParseContext) error {
 	}
 	rep, err := c.clientGKE.GetCluster(c.ctx, req)
 	if err != nil {
-		log.Fatalf("failed to get cluster details: %v", err)
+		return nil, fmt.Errorf("failed to get cluster details: %v", err)
 	}
 
 	// The master auth retrieved from GCP it is base64 encoded so it must be decoded first.
 	caCert, err := base64.StdEncoding.DecodeString(rep.MasterAuth.GetClusterCaCertificate())
 	if err != nil {
-		log.Fatalf("failed to decode certificate: %v", err.Error())
+		return nil, fmt.Errorf("failed to decode certificate: %v", err.Error())
 	}
 
 	cluster := clientcmdapi.NewCluster()
@@ -556,36 +569,68 @@ func (c *GKE) NewK8sProvider(*kingpin.ParseContext) error {
 	config.Contexts[rep.Zone] = context
 	config.AuthInfos[rep.Zone] = authInfo
 	config.CurrentContext = rep.Zone
+	return config, nil
+}
 
-	c.k8sProvider, err = k8sProvider.New(c.ctx, config)
+// NewK8sProvider sets the k8s provider used for deploying k8s manifests.
+func (c *GKE) NewK8sProvider(*kingpin.ParseContext) error {
+	config, err := c.kubeconfig()
 	if err != nil {
-		log.Fatal("k8s provider error", err)
+		return err
 	}
-	return nil
-}
-
-// ResourceApply calls k8s.ResourceApply to apply the k8s objects in the manifest files.
-func (c *GKE) ResourceApply(*kingpin.ParseContext) error {
-	if err := c.k8sProvider.ResourceApply(c.k8sResources); err != nil {
-		log.Fatal("error while applying a resource err:", err)
+	c.K8sProvider, err = k8sProvider.New(c.ctx, config)
+	if err != nil {
+		return fmt.Errorf("k8s provider error %v", err)
 	}
 	return nil
 }
 
-// ResourceDelete calls k8s.ResourceDelete to apply the k8s objects in the manifest files.
-func (c *GKE) ResourceDelete(*kingpin.ParseContext) error {
-	if err := c.k8sProvider.ResourceDelete(c.k8sResources); err != nil {
-		log.Fatal("error while deleting objects from a manifest file err:", err)
+// Kubeconfig returns the kubeconfig of the cluster with an access token of the service account,
+// which kubectl uses until it expires after an hour.
+// After that it gets a new token using the GOOGLE_APPLICATION_CREDENTIALS env variable.
+func (c *GKE) Kubeconfig() ([]byte, error) {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return nil, err
 	}
-	return nil
+	config, err := c.kubeconfig()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := google.CredentialsFromJSON(c.ctx, []byte(c.Auth), "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse the auth data")
+	}
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get an access token")
+	}
+	authProvider := config.AuthInfos[config.CurrentContext].AuthProvider
+	authProvider.Config["access-token"] = token.AccessToken
+	authProvider.Config["expiry"] = token.Expiry.Format(time.RFC3339)
+	return clientcmd.Write(*config)
 }
 
-// GetDeploymentVars shows deployment variables.
-func (c *GKE) GetDeploymentVars(parseContext *kingpin.ParseContext) error {
-	fmt.Print("-------------------\n   DeploymentVars   \n------------------- \n")
-	for key, value := range c.DeploymentVars {
-		fmt.Println(key, " : ", value)
+// Destroy deletes a cluster, node pool or namespace recorded in the state file.
+func (c *GKE) Destroy(e provider.StateEntry) error {
+	switch e.Kind {
+	case provider.StateCluster:
+		return c.deleteCluster(&containerpb.DeleteClusterRequest{
+			ProjectId: e.Settings["project"],
+			Zone:      e.Settings["zone"],
+			ClusterId: e.Name,
+		})
+	case provider.StateNodePool:
+		return c.deleteNodePool(&containerpb.DeleteNodePoolRequest{
+			ProjectId:  e.Settings["project"],
+			Zone:       e.Settings["zone"],
+			ClusterId:  e.Cluster,
+			NodePoolId: e.Name,
+		})
+	case provider.StateNamespace:
+		if err := c.NewK8sProvider(nil); err != nil {
+			return err
+		}
+		return c.K8sProvider.NamespaceDelete(e.Name)
 	}
-
-	return nil
+	return fmt.Errorf("unknown state entry kind %q", e.Kind)
 }
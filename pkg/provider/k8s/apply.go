@@ -0,0 +1,155 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultFieldManager is the default field manager of the server-side apply requests made by ResourceApply.
+const DefaultFieldManager = "prometheus-test-infra"
+
+// applyPatch returns the body of a server-side apply request for the object.
+// The api server only takes ownership of the fields in the body so the fields set by other writers are left alone.
+func applyPatch(resource runtime.Object) ([]byte, error) {
+	fields, err := applyFields(resource)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// applyFields returns the fields of the object that are applied,
+// without the null fields, the zero fields of typed objects and the status.
+func applyFields(resource runtime.Object) (map[string]interface{}, error) {
+	obj, err := toUnstructured(resource)
+	if err != nil {
+		return nil, err
+	}
+	delete(obj.Object, "status")
+	dropNulls(obj.Object)
+	if _, ok := resource.(*unstructured.Unstructured); !ok {
+		dropZeroFields(obj.Object, reflect.TypeOf(resource))
+	}
+	return obj.Object, nil
+}
+
+// dropNulls removes the null fields of the object recursively,
+// like the creationTimestamp that every typed object has after it is decoded.
+func dropNulls(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if field == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = dropNulls(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = dropNulls(v[i])
+		}
+	}
+	return v
+}
+
+// dropZeroFields removes the fields of a typed object that aren't pointers and have their zero value,
+// like the empty strategy of a Deployment or the targetPort 0 of a Service port.
+// These can't be told apart from the fields that are not in the manifest.
+// Pointer fields are kept as these are only set when they are in the manifest, like replicas: 0.
+func dropZeroFields(v interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			for name, field := range v {
+				ft, ok := jsonField(t, name)
+				if !ok {
+					continue
+				}
+				dropZeroFields(field, ft)
+				if ft.Kind() != reflect.Ptr && isZeroValue(field) {
+					delete(v, name)
+				}
+			}
+		case reflect.Map:
+			for _, field := range v {
+				dropZeroFields(field, t.Elem())
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for _, item := range v {
+				dropZeroFields(item, t.Elem())
+			}
+		}
+	}
+}
+
+// jsonField returns the type of the struct field with the given json name, including the fields of inlined structs.
+func jsonField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		switch {
+		case tag[0] == "-":
+			continue
+		case tag[0] == "" && f.Anonymous && f.Type.Kind() == reflect.Struct:
+			if ft, ok := jsonField(f.Type, name); ok {
+				return ft, true
+			}
+		case tag[0] == name, tag[0] == "" && f.Name == name:
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+func isZeroValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// applyOptions returns the options of the server-side apply requests.
+// Conflicts with other field managers are not forced so that the fields set by other writers,
+// like the replicas of a Deployment scaled by an autoscaler, are never taken over.
+// The api server returns a conflict error instead, which lists the conflicting fields and their managers.
+func (c *K8s) applyOptions() apiMetaV1.PatchOptions {
+	return apiMetaV1.PatchOptions{FieldManager: c.FieldManager}
+}
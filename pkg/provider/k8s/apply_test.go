@@ -0,0 +1,139 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	yamlGo "gopkg.in/yaml.v2"
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+func TestApplyPatchHasOnlyManifestFields(t *testing.T) {
+	for _, manifest := range []string{`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: prometheus
+  namespace: prombench
+spec:
+  replicas: 0
+  selector:
+    matchLabels:
+      app: prometheus
+  template:
+    metadata:
+      labels:
+        app: prometheus
+    spec:
+      containers:
+      - name: prometheus
+        image: prom/prometheus
+        ports:
+        - containerPort: 9090
+        volumeMounts:
+        - name: data
+          mountPath: /data
+      volumes:
+      - name: data
+        emptyDir: {}
+`, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: prometheus
+spec:
+  type: ClusterIP
+  ports:
+  - port: 80
+  selector:
+    app: prometheus
+`} {
+		resources, err := DecodeResources([]provider.Resource{{FileName: "manifest.yaml", Content: []byte(manifest)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := applyPatch(resources[0].Objects[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got, expected map[string]interface{}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), len(manifest)).Decode(&expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			out, _ := yamlGo.Marshal(got)
+			t.Errorf("expected the apply patch to be the manifest:%v\ngot:\n%s", manifest, out)
+		}
+	}
+}
+
+func TestApplyRequestOptions(t *testing.T) {
+	// The fake clientsets drop the options of the patch requests,
+	// so the request is sent to a server that only records it.
+	var (
+		method, query, contentType string
+		body                       []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, query, contentType = r.Method, r.URL.RawQuery, r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	c, err := NewWithRESTConfig(context.Background(), &rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.FieldManager = "prombench"
+	err = c.objectApply(&apiCoreV1.ConfigMap{
+		TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: apiMetaV1.ObjectMeta{Name: "config"},
+		Data:       map[string]string{"prometheus.yml": ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != http.MethodPatch || contentType != string(types.ApplyPatchType) {
+		t.Errorf("expected a server-side apply request, got: %v %v", method, contentType)
+	}
+	// Conflicts with other field managers are not forced.
+	if query != "fieldManager=prombench" {
+		t.Errorf("expected an apply by the prombench field manager, got query: %v", query)
+	}
+	expected := `{"apiVersion":"v1","data":{"prometheus.yml":""},"kind":"ConfigMap","metadata":{"name":"config","namespace":"default"}}`
+	if string(body) != expected {
+		t.Errorf("expected the body %v, got: %s", expected, body)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	yamlGo "gopkg.in/yaml.v2"
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// artifactsLogTailLines is the number of the most recent log lines collected for every container.
+	artifactsLogTailLines = 2000
+	// artifactsTimeout is how long CollectArtifacts can take to get all items from the api server.
+	artifactsTimeout = 2 * time.Minute
+)
+
+// artifactsBundle holds the files of the debugging bundle in memory until these are written as a tarball.
+type artifactsBundle struct {
+	names []string
+	files map[string][]byte
+}
+
+func (b *artifactsBundle) add(name string, content []byte) {
+	if b.files == nil {
+		b.files = map[string][]byte{}
+	}
+	if _, ok := b.files[name]; !ok {
+		b.names = append(b.names, name)
+	}
+	b.files[name] = content
+}
+
+func (b *artifactsBundle) addf(name string, format string, a ...interface{}) {
+	b.add(name, append(b.files[name], fmt.Sprintf(format, a...)...))
+}
+
+// writeTarball writes all files in a gzipped tarball with the given modification time.
+func (b *artifactsBundle) writeTarball(path string, modTime time.Time) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range b.names {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(b.files[name])),
+			ModTime: modTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b.files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// CollectArtifacts writes a debugging bundle for a failed ResourceApply as a tarball in dir and returns its path.
+// The bundle includes:
+//   - the error that ResourceApply returned.
+//   - the live yaml of the workloads that haven't finished their rollout and the manifest of the objects that don't exist.
+//   - the current and the previous logs of all containers in the namespaces of the deployments.
+//   - the events in the namespaces of the deployments.
+//   - the conditions of all nodes.
+//
+// Failing to collect a single item is recorded in the bundle instead of failing the whole collection.
+// The requests don't use the context of the client as it has usually expired when ResourceApply failed,
+// these use a new context limited by artifactsTimeout.
+func (c *K8s) CollectArtifacts(dir string, deployments []Resource, applyErr error) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), artifactsTimeout)
+	defer cancel()
+	c = c.withContext(ctx)
+
+	now := time.Now()
+	b := &artifactsBundle{}
+	if applyErr != nil {
+		b.add("error.txt", []byte(applyErr.Error()+"\n"))
+	}
+
+	namespaces := map[string]bool{}
+	for _, deployment := range deployments {
+		for _, resource := range deployment.Objects {
+			if ns := c.collectObject(b, deployment.FileName, resource); ns != "" {
+				namespaces[ns] = true
+			}
+		}
+	}
+
+	nsList := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		nsList = append(nsList, ns)
+	}
+	sort.Strings(nsList)
+	for _, ns := range nsList {
+		c.collectEvents(b, ns)
+		c.collectLogs(b, ns)
+	}
+	c.collectNodes(b)
+
+	path := filepath.Join(dir, fmt.Sprintf("artifacts-%v.tar.gz", now.UTC().Format("20060102-150405")))
+	if err := b.writeTarball(path, now); err != nil {
+		return "", errors.Wrapf(err, "writing the artifacts tarball")
+	}
+	return path, nil
+}
+
+// collectObject adds the yaml of the object when it is failing and returns its namespace.
+func (c *K8s) collectObject(b *artifactsBundle, fileName string, resource runtime.Object) string {
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return ""
+	}
+	kind := resource.GetObjectKind().GroupVersionKind().Kind
+	name := fmt.Sprintf("objects/%v_%v_%v.yaml", namespaceOrDefault(obj.GetNamespace()), kind, obj.GetName())
+	if kind == "Namespace" {
+		return obj.GetName()
+	}
+
+	client, mapping, err := c.resourceClient(resource)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			b.addf("objects/errors.txt", "%v %v from %v: %v\n", kind, obj.GetName(), fileName, err)
+		}
+		return obj.GetNamespace()
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return ""
+	}
+
+	live, err := client.Get(c.ctx, obj.GetName(), apiMetaV1.GetOptions{})
+	switch {
+	case apiErrors.IsNotFound(err):
+		// The object was never created so include what the manifest wanted to create.
+		desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
+		if err == nil {
+			b.add(name, toYAML(fmt.Sprintf("not found in the cluster, manifest from %v", fileName), desired))
+		}
+	case err != nil:
+		b.addf("objects/errors.txt", "%v %v from %v: %v\n", kind, obj.GetName(), fileName, err)
+	case hasRollout(resource):
+		if done, _, err := c.rolloutStatus(resource); err != nil || !done {
+			b.add(name, toYAML(fmt.Sprintf("live object from %v", fileName), live.Object))
+		}
+	}
+	return obj.GetNamespace()
+}
+
+func toYAML(comment string, obj map[string]interface{}) []byte {
+	out, err := yamlGo.Marshal(obj)
+	if err != nil {
+		return []byte(fmt.Sprintf("# %v\n# error marshaling the object: %v\n", comment, err))
+	}
+	return append([]byte(fmt.Sprintf("# %v\n", comment)), out...)
+}
+
+func (c *K8s) collectEvents(b *artifactsBundle, namespace string) {
+	name := fmt.Sprintf("events/%v.txt", namespace)
+	events, err := c.clt.CoreV1().Events(namespace).List(c.ctx, apiMetaV1.ListOptions{})
+	if err != nil {
+		b.addf(name, "error listing the events: %v\n", err)
+		return
+	}
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(items[i]).Before(eventTime(items[j]))
+	})
+	for _, e := range items {
+		b.addf(name, "%v %v %v/%v %v x%d: %v\n",
+			eventTime(e).Format(time.RFC3339), e.Type, e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Reason, e.Count, e.Message)
+	}
+	if len(items) == 0 {
+		b.add(name, []byte("no events\n"))
+	}
+}
+
+func (c *K8s) collectLogs(b *artifactsBundle, namespace string) {
+	pods, err := c.clt.CoreV1().Pods(namespace).List(c.ctx, apiMetaV1.ListOptions{})
+	if err != nil {
+		b.addf("logs/errors.txt", "error listing the pods in namespace %v: %v\n", namespace, err)
+		return
+	}
+	tail := int64(artifactsLogTailLines)
+	for _, pod := range pods.Items {
+		statuses := append(append([]apiCoreV1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			for _, previous := range []bool{false, true} {
+				if previous && cs.RestartCount == 0 {
+					continue
+				}
+				name := fmt.Sprintf("logs/%v/%v_%v.log", namespace, pod.Name, cs.Name)
+				if previous {
+					name = fmt.Sprintf("logs/%v/%v_%v.previous.log", namespace, pod.Name, cs.Name)
+				}
+				logs, err := c.clt.CoreV1().Pods(namespace).GetLogs(pod.Name, &apiCoreV1.PodLogOptions{
+					Container: cs.Name,
+					Previous:  previous,
+					TailLines: &tail,
+				}).DoRaw(c.ctx)
+				if err != nil {
+					b.addf(name, "error getting the logs: %v\n", err)
+					continue
+				}
+				b.add(name, logs)
+			}
+		}
+	}
+}
+
+func (c *K8s) collectNodes(b *artifactsBundle) {
+	nodes, err := c.clt.CoreV1().Nodes().List(c.ctx, apiMetaV1.ListOptions{})
+	if err != nil {
+		b.addf("nodes.txt", "error listing the nodes: %v\n", err)
+		return
+	}
+	for _, n := range nodes.Items {
+		b.addf("nodes.txt", "%v labels:%v unschedulable:%v\n", n.Name, n.Labels, n.Spec.Unschedulable)
+		for _, cond := range n.Status.Conditions {
+			b.addf("nodes.txt", "  %v=%v %v: %v (since %v)\n", cond.Type, cond.Status, cond.Reason, cond.Message, cond.LastTransitionTime.UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+// withContext returns a copy of the client that uses the given context for all requests.
+func (c *K8s) withContext(ctx context.Context) *K8s {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArtifactsTarball(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &artifactsBundle{}
+	b.add("error.txt", []byte("deployment not ready\n"))
+	b.addf("events/prombench.txt", "%v\n", "first")
+	b.addf("events/prombench.txt", "%v\n", "second")
+	b.add("logs/prombench/prometheus-0_prometheus.log", []byte("level=info msg=starting\n"))
+
+	path := filepath.Join(dir, "nested", "artifacts.tar.gz")
+	if err := b.writeTarball(path, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[h.Name] = string(content)
+	}
+
+	expected := map[string]string{
+		"error.txt":            "deployment not ready\n",
+		"events/prombench.txt": "first\nsecond\n",
+		"logs/prombench/prometheus-0_prometheus.log": "level=info msg=starting\n",
+	}
+	if !reflect.DeepEqual(expected, files) {
+		t.Errorf("expect %v, got %v", expected, files)
+	}
+}
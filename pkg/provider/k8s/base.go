@@ -0,0 +1,326 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+// Base holds the fields and the actions shared by all providers that deploy k8s manifests.
+// Providers embed it and only implement the parts that are specific to them.
+type Base struct {
+	// The k8s provider used when we work with the manifest files.
+	K8sProvider *K8s
+	// Final DeploymentFiles files.
+	DeploymentFiles []string
+	// Final DeploymentVars.
+	DeploymentVars map[string]string
+	// The source every final DeploymentVars value came from.
+	DeploymentVarsOrigin map[string]string
+	// DeployResource to construct DeploymentVars and DeploymentFiles
+	DeploymentResource *provider.DeploymentResource
+	// CustomDeploymentVars override the default DeploymentVars for this provider.
+	// DeploymentVars provided from the cli take precedence over these.
+	CustomDeploymentVars map[string]string
+	// RequiredVars are the DeploymentVars that must be set before parsing the deployment files.
+	RequiredVars []string
+	// Content bytes after parsing the template variables, grouped by filename.
+	ProviderResources []provider.Resource
+	// K8s resource.runtime objects after parsing the template variables, grouped by filename.
+	K8sResources []Resource
+	// StateSettings are recorded with the applied namespaces in the state file.
+	// Providers set them when the deployment vars aren't enough to access the cluster again.
+	StateSettings map[string]string
+}
+
+// NewBase returns a Base that requires the given deployment vars.
+func NewBase(dr *provider.DeploymentResource, requiredVars ...string) Base {
+	return Base{
+		DeploymentResource: dr,
+		RequiredVars:       requiredVars,
+	}
+}
+
+// Flags adds no flags. Providers override it when they need any.
+func (c *Base) Flags(*kingpin.CmdClause) {}
+
+// NewClient is a no-op for providers that don't talk to a provider API.
+func (c *Base) NewClient(*kingpin.ParseContext) error {
+	return nil
+}
+
+// SetupDeploymentResources Sets up DeploymentVars and DeploymentFiles
+func (c *Base) SetupDeploymentResources(*kingpin.ParseContext) error {
+	c.DeploymentFiles = c.DeploymentResource.DeploymentFiles
+	c.DeploymentVars, c.DeploymentVarsOrigin = provider.MergeVarsSources(
+		c.DeploymentResource.VarsSources(c.CustomDeploymentVars),
+	)
+	return nil
+}
+
+// CheckDeploymentVars checks whether the requied deployment vars are passed.
+func (c *Base) CheckDeploymentVars() error {
+	for _, k := range c.RequiredVars {
+		if v, ok := c.DeploymentVars[k]; !ok || v == "" {
+			return fmt.Errorf("missing required %v variable", k)
+		}
+	}
+	return nil
+}
+
+// CheckDeploymentVarsAndFiles checks whether the requied deployment vars and the deployment files are passed.
+func (c *Base) CheckDeploymentVarsAndFiles() error {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return err
+	}
+	if len(c.DeploymentFiles) == 0 {
+		return fmt.Errorf("missing deployment file(s)")
+	}
+	return nil
+}
+
+// DeploymentsParse parses the cluster/nodepool deployment files and saves the result as bytes grouped by the filename.
+// Any variables passed to the cli will be replaced in the resources files following the golang text template format.
+func (c *Base) DeploymentsParse(*kingpin.ParseContext) error {
+	if err := c.CheckDeploymentVarsAndFiles(); err != nil {
+		return err
+	}
+
+	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
+	if err != nil {
+		return fmt.Errorf("Couldn't parse deployment files: %v", err)
+	}
+
+	c.ProviderResources = deploymentResource
+	return nil
+}
+
+// K8SDeploymentsParse parses the k8s objects deployment files and saves the result as k8s objects grouped by the filename.
+// Any variables passed to the cli will be replaced in the resources files following the golang text template format.
+func (c *Base) K8SDeploymentsParse(*kingpin.ParseContext) error {
+	if err := c.CheckDeploymentVarsAndFiles(); err != nil {
+		return err
+	}
+
+	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
+	if err != nil {
+		return fmt.Errorf("Couldn't parse deployment files: %v", err)
+	}
+
+	k8sResources, err := DecodeResources(deploymentResource)
+	if err != nil {
+		return err
+	}
+	setFileSet(k8sResources, c.DeploymentFiles)
+	c.K8sResources = append(c.K8sResources, k8sResources...)
+	return nil
+}
+
+// ResourceApply calls k8s.ResourceApply to apply the k8s objects in the manifest files.
+// When it fails and opts.ArtifactsDir is set, it writes a debugging bundle using k8s.CollectArtifacts.
+// With opts.Prune it calls k8s.ResourcePrune to delete the objects that were removed from the manifest files.
+func (c *Base) ResourceApply(opts provider.ApplyOptions) error {
+	if err := c.K8sProvider.ResourceApply(c.K8sResources); err != nil {
+		if opts.ArtifactsDir != "" {
+			if path, aErr := c.K8sProvider.CollectArtifacts(opts.ArtifactsDir, c.K8sResources, err); aErr != nil {
+				log.Printf("error while collecting the debugging artifacts err: %v", aErr)
+			} else {
+				log.Printf("debugging artifacts written to %v", path)
+			}
+		}
+		return fmt.Errorf("error while applying a resource err: %v", err)
+	}
+	if opts.Prune {
+		if err := c.K8sProvider.ResourcePrune(c.K8sResources); err != nil {
+			return fmt.Errorf("error while pruning objects removed from the manifest files err: %v", err)
+		}
+	}
+	for _, ns := range c.namespaces() {
+		if err := c.Record(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResourcePlan calls k8s.ResourcePlan and prints what applying, or deleting when del is true,
+// the k8s objects in the manifest files would change.
+// It returns provider.ErrPendingChanges when any object would be changed.
+func (c *Base) ResourcePlan(del bool) error {
+	plans, err := c.K8sProvider.ResourcePlan(c.K8sResources, del)
+	if err != nil {
+		return fmt.Errorf("error while planning the resources err: %v", err)
+	}
+	if PrintPlan(os.Stdout, plans) {
+		return provider.ErrPendingChanges
+	}
+	return nil
+}
+
+// ResourceDelete calls k8s.ResourceDelete to delete the k8s objects in the manifest files
+// and waits for all of them to be gone.
+func (c *Base) ResourceDelete(opts provider.DeleteOptions) error {
+	if opts.PropagationPolicy != "" {
+		policy, err := ParsePropagationPolicy(opts.PropagationPolicy)
+		if err != nil {
+			return err
+		}
+		c.K8sProvider.DeletePropagation = policy
+	}
+	if opts.WaitTimeout > 0 {
+		c.K8sProvider.DeleteTimeout = opts.WaitTimeout
+	}
+	if err := c.K8sProvider.ResourceDelete(c.K8sResources); err != nil {
+		return fmt.Errorf("error while deleting objects from a manifest file err: %v", err)
+	}
+	for _, ns := range c.namespaces() {
+		if err := c.Forget(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespaces returns the state entries of the namespaces in the manifest files.
+func (c *Base) namespaces() []provider.StateEntry {
+	var entries []provider.StateEntry
+	for _, r := range c.K8sResources {
+		for _, resource := range r.Objects {
+			if resource.GetObjectKind().GroupVersionKind().Kind != "Namespace" {
+				continue
+			}
+			obj, err := meta.Accessor(resource)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, provider.StateEntry{
+				Kind:     provider.StateNamespace,
+				Name:     obj.GetName(),
+				Cluster:  c.DeploymentVars["CLUSTER_NAME"],
+				Settings: c.StateSettings,
+			})
+		}
+	}
+	return entries
+}
+
+// Record adds the created object to the state file with the deployment vars that don't look like secrets.
+func (c *Base) Record(e provider.StateEntry) error {
+	e.Vars = map[string]string{}
+	for k, v := range c.DeploymentVars {
+		if !provider.IsSecretVar(k) {
+			e.Vars[k] = v
+		}
+	}
+	if err := c.DeploymentResource.Record(e); err != nil {
+		return fmt.Errorf("recording %v %v in the state file err: %v", e.Kind, e.Name, err)
+	}
+	return nil
+}
+
+// Forget removes the deleted object from the state file.
+func (c *Base) Forget(e provider.StateEntry) error {
+	if err := c.DeploymentResource.Forget(e); err != nil {
+		return fmt.Errorf("removing %v %v from the state file err: %v", e.Kind, e.Name, err)
+	}
+	return nil
+}
+
+// GetDeploymentVars shows deployment variables and where each value came from.
+// The values of the variables that look like secrets are masked.
+func (c *Base) GetDeploymentVars(*kingpin.ParseContext) error {
+	fmt.Print("-------------------\n   DeploymentVars   \n------------------- \n")
+	keys := make([]string, 0, len(c.DeploymentVars))
+	for key := range c.DeploymentVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := c.DeploymentVars[key]
+		if provider.IsSecretVar(key) && value != "" {
+			value = "(sensitive value)"
+		}
+		fmt.Printf("%v  :  %v  (%v)\n", key, value, c.DeploymentVarsOrigin[key])
+	}
+	return nil
+}
+
+// DecodeResources decodes the parsed deployment files into k8s objects grouped by the filename.
+// Files that don't contain any k8s objects are skipped.
+func DecodeResources(deploymentResource []provider.Resource) ([]Resource, error) {
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+
+	var resources []Resource
+	for _, deployment := range deploymentResource {
+		k8sObjects := make([]runtime.Object, 0)
+
+		for _, text := range strings.Split(string(deployment.Content), provider.Separator) {
+			text = strings.TrimSpace(text)
+			if len(text) == 0 {
+				continue
+			}
+
+			resource, _, err := decode([]byte(text), nil, nil)
+			// Kinds that are not in the client-go scheme, like custom resources,
+			// are decoded as unstructured objects and applied with the dynamic client.
+			if runtime.IsNotRegisteredError(err) {
+				resource, err = decodeUnstructured([]byte(text))
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "decoding the resource file:%v, section:%v...", deployment.FileName, truncate(text, 100))
+			}
+			if resource == nil {
+				continue
+			}
+			k8sObjects = append(k8sObjects, resource)
+		}
+		if len(k8sObjects) > 0 {
+			resources = append(resources, Resource{FileName: deployment.FileName, Objects: k8sObjects})
+		}
+	}
+	return resources, nil
+}
+
+func decodeUnstructured(text []byte) (runtime.Object, error) {
+	j, err := yaml.ToJSON(text)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(j); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
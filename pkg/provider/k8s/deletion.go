@@ -0,0 +1,204 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultDeleteTimeout is the default time to wait for all deleted objects to be gone.
+const DefaultDeleteTimeout = 15 * time.Minute
+
+// ErrNotDeleted is returned when some deleted objects still exist after the deadline.
+var ErrNotDeleted = errors.New("objects not deleted before the deadline")
+
+// propagationPolicies maps the names accepted by ParsePropagationPolicy to the api values.
+var propagationPolicies = map[string]apiMetaV1.DeletionPropagation{
+	"foreground": apiMetaV1.DeletePropagationForeground,
+	"background": apiMetaV1.DeletePropagationBackground,
+	"orphan":     apiMetaV1.DeletePropagationOrphan,
+}
+
+// PropagationPolicies returns the names of the supported propagation policies in sorted order.
+func PropagationPolicies() []string {
+	names := make([]string, 0, len(propagationPolicies))
+	for name := range propagationPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePropagationPolicy returns the propagation policy with the given case insensitive name.
+func ParsePropagationPolicy(name string) (apiMetaV1.DeletionPropagation, error) {
+	if p, ok := propagationPolicies[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown propagation policy %q, expected one of: %v", name, strings.Join(PropagationPolicies(), ", "))
+}
+
+// WaitDeleted waits in parallel until all objects are gone from the cluster or the deadline is reached.
+// When the deadline is reached, the returned error names every object that still exists
+// with the finalizers that are holding it and, for namespaces, what is left in them.
+func (c *K8s) WaitDeleted(objects []Object, deadline time.Time) error {
+	var (
+		wg    sync.WaitGroup
+		mtx   sync.Mutex
+		errs  []error
+		stuck []*unstructured.Unstructured
+	)
+	for _, o := range objects {
+		wg.Add(1)
+		go func(o Object) {
+			defer wg.Done()
+			live, err := c.waitGone(o.Object, deadline)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error waiting for the deletion of '%v' err:%v", o.FileName, err))
+			} else if live != nil {
+				stuck = append(stuck, live)
+			}
+		}(o)
+	}
+	wg.Wait()
+
+	if len(stuck) > 0 {
+		sort.Slice(stuck, func(i, j int) bool {
+			return objectKey(stuck[i].GetKind(), stuck[i].GetNamespace(), stuck[i].GetName()) <
+				objectKey(stuck[j].GetKind(), stuck[j].GetNamespace(), stuck[j].GetName())
+		})
+		var report strings.Builder
+		for _, live := range stuck {
+			writeStuckReport(&report, live)
+		}
+		log.Print(report.String())
+		errs = append(errs, errors.Wrap(ErrNotDeleted, report.String()))
+	}
+	return joinErrors(errs)
+}
+
+// NamespaceDelete deletes the namespace, with everything in it, and waits for it to be gone.
+// It is not an error when the namespace doesn't exist.
+func (c *K8s) NamespaceDelete(name string) error {
+	ns := &apiCoreV1.Namespace{
+		TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: apiMetaV1.ObjectMeta{Name: name},
+	}
+	if err := c.namespaceDelete(ns); err != nil {
+		if apiErrors.IsNotFound(errors.Cause(err)) {
+			return nil
+		}
+		return err
+	}
+	return c.WaitDeleted([]Object{{FileName: "namespace " + name, Object: ns}}, time.Now().Add(c.DeleteTimeout))
+}
+
+// waitGone watches the object until it is gone and returns nil,
+// or returns the live object when it still exists at the deadline.
+func (c *K8s) waitGone(resource runtime.Object, deadline time.Time) (*unstructured.Unstructured, error) {
+	kind := resource.GetObjectKind().GroupVersionKind().Kind
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return nil, fmt.Errorf("reading the object metadata - kind: %v err:%v", kind, err)
+	}
+
+	ctx, cancel := context.WithDeadline(c.ctx, deadline)
+	defer cancel()
+	logged := false
+	gone, err := c.watchObject(ctx, resource, func(live *unstructured.Unstructured) (bool, error) {
+		if live == nil {
+			return true, nil
+		}
+		if !logged {
+			log.Printf("Waiting for the deletion of %v:%v, finalizers: %v", strings.ToLower(kind), obj.GetName(), live.GetFinalizers())
+			logged = true
+		}
+		return false, nil
+	})
+	if meta.IsNoMatchError(err) {
+		// The kind is no longer served so its objects are gone as well, like after deleting a CRD.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "watching resource : %v, name: %v", kind, obj.GetName())
+	}
+	if gone {
+		log.Printf("resource gone - kind: %v, name: %v", kind, obj.GetName())
+		return nil, nil
+	}
+
+	client, _, err := c.resourceClient(resource)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting the resource mapping - kind: %v", kind)
+	}
+	live, err := client.Get(c.ctx, obj.GetName(), apiMetaV1.GetOptions{})
+	switch {
+	case apiErrors.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "error getting resource : %v, name: %v", kind, obj.GetName())
+	}
+	return live, nil
+}
+
+// writeStuckReport writes why the object is still not deleted.
+// It lists the finalizers that block the deletion and the conditions of the object that explain it,
+// like the NamespaceContentRemaining and NamespaceFinalizersRemaining conditions of a namespace.
+func writeStuckReport(w io.Writer, live *unstructured.Unstructured) {
+	name := live.GetName()
+	if live.GetNamespace() != "" {
+		name = live.GetNamespace() + "/" + name
+	}
+	fmt.Fprintf(w, "\n%v:%v is not deleted:\n", strings.ToLower(live.GetKind()), name)
+
+	if ts := live.GetDeletionTimestamp(); ts != nil {
+		fmt.Fprintf(w, "  deletion requested at %v\n", ts.UTC().Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(w, "  no deletion requested, it was probably recreated")
+	}
+
+	finalizers := append([]string{}, live.GetFinalizers()...)
+	if spec, _, _ := unstructured.NestedStringSlice(live.Object, "spec", "finalizers"); len(spec) > 0 {
+		// Namespaces keep the finalizers of the api server in the spec.
+		finalizers = append(finalizers, spec...)
+	}
+	if len(finalizers) > 0 {
+		fmt.Fprintf(w, "  finalizers: %v\n", strings.Join(finalizers, ", "))
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["status"] != "True" {
+			continue
+		}
+		fmt.Fprintf(w, "  condition %v: %v\n", cond["type"], cond["message"])
+	}
+}
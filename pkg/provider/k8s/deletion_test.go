@@ -0,0 +1,136 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParsePropagationPolicy(t *testing.T) {
+	for name, want := range map[string]apiMetaV1.DeletionPropagation{
+		"foreground": apiMetaV1.DeletePropagationForeground,
+		"Background": apiMetaV1.DeletePropagationBackground,
+		"orphan":     apiMetaV1.DeletePropagationOrphan,
+	} {
+		got, err := ParsePropagationPolicy(name)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("%v: expected %v, got %v", name, want, got)
+		}
+	}
+	if _, err := ParsePropagationPolicy("cascade"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestNamespaceDelete(t *testing.T) {
+	f, c := newFakeCluster(t, &apiCoreV1.Namespace{
+		TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: apiMetaV1.ObjectMeta{Name: "prombench-1"},
+	})
+	c.DeleteTimeout = time.Minute
+
+	if err := c.NamespaceDelete("prombench-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.clt.CoreV1().Namespaces().Get(context.Background(), "prombench-1", apiMetaV1.GetOptions{}); !apiErrors.IsNotFound(err) {
+		t.Errorf("expected the namespace to be deleted, got: %v", err)
+	}
+
+	// Namespaces that are already gone are not an error so destroy can be run again.
+	if err := c.NamespaceDelete("prombench-1"); err != nil {
+		t.Errorf("unexpected error for a missing namespace: %v", err)
+	}
+}
+
+func TestWriteStuckReport(t *testing.T) {
+	deleted := apiMetaV1.NewTime(time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC))
+
+	ns := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "prombench-1"},
+		"spec":       map[string]interface{}{"finalizers": []interface{}{"kubernetes"}},
+		"status": map[string]interface{}{
+			"phase": "Terminating",
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "NamespaceDeletionDiscoveryFailure", "status": "False", "message": "All resources successfully discovered"},
+				map[string]interface{}{"type": "NamespaceContentRemaining", "status": "True", "message": "Some resources are remaining: widgets.example.com has 1 resource instances"},
+				map[string]interface{}{"type": "NamespaceFinalizersRemaining", "status": "True", "message": "Some content in the namespace has finalizers remaining: example.com/cleanup in 1 resource instances"},
+			},
+		},
+	}}
+	ns.SetDeletionTimestamp(&deleted)
+
+	pvc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "PersistentVolumeClaim",
+		"metadata":   map[string]interface{}{"name": "data", "namespace": "prombench-1"},
+	}}
+	pvc.SetFinalizers([]string{"kubernetes.io/pvc-protection"})
+	pvc.SetDeletionTimestamp(&deleted)
+
+	recreated := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config", "namespace": "default"},
+	}}
+
+	for _, tc := range []struct {
+		live     *unstructured.Unstructured
+		expected string
+	}{
+		{
+			live: ns,
+			expected: `
+namespace:prombench-1 is not deleted:
+  deletion requested at 2020-07-01T10:00:00Z
+  finalizers: kubernetes
+  condition NamespaceContentRemaining: Some resources are remaining: widgets.example.com has 1 resource instances
+  condition NamespaceFinalizersRemaining: Some content in the namespace has finalizers remaining: example.com/cleanup in 1 resource instances
+`,
+		},
+		{
+			live: pvc,
+			expected: `
+persistentvolumeclaim:prombench-1/data is not deleted:
+  deletion requested at 2020-07-01T10:00:00Z
+  finalizers: kubernetes.io/pvc-protection
+`,
+		},
+		{
+			live: recreated,
+			expected: `
+configmap:default/config is not deleted:
+  no deletion requested, it was probably recreated
+`,
+		},
+	} {
+		var b strings.Builder
+		writeStuckReport(&b, tc.live)
+		if b.String() != tc.expected {
+			t.Errorf("%v: expected report:\n%v\ngot:\n%v", tc.live.GetName(), tc.expected, b.String())
+		}
+	}
+}
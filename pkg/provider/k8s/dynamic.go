@@ -0,0 +1,114 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+// mappingBackoff sets how long to wait for a kind to be served by the api server,
+// for example right after creating its CRD.
+var mappingBackoff = provider.Backoff{
+	Initial: 5 * time.Second,
+	Factor:  1,
+	Timeout: time.Minute,
+}
+
+// waitResourceClient is like resourceClient, but when the api server doesn't know the kind yet
+// it refreshes the discovery cache and retries until the kind is served or the context is cancelled.
+func (c *K8s) waitResourceClient(resource runtime.Object) (dynamic.ResourceInterface, *meta.RESTMapping, error) {
+	client, mapping, err := c.resourceClient(resource)
+	if err == nil || !meta.IsNoMatchError(err) {
+		return client, mapping, err
+	}
+	name := fmt.Sprintf("kind %v to be served", resource.GetObjectKind().GroupVersionKind())
+	err = provider.RetryUntilTrue(c.ctx, name, mappingBackoff, func() (bool, error) {
+		if m, ok := c.mapper.(interface{ Reset() }); ok {
+			m.Reset()
+		}
+		var err error
+		client, mapping, err = c.resourceClient(resource)
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return client, mapping, err
+}
+
+func toUnstructured(resource runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := resource.(*unstructured.Unstructured); ok {
+		return u.DeepCopy(), nil
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+// dynamicApply creates or updates, with server-side apply, an object of any kind that the api server knows about.
+func (c *K8s) dynamicApply(resource runtime.Object) error {
+	kind := resource.GetObjectKind().GroupVersionKind().Kind
+	client, _, err := c.waitResourceClient(resource)
+	if err != nil {
+		return errors.Wrapf(err, "getting the resource mapping - kind: %v", kind)
+	}
+	req, err := toUnstructured(resource)
+	if err != nil {
+		return errors.Wrapf(err, "converting the resource - kind: %v", kind)
+	}
+
+	data, err := applyPatch(req)
+	if err != nil {
+		return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.GetName())
+	}
+	if _, err := client.Patch(c.ctx, req.GetName(), types.ApplyPatchType, data, c.applyOptions()); err != nil {
+		return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.GetName())
+	}
+	log.Printf("resource applied - kind: %v, name: %v", kind, req.GetName())
+	return nil
+}
+
+// dynamicDelete deletes an object of any kind that the api server knows about.
+func (c *K8s) dynamicDelete(resource runtime.Object) error {
+	kind := resource.GetObjectKind().GroupVersionKind().Kind
+	client, _, err := c.resourceClient(resource)
+	if err != nil {
+		return errors.Wrapf(err, "getting the resource mapping - kind: %v", kind)
+	}
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return fmt.Errorf("reading the object metadata - kind: %v err:%v", kind, err)
+	}
+
+	delPolicy := c.DeletePropagation
+	if err := client.Delete(c.ctx, obj.GetName(), apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
+		return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, obj.GetName())
+	}
+	log.Printf("resource deleted - kind: %v , name: %v", kind, obj.GetName())
+	return nil
+}
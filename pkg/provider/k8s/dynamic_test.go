@@ -0,0 +1,94 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	policyV1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+func TestDecodeUnregisteredKinds(t *testing.T) {
+	resources, err := DecodeResources([]provider.Resource{{
+		FileName: "monitoring.yaml",
+		Content: []byte(`
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: prometheus
+  namespace: prombench
+spec:
+  endpoints:
+  - port: web
+---
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: prometheus
+spec:
+  minAvailable: 1
+`),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := resources[0].Objects
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(objects))
+	}
+
+	sm, ok := objects[0].(*unstructured.Unstructured)
+	if !ok {
+		t.Fatalf("expected the custom resource to be decoded as unstructured, got %T", objects[0])
+	}
+	if sm.GetKind() != "ServiceMonitor" || sm.GetNamespace() != "prombench" || sm.GetName() != "prometheus" {
+		t.Errorf("unexpected custom resource %v/%v %v", sm.GetNamespace(), sm.GetName(), sm.GetKind())
+	}
+	if _, ok := objects[1].(*policyV1beta1.PodDisruptionBudget); !ok {
+		t.Errorf("expected a kind from the client-go scheme to be decoded as typed, got %T", objects[1])
+	}
+
+	u, err := toUnstructured(objects[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.GetKind() != "PodDisruptionBudget" || u.GetAPIVersion() != "policy/v1beta1" {
+		t.Errorf("the type meta must be kept when converting, got %v %v", u.GetAPIVersion(), u.GetKind())
+	}
+}
+
+func TestWaitResourceClientCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &K8s{mapper: meta.NewDefaultRESTMapper(nil), ctx: ctx}
+
+	sm := &unstructured.Unstructured{}
+	sm.SetAPIVersion("monitoring.coreos.com/v1")
+	sm.SetKind("ServiceMonitor")
+	sm.SetName("prometheus")
+
+	start := time.Now()
+	if _, _, err := c.waitResourceClient(sm); err == nil {
+		t.Fatal("expected an error for a kind that is not served")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected to stop waiting when the context is cancelled, waited %v", d)
+	}
+}
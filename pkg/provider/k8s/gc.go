@@ -0,0 +1,43 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/test-infra/pkg/provider"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BenchmarkNamespaces returns the namespaces of the cluster that were created for the benchmark of a pull request.
+func (c *K8s) BenchmarkNamespaces() ([]provider.BenchmarkObject, error) {
+	namespaces, err := c.clt.CoreV1().Namespaces().List(c.ctx, apiMetaV1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing the namespaces")
+	}
+	var objects []provider.BenchmarkObject
+	for _, ns := range namespaces.Items {
+		if o, ok := provider.NewBenchmarkObject(provider.StateNamespace, ns.Name, ns.CreationTimestamp.Time); ok {
+			objects = append(objects, o)
+		}
+	}
+	return objects, nil
+}
+
+// GCNamespace deletes a stale benchmark namespace and removes it from the state file.
+func (c *Base) GCNamespace(name string) error {
+	if err := c.K8sProvider.NamespaceDelete(name); err != nil {
+		return err
+	}
+	return c.Forget(provider.StateEntry{Kind: provider.StateNamespace, Name: name, Cluster: c.DeploymentVars["CLUSTER_NAME"]})
+}
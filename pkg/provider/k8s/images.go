@@ -0,0 +1,71 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"strings"
+
+	apiCoreV1 "k8s.io/api/core/v1"
+)
+
+// SetImagePullPolicy sets the pull policy of the containers that run one of the images
+// and returns how many containers were changed.
+// Images are compared the way docker does so "prom/prometheus" matches "docker.io/prom/prometheus:latest".
+func SetImagePullPolicy(resources []Resource, images []string, policy apiCoreV1.PullPolicy) int {
+	match := make(map[string]bool, len(images))
+	for _, image := range images {
+		match[NormalizeImage(image)] = true
+	}
+
+	changed := 0
+	for _, r := range resources {
+		for _, resource := range r.Objects {
+			spec := podSpec(resource)
+			if spec == nil {
+				continue
+			}
+			for _, containers := range [][]apiCoreV1.Container{spec.InitContainers, spec.Containers} {
+				for i := range containers {
+					if match[NormalizeImage(containers[i].Image)] && containers[i].ImagePullPolicy != policy {
+						containers[i].ImagePullPolicy = policy
+						changed++
+					}
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// NormalizeImage returns the fully qualified name of the image,
+// with the default registry and the latest tag when they are omitted.
+func NormalizeImage(image string) string {
+	name, digest := image, ""
+	if i := strings.Index(image, "@"); i >= 0 {
+		name, digest = image[:i], image[i:]
+	}
+	if digest == "" && strings.LastIndex(name, ":") <= strings.LastIndex(name, "/") {
+		name += ":latest"
+	}
+
+	// The first part of the name is a registry only when it looks like a host.
+	parts := strings.SplitN(name, "/", 2)
+	switch {
+	case len(parts) == 1:
+		name = "docker.io/library/" + name
+	case !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost":
+		name = "docker.io/" + name
+	}
+	return name + digest
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"testing"
+
+	appsV1 "k8s.io/api/apps/v1"
+	apiCoreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNormalizeImage(t *testing.T) {
+	for image, expected := range map[string]string{
+		"busybox":                          "docker.io/library/busybox:latest",
+		"prom/prometheus":                  "docker.io/prom/prometheus:latest",
+		"prom/prometheus:v2.20.0":          "docker.io/prom/prometheus:v2.20.0",
+		"docker.io/prom/prometheus:pr-123": "docker.io/prom/prometheus:pr-123",
+		"quay.io/prometheus/prometheus":    "quay.io/prometheus/prometheus:latest",
+		"localhost:5000/prometheus:dev":    "localhost:5000/prometheus:dev",
+		"localhost/prometheus":             "localhost/prometheus:latest",
+		"prom/prometheus@sha256:abc":       "docker.io/prom/prometheus@sha256:abc",
+	} {
+		if got := NormalizeImage(image); got != expected {
+			t.Errorf("%v: expected %v, got %v", image, expected, got)
+		}
+	}
+}
+
+func TestSetImagePullPolicy(t *testing.T) {
+	deployment := &appsV1.Deployment{}
+	deployment.Spec.Template.Spec = apiCoreV1.PodSpec{
+		InitContainers: []apiCoreV1.Container{{Name: "init", Image: "docker.io/prom/prometheus:dev"}},
+		Containers: []apiCoreV1.Container{
+			{Name: "prometheus", Image: "prom/prometheus:dev", ImagePullPolicy: apiCoreV1.PullAlways},
+			{Name: "sidecar", Image: "prom/prometheus:v2.20.0", ImagePullPolicy: apiCoreV1.PullAlways},
+		},
+	}
+	resources := []Resource{{FileName: "prometheus.yaml", Objects: []runtime.Object{deployment, &apiCoreV1.Service{}}}}
+
+	if changed := SetImagePullPolicy(resources, []string{"prom/prometheus:dev"}, apiCoreV1.PullIfNotPresent); changed != 2 {
+		t.Errorf("expected 2 changed containers, got %v", changed)
+	}
+	spec := deployment.Spec.Template.Spec
+	for _, c := range []struct {
+		container apiCoreV1.Container
+		expected  apiCoreV1.PullPolicy
+	}{
+		{spec.InitContainers[0], apiCoreV1.PullIfNotPresent},
+		{spec.Containers[0], apiCoreV1.PullIfNotPresent},
+		{spec.Containers[1], apiCoreV1.PullAlways},
+	} {
+		if c.container.ImagePullPolicy != c.expected {
+			t.Errorf("%v: expected pull policy %v, got %v", c.container.Name, c.expected, c.container.ImagePullPolicy)
+		}
+	}
+
+	if changed := SetImagePullPolicy(resources, []string{"prom/prometheus:dev"}, apiCoreV1.PullIfNotPresent); changed != 0 {
+		t.Errorf("expected no changes the second time, got %v", changed)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -26,13 +27,18 @@ import (
 	apiExtensionsV1beta1 "k8s.io/api/extensions/v1beta1"
 	rbac "k8s.io/api/rbac/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/restmapper"
 
 	"strings"
 
@@ -53,13 +59,18 @@ func init() {
 // Resource holds the resource objects after parsing deployment files.
 type Resource struct {
 	FileName string
-	Objects  []runtime.Object
+	// FileSet identifies the deployment files passed with -f that the file was parsed from, see ResourcePrune.
+	FileSet string
+	Objects []runtime.Object
 }
 
 // K8s holds the fields used to generate API request from within a cluster.
 type K8s struct {
-	clt          *kubernetes.Clientset
-	ApiExtClient *apiServerExtensionsClient.Clientset
+	clt          kubernetes.Interface
+	ApiExtClient apiServerExtensionsClient.Interface
+	// The dynamic client and the mapper are used for operations that work with any kind of object.
+	dynamicClt dynamic.Interface
+	mapper     meta.RESTMapper
 	// DeploymentFiles files provided from the cli.
 	DeploymentFiles []string
 	// Variables to substitute in the DeploymentFiles.
@@ -67,6 +78,16 @@ type K8s struct {
 	DeploymentVars map[string]string
 	// K8s resource.runtime objects after parsing the template variables, grouped by filename.
 	resources []Resource
+	// Parallelism is the max number of objects in the same tier that are applied or deleted at the same time.
+	Parallelism int
+	// ReadyTimeout is how long ResourceApply waits for all workloads to finish their rollout.
+	ReadyTimeout time.Duration
+	// FieldManager is the field manager of the server-side apply requests made by ResourceApply.
+	FieldManager string
+	// DeletePropagation is the propagation policy used when deleting objects.
+	DeletePropagation apiMetaV1.DeletionPropagation
+	// DeleteTimeout is how long ResourceDelete waits for all deleted objects to be gone.
+	DeleteTimeout time.Duration
 
 	ctx context.Context
 }
@@ -83,7 +104,12 @@ func New(ctx context.Context, config *clientcmdapi.Config) (*K8s, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "k8s config error")
 	}
+	return NewWithRESTConfig(ctx, restConfig)
+}
 
+// NewWithRESTConfig returns a k8s client for the rest config,
+// for example one with a WrapTransport that sets credentials which need to be refreshed.
+func NewWithRESTConfig(ctx context.Context, restConfig *rest.Config) (*K8s, error) {
 	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return nil, errors.Wrapf(err, "k8s client error")
@@ -94,12 +120,32 @@ func New(ctx context.Context, config *clientcmdapi.Config) (*K8s, error) {
 		return nil, errors.Wrapf(err, "k8s api extensions client error")
 	}
 
+	dynamicClientset, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "k8s dynamic client error")
+	}
+
+	return NewWithClients(ctx, clientset, apiExtClientset, dynamicClientset), nil
+}
+
+// NewWithClients returns a k8s client that uses the given clientsets,
+// for example the fake clientsets of client-go in tests.
+// The kinds served by the api server are discovered through clt.
+func NewWithClients(ctx context.Context, clt kubernetes.Interface, apiExtClt apiServerExtensionsClient.Interface, dynamicClt dynamic.Interface) *K8s {
 	return &K8s{
 		ctx:            ctx,
-		clt:            clientset,
-		ApiExtClient:   apiExtClientset,
+		clt:            clt,
+		ApiExtClient:   apiExtClt,
+		dynamicClt:     dynamicClt,
+		mapper:         restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clt.Discovery())),
 		DeploymentVars: make(map[string]string),
-	}, nil
+		Parallelism:    DefaultParallelism,
+		ReadyTimeout:   DefaultReadyTimeout,
+		FieldManager:   DefaultFieldManager,
+
+		DeletePropagation: apiMetaV1.DeletePropagationForeground,
+		DeleteTimeout:     DefaultDeleteTimeout,
+	}
 }
 
 // GetResources is a getter function for Resources field in K8s.
@@ -107,6 +153,28 @@ func (c *K8s) GetResources() []Resource {
 	return c.resources
 }
 
+// resourceClient returns a dynamic client for the kind of the object.
+// Namespaced objects without a namespace use the default namespace.
+func (c *K8s) resourceClient(resource runtime.Object) (dynamic.ResourceInterface, *meta.RESTMapping, error) {
+	gvk := resource.GetObjectKind().GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return c.dynamicClt.Resource(mapping.Resource), mapping, nil
+	}
+
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(obj.GetNamespace()) == 0 {
+		obj.SetNamespace("default")
+	}
+	return c.dynamicClt.Resource(mapping.Resource).Namespace(obj.GetNamespace()), mapping, nil
+}
+
 // DeploymentsParse parses the k8s objects deployment files and saves the result as k8s objects grouped by the filename.
 // Any variables passed to the cli will be replaced in the resources files following the golang text template format.
 func (c *K8s) DeploymentsParse(*kingpin.ParseContext) error {
@@ -115,136 +183,176 @@ func (c *K8s) DeploymentsParse(*kingpin.ParseContext) error {
 		log.Fatalf("Couldn't parse deployment files: %v", err)
 	}
 
-	for _, deployment := range deploymentResource {
-
-		decode := scheme.Codecs.UniversalDeserializer().Decode
-		k8sObjects := make([]runtime.Object, 0)
-
-		for _, text := range strings.Split(string(deployment.Content), provider.Separator) {
-			text = strings.TrimSpace(text)
-			if len(text) == 0 {
-				continue
-			}
+	resources, err := DecodeResources(deploymentResource)
+	if err != nil {
+		return err
+	}
+	setFileSet(resources, c.DeploymentFiles)
+	c.resources = append(c.resources, resources...)
+	return nil
+}
 
-			resource, _, err := decode([]byte(text), nil, nil)
-			if err != nil {
-				return errors.Wrapf(err, "decoding the resource file:%v, section:%v...", deployment.FileName, text[:100])
+// ResourceApply applies k8s objects.
+// The input is a slice of structs containing the filename and the slice of k8s objects present in the file.
+// The objects are applied in the tiers returned by ApplyOrder and the objects in the same tier are applied in parallel.
+// The next tier starts after the workloads in the tier have finished their rollout, see WaitReady.
+// Every object is labelled as owned by the infra tool with its file set and annotated with the file it came from, see ResourcePrune.
+func (c *K8s) ResourceApply(deployments []Resource) error {
+	deadline := time.Now().Add(c.ReadyTimeout)
+	for _, tier := range ApplyOrder(deployments) {
+		if err := forEach(tier, c.Parallelism, func(o Object) error {
+			if err := setOwnership(o.Object, o.FileName, o.FileSet); err != nil {
+				return fmt.Errorf("error applying '%v' err:%v", o.FileName, err)
 			}
-			if resource == nil {
-				continue
+			if err := c.objectApply(o.Object); err != nil {
+				if apiErrors.IsConflict(errors.Cause(err)) {
+					return fmt.Errorf("error applying '%v', leave the fields managed by other writers out of the file err:%v", o.FileName, err)
+				}
+				return fmt.Errorf("error applying '%v' err:%v", o.FileName, err)
 			}
-			k8sObjects = append(k8sObjects, resource)
+			return nil
+		}); err != nil {
+			return err
 		}
-		if len(k8sObjects) > 0 {
-			c.resources = append(c.resources, Resource{FileName: deployment.FileName, Objects: k8sObjects})
+		if err := c.WaitReady(tier, deadline); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// ResourceApply applies k8s objects.
-// The input is a slice of structs containing the filename and the slice of k8s objects present in the file.
-func (c *K8s) ResourceApply(deployments []Resource) error {
-
-	var err error
+// DeploymentsScale sets the replicas of the deployments and waits for their rollout.
+// The apply requests only have the name and the replicas of every deployment,
+// so the field manager only owns the replicas and the other fields are left to the manifest files.
+func (c *K8s) DeploymentsScale(deployments []Resource, replicas int32) error {
+	var scaled []Object
 	for _, deployment := range deployments {
 		for _, resource := range deployment.Objects {
-			switch kind := strings.ToLower(resource.GetObjectKind().GroupVersionKind().Kind); kind {
-			case "clusterrole":
-				err = c.clusterRoleApply(resource)
-			case "clusterrolebinding":
-				err = c.clusterRoleBindingApply(resource)
-			case "configmap":
-				err = c.configMapApply(resource)
-			case "daemonset":
-				err = c.daemonSetApply(resource)
-			case "deployment":
-				err = c.deploymentApply(resource)
-			case "ingress":
-				err = c.ingressApply(resource)
-			case "namespace":
-				err = c.nameSpaceApply(resource)
-			case "role":
-				err = c.roleApply(resource)
-			case "rolebinding":
-				err = c.roleBindingApply(resource)
-			case "service":
-				err = c.serviceApply(resource)
-			case "serviceaccount":
-				err = c.serviceAccountApply(resource)
-			case "secret":
-				err = c.secretApply(resource)
-			case "persistentvolumeclaim":
-				err = c.persistentVolumeClaimApply(resource)
-			case "customresourcedefinition":
-				err = c.customResourceApply(resource)
-			case "statefulset":
-				err = c.statefulSetApply(resource)
-			case "job":
-				err = c.jobApply(resource)
-			default:
-				err = fmt.Errorf("creating request for unimplimented resource type:%v", kind)
+			req, ok := resource.(*appsV1.Deployment)
+			if !ok {
+				continue
 			}
-			if err != nil {
-				return fmt.Errorf("error applying '%v' err:%v", deployment.FileName, err)
+			scale := &appsV1.Deployment{
+				TypeMeta:   req.TypeMeta,
+				ObjectMeta: apiMetaV1.ObjectMeta{Name: req.Name, Namespace: req.Namespace},
+				Spec:       appsV1.DeploymentSpec{Replicas: &replicas},
 			}
+			if err := c.deploymentApply(scale); err != nil {
+				return fmt.Errorf("error scaling '%v' err:%v", deployment.FileName, err)
+			}
+			scaled = append(scaled, Object{FileName: deployment.FileName, FileSet: deployment.FileSet, Object: req})
 		}
 	}
-	return nil
+	return c.WaitReady(scaled, time.Now().Add(c.ReadyTimeout))
+}
+
+func (c *K8s) objectApply(resource runtime.Object) error {
+	if _, ok := resource.(*unstructured.Unstructured); ok {
+		return c.dynamicApply(resource)
+	}
+	switch kind := strings.ToLower(resource.GetObjectKind().GroupVersionKind().Kind); kind {
+	case "clusterrole":
+		return c.clusterRoleApply(resource)
+	case "clusterrolebinding":
+		return c.clusterRoleBindingApply(resource)
+	case "configmap":
+		return c.configMapApply(resource)
+	case "daemonset":
+		return c.daemonSetApply(resource)
+	case "deployment":
+		return c.deploymentApply(resource)
+	case "ingress":
+		return c.ingressApply(resource)
+	case "namespace":
+		return c.nameSpaceApply(resource)
+	case "role":
+		return c.roleApply(resource)
+	case "rolebinding":
+		return c.roleBindingApply(resource)
+	case "service":
+		return c.serviceApply(resource)
+	case "serviceaccount":
+		return c.serviceAccountApply(resource)
+	case "secret":
+		return c.secretApply(resource)
+	case "persistentvolumeclaim":
+		return c.persistentVolumeClaimApply(resource)
+	case "customresourcedefinition":
+		return c.customResourceApply(resource)
+	case "statefulset":
+		return c.statefulSetApply(resource)
+	case "job":
+		return c.jobApply(resource)
+	default:
+		return c.dynamicApply(resource)
+	}
 }
 
 // ResourceDelete deletes k8s objects.
 // The input is a slice of structs containing the filename and the slice of k8s objects present in the file.
+// The objects are deleted in the tiers returned by DeleteOrder and the objects in the same tier are deleted in parallel.
+// The next tier starts after all objects of the tier are gone from the cluster,
+// and all of them must be gone within DeleteTimeout.
 func (c *K8s) ResourceDelete(deployments []Resource) error {
-
-	var err error
-	for _, deployment := range deployments {
-		for _, resource := range deployment.Objects {
-			switch kind := strings.ToLower(resource.GetObjectKind().GroupVersionKind().Kind); kind {
-			case "clusterrole":
-				err = c.clusterRoleDelete(resource)
-			case "clusterrolebinding":
-				err = c.clusterRoleBindingDelete(resource)
-			case "configmap":
-				err = c.configMapDelete(resource)
-			case "daemonset":
-				err = c.daemonsetDelete(resource)
-			case "deployment":
-				err = c.deploymentDelete(resource)
-			case "ingress":
-				err = c.ingressDelete(resource)
-			case "namespace":
-				err = c.namespaceDelete(resource)
-			case "role":
-				err = c.roleDelete(resource)
-			case "rolebinding":
-				err = c.roleBindingDelete(resource)
-			case "service":
-				err = c.serviceDelete(resource)
-			case "serviceaccount":
-				err = c.serviceAccountDelete(resource)
-			case "secret":
-				err = c.secretDelete(resource)
-			case "persistentvolumeclaim":
-				err = c.persistentVolumeClaimDelete(resource)
-			case "customresourcedefinition":
-				err = c.customResourceDelete(resource)
-			case "statefulset":
-				err = c.statefulSetDelete(resource)
-			case "job":
-				err = c.jobDelete(resource)
-			default:
-				err = fmt.Errorf("deleting request for unimplimented resource type:%v", kind)
-			}
-			if err != nil {
-				return fmt.Errorf("error deleting '%v' err:%v", deployment.FileName, err)
+	deadline := time.Now().Add(c.DeleteTimeout)
+	for _, tier := range DeleteOrder(deployments) {
+		if err := forEach(tier, c.Parallelism, func(o Object) error {
+			if err := c.objectDelete(o.Object); err != nil {
+				return fmt.Errorf("error deleting '%v' err:%v", o.FileName, err)
 			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := c.WaitDeleted(tier, deadline); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// Functions to create different K8s objects.
+func (c *K8s) objectDelete(resource runtime.Object) error {
+	if _, ok := resource.(*unstructured.Unstructured); ok {
+		return c.dynamicDelete(resource)
+	}
+	switch kind := strings.ToLower(resource.GetObjectKind().GroupVersionKind().Kind); kind {
+	case "clusterrole":
+		return c.clusterRoleDelete(resource)
+	case "clusterrolebinding":
+		return c.clusterRoleBindingDelete(resource)
+	case "configmap":
+		return c.configMapDelete(resource)
+	case "daemonset":
+		return c.daemonsetDelete(resource)
+	case "deployment":
+		return c.deploymentDelete(resource)
+	case "ingress":
+		return c.ingressDelete(resource)
+	case "namespace":
+		return c.namespaceDelete(resource)
+	case "role":
+		return c.roleDelete(resource)
+	case "rolebinding":
+		return c.roleBindingDelete(resource)
+	case "service":
+		return c.serviceDelete(resource)
+	case "serviceaccount":
+		return c.serviceAccountDelete(resource)
+	case "secret":
+		return c.secretDelete(resource)
+	case "persistentvolumeclaim":
+		return c.persistentVolumeClaimDelete(resource)
+	case "customresourcedefinition":
+		return c.customResourceDelete(resource)
+	case "statefulset":
+		return c.statefulSetDelete(resource)
+	case "job":
+		return c.jobDelete(resource)
+	default:
+		return c.dynamicDelete(resource)
+	}
+}
+
 func (c *K8s) clusterRoleApply(resource runtime.Object) error {
 	req := resource.(*rbac.ClusterRole)
 	kind := resource.GetObjectKind().GroupVersionKind().Kind
@@ -252,37 +360,18 @@ func (c *K8s) clusterRoleApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().ClusterRoles()
-
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "listing resource : %v", kind)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
-		return nil
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
+	return nil
 }
 
 func (c *K8s) clusterRoleBindingApply(resource runtime.Object) error {
@@ -292,32 +381,14 @@ func (c *K8s) clusterRoleBindingApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().ClusterRoleBindings()
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -333,35 +404,15 @@ func (c *K8s) configMapApply(resource runtime.Object) error {
 
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
-
 		client := c.clt.CoreV1().ConfigMaps(req.Namespace)
-
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -378,36 +429,18 @@ func (c *K8s) daemonSetApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.AppsV1().DaemonSets(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
-		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
-	return c.daemonsetReady(resource)
+	return nil
 }
 
 func (c *K8s) deploymentApply(resource runtime.Object) error {
@@ -420,40 +453,18 @@ func (c *K8s) deploymentApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.AppsV1().Deployments(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-		} else {
-			if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-				return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
-	return provider.RetryUntilTrue(
-		fmt.Sprintf("applying deployment:%v", req.Name),
-		provider.GlobalRetryCount,
-		func() (bool, error) { return c.deploymentReady(resource) })
+	return nil
 }
 
 func (c *K8s) statefulSetApply(resource runtime.Object) error {
@@ -466,40 +477,18 @@ func (c *K8s) statefulSetApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.AppsV1().StatefulSets(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
-		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-		} else {
-			if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-				return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
-
-	return provider.RetryUntilTrue(
-		fmt.Sprintf("applying statefulSet:%v", req.Name),
-		provider.GlobalRetryCount,
-		func() (bool, error) { return c.statefulSetReady(resource) })
+	return nil
 }
 
 func (c *K8s) jobApply(resource runtime.Object) error {
@@ -512,75 +501,35 @@ func (c *K8s) jobApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.BatchV1().Jobs(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
-	const Infinite int = 1<<31 - 1
-	return provider.RetryUntilTrue(
-		fmt.Sprintf("running job:%v", req.Name),
-		Infinite,
-		func() (bool, error) { return c.jobReady(resource) })
+	return nil
 }
 
 func (c *K8s) customResourceApply(resource runtime.Object) error {
 	req := resource.(*apiServerExtensionsV1beta1.CustomResourceDefinition)
 	kind := resource.GetObjectKind().GroupVersionKind().Kind
-	if len(req.Namespace) == 0 {
-		req.Namespace = "default"
-	}
 
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1beta1":
 		client := c.ApiExtClient.ApiextensionsV1beta1().CustomResourceDefinitions()
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -598,32 +547,14 @@ func (c *K8s) ingressApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1beta1":
 		client := c.clt.ExtensionsV1beta1().Ingresses(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
-		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -637,33 +568,14 @@ func (c *K8s) nameSpaceApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().Namespaces()
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
-
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -680,32 +592,14 @@ func (c *K8s) roleApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().Roles(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -722,32 +616,14 @@ func (c *K8s) roleBindingApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().RoleBindings(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -764,32 +640,14 @@ func (c *K8s) serviceAccountApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().ServiceAccounts(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -806,48 +664,18 @@ func (c *K8s) serviceApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().Services(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				// Immutable fields must be set when updating.
-				// See https://github.com/kubernetes/kubernetes/pull/66602
-				if req.ResourceVersion == "" {
-					req.ResourceVersion = l.ResourceVersion
-				}
-				if req.Spec.ClusterIP == "" {
-					req.Spec.ClusterIP = l.Spec.ClusterIP
-				}
-				break
-			}
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
-
-	return provider.RetryUntilTrue(
-		fmt.Sprintf("applying service:%v", req.Name),
-		provider.GlobalRetryCount,
-		func() (bool, error) { return c.serviceExists(resource) })
+	return nil
 }
 
 func (c *K8s) secretApply(resource runtime.Object) error {
@@ -859,32 +687,14 @@ func (c *K8s) secretApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().Secrets(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
-		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -900,32 +710,14 @@ func (c *K8s) persistentVolumeClaimApply(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().PersistentVolumeClaims(req.Namespace)
-		list, err := client.List(c.ctx, apiMetaV1.ListOptions{})
+		data, err := applyPatch(req)
 		if err != nil {
-			return errors.Wrapf(err, "error listing resource : %v, name: %v", kind, req.Name)
+			return errors.Wrapf(err, "encoding resource - kind: %v, name: %v", kind, req.Name)
 		}
-
-		var exists bool
-		for _, l := range list.Items {
-			if l.Name == req.Name {
-				exists = true
-				break
-			}
+		if _, err := client.Patch(c.ctx, req.Name, types.ApplyPatchType, data, c.applyOptions()); err != nil {
+			return errors.Wrapf(err, "resource apply failed - kind: %v, name: %v", kind, req.Name)
 		}
-
-		if exists {
-			if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				_, err := client.Update(c.ctx, req, apiMetaV1.UpdateOptions{})
-				return err
-			}); err != nil {
-				return errors.Wrapf(err, "resource update failed - kind: %v, name: %v", kind, req.Name)
-			}
-			log.Printf("resource updated - kind: %v, name: %v", kind, req.Name)
-			return nil
-		} else if _, err := client.Create(c.ctx, req, apiMetaV1.CreateOptions{}); err != nil {
-			return errors.Wrapf(err, "resource creation failed - kind: %v, name: %v", kind, req.Name)
-		}
-		log.Printf("resource created - kind: %v, name: %v", kind, req.Name)
+		log.Printf("resource applied - kind: %v, name: %v", kind, req.Name)
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
@@ -940,7 +732,7 @@ func (c *K8s) clusterRoleDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().ClusterRoles()
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -958,7 +750,7 @@ func (c *K8s) clusterRoleBindingDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().ClusterRoleBindings()
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -978,7 +770,7 @@ func (c *K8s) configMapDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().ConfigMaps(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -999,7 +791,7 @@ func (c *K8s) daemonsetDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.AppsV1().DaemonSets(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1020,7 +812,7 @@ func (c *K8s) deploymentDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.AppsV1().Deployments(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1041,7 +833,7 @@ func (c *K8s) statefulSetDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.AppsV1().StatefulSets(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1062,7 +854,7 @@ func (c *K8s) jobDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.BatchV1().Jobs(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1083,7 +875,7 @@ func (c *K8s) customResourceDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1beta1":
 		client := c.ApiExtClient.ApiextensionsV1beta1().CustomResourceDefinitions()
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1105,7 +897,7 @@ func (c *K8s) ingressDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1beta1":
 		client := c.clt.ExtensionsV1beta1().Ingresses(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1123,18 +915,15 @@ func (c *K8s) namespaceDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().Namespaces()
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
 		log.Printf("resource deleting - kind: %v , name: %v", kind, req.Name)
-		return provider.RetryUntilTrue(
-			fmt.Sprintf("deleting namespace:%v", req.Name),
-			2*provider.GlobalRetryCount,
-			func() (bool, error) { return c.namespaceDeleted(resource) })
 	default:
 		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
 	}
+	return nil
 }
 
 func (c *K8s) roleDelete(resource runtime.Object) error {
@@ -1147,7 +936,7 @@ func (c *K8s) roleDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().Roles(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1168,7 +957,7 @@ func (c *K8s) roleBindingDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.RbacV1().RoleBindings(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1189,7 +978,7 @@ func (c *K8s) serviceDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().Services(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1210,7 +999,7 @@ func (c *K8s) serviceAccountDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().ServiceAccounts(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1230,7 +1019,7 @@ func (c *K8s) secretDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().Secrets(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1250,7 +1039,7 @@ func (c *K8s) persistentVolumeClaimDelete(resource runtime.Object) error {
 	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
 	case "v1":
 		client := c.clt.CoreV1().PersistentVolumeClaims(req.Namespace)
-		delPolicy := apiMetaV1.DeletePropagationForeground
+		delPolicy := c.DeletePropagation
 		if err := client.Delete(c.ctx, req.Name, apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy}); err != nil {
 			return errors.Wrapf(err, "resource delete failed - kind: %v, name: %v", kind, req.Name)
 		}
@@ -1260,176 +1049,3 @@ func (c *K8s) persistentVolumeClaimDelete(resource runtime.Object) error {
 	}
 	return nil
 }
-
-func (c *K8s) serviceExists(resource runtime.Object) (bool, error) {
-	req := resource.(*apiCoreV1.Service)
-	kind := resource.GetObjectKind().GroupVersionKind().Kind
-	if len(req.Namespace) == 0 {
-		req.Namespace = "default"
-	}
-
-	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
-	case "v1":
-		client := c.clt.CoreV1().Services(req.Namespace)
-		res, err := client.Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
-		if err != nil {
-			return false, errors.Wrapf(err, "Checking Service resource status failed")
-		}
-		if res.Spec.Type == apiCoreV1.ServiceTypeLoadBalancer {
-			// K8s API currently just supports LoadBalancerStatus.
-			if len(res.Status.LoadBalancer.Ingress) > 0 {
-				log.Printf("\tService %s Details", req.Name)
-				for _, x := range res.Status.LoadBalancer.Ingress {
-
-					ingressHostAddr := ""
-					if len(x.IP) != 0 {
-						ingressHostAddr = x.IP
-					} else {
-						ingressHostAddr = x.Hostname
-					}
-
-					log.Printf("\t\thttp://%s:%d", ingressHostAddr, res.Spec.Ports[0].Port)
-				}
-				return true, nil
-			}
-			return false, nil
-		}
-		// For any other type we blindly assume that it is up and running as we have no way of checking.
-		return true, nil
-	default:
-		return false, fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
-	}
-}
-
-func (c *K8s) deploymentReady(resource runtime.Object) (bool, error) {
-	req := resource.(*appsV1.Deployment)
-	kind := resource.GetObjectKind().GroupVersionKind().Kind
-	if len(req.Namespace) == 0 {
-		req.Namespace = "default"
-	}
-
-	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
-	case "v1":
-		client := c.clt.AppsV1().Deployments(req.Namespace)
-
-		res, err := client.Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
-		if err != nil {
-			return false, errors.Wrapf(err, "Checking Deployment resource:'%v' status failed err:%v", req.Name, err)
-		}
-
-		replicas := int32(1)
-		if req.Spec.Replicas != nil {
-			replicas = *req.Spec.Replicas
-		}
-		if res.Status.AvailableReplicas == replicas {
-			return true, nil
-		}
-		return false, nil
-	default:
-		return false, fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
-	}
-}
-
-func (c *K8s) statefulSetReady(resource runtime.Object) (bool, error) {
-	req := resource.(*appsV1.StatefulSet)
-	kind := resource.GetObjectKind().GroupVersionKind().Kind
-	if len(req.Namespace) == 0 {
-		req.Namespace = "default"
-	}
-
-	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
-	case "v1":
-		client := c.clt.AppsV1().StatefulSets(req.Namespace)
-
-		res, err := client.Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
-		if err != nil {
-			return false, errors.Wrapf(err, "Checking StatefulSet resource:'%v' status failed err:%v", req.Name, err)
-		}
-
-		replicas := int32(1)
-		if req.Spec.Replicas != nil {
-			replicas = *req.Spec.Replicas
-		}
-		if res.Status.ReadyReplicas == replicas {
-			return true, nil
-		}
-		return false, nil
-	default:
-		return false, fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
-	}
-}
-
-func (c *K8s) jobReady(resource runtime.Object) (bool, error) {
-	req := resource.(*batchV1.Job)
-	kind := resource.GetObjectKind().GroupVersionKind().Kind
-	if len(req.Namespace) == 0 {
-		req.Namespace = "default"
-	}
-
-	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
-	case "v1":
-		client := c.clt.BatchV1().Jobs(req.Namespace)
-
-		res, err := client.Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
-		if err != nil {
-			return false, errors.Wrapf(err, "Checking Job resource:'%v' status failed err:%v", req.Name, err)
-		}
-
-		// Current `jobReady` only works for non-parallel jobs.
-		// https://kubernetes.io/docs/concepts/workloads/controllers/jobs-run-to-completion/#parallel-jobs
-		count := int32(1)
-		if res.Status.Succeeded == count {
-			return true, nil
-		} else if res.Status.Failed == count {
-			return true, errors.New(fmt.Sprintf("Job %v has failed", req.Name))
-		}
-
-		return false, nil
-	default:
-		return false, fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
-	}
-}
-
-func (c *K8s) daemonsetReady(resource runtime.Object) error {
-	req := resource.(*appsV1.DaemonSet)
-	kind := resource.GetObjectKind().GroupVersionKind().Kind
-	if len(req.Namespace) == 0 {
-		req.Namespace = "default"
-	}
-
-	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
-	case "v1":
-		client := c.clt.AppsV1().DaemonSets(req.Namespace)
-
-		res, err := client.Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
-		if err != nil {
-			return errors.Wrapf(err, "Checking DaemonSet resource:'%v' status failed err:%v", req.Name, err)
-		}
-		if res.Status.NumberUnavailable == 0 {
-			return nil
-		}
-	default:
-		return fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
-	}
-	return nil
-}
-
-func (c *K8s) namespaceDeleted(resource runtime.Object) (bool, error) {
-	req := resource.(*apiCoreV1.Namespace)
-	kind := resource.GetObjectKind().GroupVersionKind().Kind
-
-	switch v := resource.GetObjectKind().GroupVersionKind().Version; v {
-	case "v1":
-		client := c.clt.CoreV1().Namespaces()
-
-		if _, err := client.Get(c.ctx, req.Name, apiMetaV1.GetOptions{}); err != nil {
-			if apiErrors.IsNotFound(err) {
-				return true, nil
-			}
-			return false, errors.Wrapf(err, "Couldn't get namespace '%v' err:%v", req.Name, err)
-		}
-		return false, nil
-	default:
-		return false, fmt.Errorf("unknown object version: %v kind:'%v', name:'%v'", v, kind, req.Name)
-	}
-}
@@ -0,0 +1,553 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	apiCoreV1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	apiExtFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	fakeDiscovery "k8s.io/client-go/discovery/fake"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+// apiResources are the resources served by the fake clientsets, used by the discovery of the rest mapper.
+var apiResources = []*apiMetaV1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []apiMetaV1.APIResource{
+			{Name: "namespaces", Kind: "Namespace", Verbs: []string{"list", "delete"}},
+			{Name: "nodes", Kind: "Node", Verbs: []string{"list", "delete"}},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "events", Kind: "Event", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "services", Kind: "Service", Namespaced: true, Verbs: []string{"list", "delete"}},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []apiMetaV1.APIResource{
+			{Name: "daemonsets", Kind: "DaemonSet", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true, Verbs: []string{"list", "delete"}},
+		},
+	},
+	{
+		GroupVersion: "batch/v1",
+		APIResources: []apiMetaV1.APIResource{
+			{Name: "jobs", Kind: "Job", Namespaced: true, Verbs: []string{"list", "delete"}},
+		},
+	},
+	{
+		GroupVersion: "rbac.authorization.k8s.io/v1",
+		APIResources: []apiMetaV1.APIResource{
+			{Name: "clusterroles", Kind: "ClusterRole", Verbs: []string{"list", "delete"}},
+			{Name: "roles", Kind: "Role", Namespaced: true, Verbs: []string{"list", "delete"}},
+		},
+	},
+}
+
+// fakeCluster backs a K8s client with the fake clientsets of client-go.
+// The typed and the dynamic fake clientsets keep their objects apart,
+// so store adds the objects to both and the deletions of the typed clientset are done in the dynamic one as well.
+// The fake clientsets don't support server-side apply, apply requests are only recorded.
+type fakeCluster struct {
+	clt     *fake.Clientset
+	dynamic *dynamicFake.FakeDynamicClient
+}
+
+// newFakeCluster returns a fake cluster with the given objects and a K8s client that uses it.
+func newFakeCluster(t *testing.T, objects ...runtime.Object) (*fakeCluster, *K8s) {
+	f := &fakeCluster{
+		clt:     fake.NewSimpleClientset(),
+		dynamic: dynamicFake.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+	f.clt.Resources = apiResources
+
+	applied := func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		return action.(k8sTesting.PatchAction).GetPatchType() == types.ApplyPatchType, nil, nil
+	}
+	f.clt.PrependReactor("patch", "*", applied)
+	f.dynamic.PrependReactor("patch", "*", applied)
+	f.clt.PrependReactor("delete", "*", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		d := action.(k8sTesting.DeleteAction)
+		err := f.dynamic.Resource(d.GetResource()).Namespace(d.GetNamespace()).Delete(context.Background(), d.GetName(), apiMetaV1.DeleteOptions{})
+		if err != nil && !apiErrors.IsNotFound(err) {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+
+	for _, obj := range objects {
+		f.store(t, obj)
+	}
+	return f, NewWithClients(context.Background(), fakeClientset{f.clt}, apiExtFake.NewSimpleClientset(), f.dynamic)
+}
+
+// store creates or updates the object in both fake clientsets.
+func (f *fakeCluster) store(t *testing.T, obj runtime.Object) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gvks[0])
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := &unstructured.Unstructured{Object: u}
+	live.SetGroupVersionKind(gvks[0])
+
+	ns := live.GetNamespace()
+	if err := f.clt.Tracker().Create(gvr, obj, ns); apiErrors.IsAlreadyExists(err) {
+		err = f.clt.Tracker().Update(gvr, obj, ns)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	client := f.dynamic.Resource(gvr).Namespace(ns)
+	if _, err := client.Create(context.Background(), live, apiMetaV1.CreateOptions{}); apiErrors.IsAlreadyExists(err) {
+		_, err = client.Update(context.Background(), live, apiMetaV1.UpdateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// patches returns the apply requests sent with both clientsets.
+func (f *fakeCluster) patches() []k8sTesting.PatchAction {
+	var patches []k8sTesting.PatchAction
+	for _, action := range append(f.clt.Actions(), f.dynamic.Actions()...) {
+		if p, ok := action.(k8sTesting.PatchAction); ok {
+			patches = append(patches, p)
+		}
+	}
+	return patches
+}
+
+// fakeClientset serves the preferred resources from the fake discovery, which leaves these empty.
+type fakeClientset struct {
+	*fake.Clientset
+}
+
+func (c fakeClientset) Discovery() discovery.DiscoveryInterface {
+	return preferredResourcesDiscovery{c.Clientset.Discovery().(*fakeDiscovery.FakeDiscovery)}
+}
+
+type preferredResourcesDiscovery struct {
+	*fakeDiscovery.FakeDiscovery
+}
+
+func (d preferredResourcesDiscovery) ServerPreferredNamespacedResources() ([]*apiMetaV1.APIResourceList, error) {
+	return discovery.ServerPreferredNamespacedResources(d)
+}
+
+var _ kubernetes.Interface = fakeClientset{}
+
+func TestApplyCreateAndUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		resource  schema.GroupVersionResource
+		namespace string
+		name      string
+		object    func(value string) runtime.Object
+	}{
+		{
+			resource:  schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			namespace: "default",
+			name:      "config",
+			object: func(value string) runtime.Object {
+				return &apiCoreV1.ConfigMap{
+					TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+					ObjectMeta: apiMetaV1.ObjectMeta{Name: "config", Labels: map[string]string{"value": value}},
+				}
+			},
+		},
+		{
+			resource:  schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			namespace: "prombench",
+			name:      "token",
+			object: func(value string) runtime.Object {
+				return &apiCoreV1.Secret{
+					TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+					ObjectMeta: apiMetaV1.ObjectMeta{Name: "token", Namespace: "prombench", Labels: map[string]string{"value": value}},
+				}
+			},
+		},
+		{
+			resource:  schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"},
+			namespace: "default",
+			name:      "prometheus",
+			object: func(value string) runtime.Object {
+				return &apiCoreV1.ServiceAccount{
+					TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+					ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus", Labels: map[string]string{"value": value}},
+				}
+			},
+		},
+		{
+			resource: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+			name:     "prometheus",
+			object: func(value string) runtime.Object {
+				return &rbac.ClusterRole{
+					TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+					ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus", Labels: map[string]string{"value": value}},
+				}
+			},
+		},
+		{
+			resource:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			namespace: "default",
+			name:      "prometheus",
+			object: func(value string) runtime.Object {
+				return &appsV1.Deployment{
+					TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+					ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus", Labels: map[string]string{"value": value}},
+				}
+			},
+		},
+		{
+			resource:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"},
+			namespace: "prombench",
+			name:      "prometheus",
+			object: func(value string) runtime.Object {
+				u := &unstructured.Unstructured{}
+				u.SetAPIVersion("apps/v1")
+				u.SetKind("StatefulSet")
+				u.SetNamespace("prombench")
+				u.SetName("prometheus")
+				u.SetLabels(map[string]string{"value": value})
+				return u
+			},
+		},
+	} {
+		t.Run(tc.resource.String(), func(t *testing.T) {
+			f, c := newFakeCluster(t)
+
+			// Creating and updating an object are the same apply request.
+			for i, value := range []string{"created", "updated"} {
+				if err := c.objectApply(tc.object(value)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				patches := f.patches()
+				if len(patches) != i+1 {
+					t.Fatalf("expected %d apply requests, got: %v", i+1, patches)
+				}
+				p := patches[i]
+				if p.GetResource() != tc.resource || p.GetNamespace() != tc.namespace || p.GetName() != tc.name || p.GetPatchType() != types.ApplyPatchType {
+					t.Errorf("expected an apply request for %v %v/%v, got: %v %v %v/%v", tc.resource, tc.namespace, tc.name, p.GetPatchType(), p.GetResource(), p.GetNamespace(), p.GetName())
+				}
+				var body unstructured.Unstructured
+				if err := json.Unmarshal(p.GetPatch(), &body.Object); err != nil {
+					t.Fatal(err)
+				}
+				if body.GetLabels()["value"] != value {
+					t.Errorf("expected the %v object, got: %v", value, body.GetLabels())
+				}
+			}
+		})
+	}
+}
+
+func TestResourceApplyOwnership(t *testing.T) {
+	f, c := newFakeCluster(t)
+
+	err := c.ResourceApply([]Resource{{
+		FileName: "manifests/config.yaml",
+		FileSet:  "0123456789abcdef",
+		Objects: []runtime.Object{&apiCoreV1.ConfigMap{
+			TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "config"},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	patches := f.patches()
+	if len(patches) != 1 {
+		t.Fatalf("expected 1 apply request, got: %v", patches)
+	}
+	var body unstructured.Unstructured
+	if err := json.Unmarshal(patches[0].GetPatch(), &body.Object); err != nil {
+		t.Fatal(err)
+	}
+	if v := body.GetLabels()[OwnerLabel]; v != OwnerLabelValue {
+		t.Errorf("expected the %v label to be %v, got: %v", OwnerLabel, OwnerLabelValue, v)
+	}
+	if v := body.GetLabels()[FileSetLabel]; v != "0123456789abcdef" {
+		t.Errorf("expected the %v label to be the file set, got: %v", FileSetLabel, v)
+	}
+	if v := body.GetAnnotations()[SourceFileAnnotation]; v != "manifests/config.yaml" {
+		t.Errorf("expected the %v annotation to be the file name, got: %v", SourceFileAnnotation, v)
+	}
+}
+
+func TestDeploymentsScale(t *testing.T) {
+	replicas := int32(3)
+	live := &appsV1.Deployment{
+		TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: apiMetaV1.ObjectMeta{Name: "fake-webserver", Namespace: "prombench", Generation: 1},
+		Spec: appsV1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &apiMetaV1.LabelSelector{MatchLabels: map[string]string{"app": "fake-webserver"}},
+		},
+		Status: appsV1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+	}
+	f, c := newFakeCluster(t, live)
+
+	manifest := live.DeepCopy()
+	manifest.Spec.Replicas = nil
+	err := c.DeploymentsScale([]Resource{{
+		FileName: "webserver.yaml",
+		Objects: []runtime.Object{manifest, &apiCoreV1.Service{
+			TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "fake-webserver", Namespace: "prombench"},
+		}},
+	}}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only the replicas are applied so that the other fields stay owned by the manifest files.
+	patches := f.patches()
+	if len(patches) != 1 {
+		t.Fatalf("expected 1 apply request, got: %v", patches)
+	}
+	expected := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"fake-webserver","namespace":"prombench"},"spec":{"replicas":3}}`
+	if string(patches[0].GetPatch()) != expected {
+		t.Errorf("expected the apply request %v, got: %s", expected, patches[0].GetPatch())
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	replicas := int32(1)
+	deployment := &appsV1.Deployment{
+		TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus", Namespace: "default", Generation: 1},
+		Spec: appsV1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &apiMetaV1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+		},
+		Status: appsV1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1},
+	}
+	objects := []Object{{FileName: "prometheus.yaml", Object: deployment}}
+
+	t.Run("ready after a status update", func(t *testing.T) {
+		f, c := newFakeCluster(t, deployment)
+
+		errc := make(chan error, 1)
+		go func() { errc <- c.WaitReady(objects, time.Now().Add(time.Minute)) }()
+
+		watching := func() bool {
+			for _, action := range f.dynamic.Actions() {
+				if action.GetVerb() == "watch" {
+					return true
+				}
+			}
+			return false
+		}
+		for i := 0; !watching() && i < 500; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		ready := deployment.DeepCopy()
+		ready.Status.AvailableReplicas = 1
+		f.store(t, ready)
+
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("the deployment update wasn't noticed")
+		}
+		for _, action := range f.dynamic.Actions() {
+			var selector string
+			switch a := action.(type) {
+			case k8sTesting.ListAction:
+				selector = a.GetListRestrictions().Fields.String()
+			case k8sTesting.WatchAction:
+				selector = a.GetWatchRestrictions().Fields.String()
+			default:
+				continue
+			}
+			if selector != "metadata.name=prometheus" {
+				t.Errorf("expected only the deployment to be listed and watched, got: %v %v with %q", action.GetVerb(), action.GetResource(), selector)
+			}
+		}
+	})
+
+	t.Run("not ready before the deadline", func(t *testing.T) {
+		_, c := newFakeCluster(t, deployment, &apiCoreV1.Pod{
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus-1", Namespace: "default", Labels: map[string]string{"app": "prometheus"}},
+			Status: apiCoreV1.PodStatus{
+				Phase: apiCoreV1.PodRunning,
+				ContainerStatuses: []apiCoreV1.ContainerStatus{{
+					Name:         "prometheus",
+					RestartCount: 4,
+					State:        apiCoreV1.ContainerState{Waiting: &apiCoreV1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				}},
+			},
+		})
+
+		err := c.WaitReady(objects, time.Now())
+		if errors.Cause(err) != ErrNotReady {
+			t.Fatalf("expected ErrNotReady, got: %v", err)
+		}
+		for _, expected := range []string{"deployment:default/prometheus is not ready", "0 of 1 updated replicas are available", "pod prometheus-1", "Waiting(CrashLoopBackOff)"} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected the error to contain %q, got: %v", expected, err)
+			}
+		}
+	})
+
+	t.Run("jobs have the same deadline", func(t *testing.T) {
+		job := &batchV1.Job{
+			TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "funcbench", Namespace: "default"},
+			Status:     batchV1.JobStatus{Active: 1},
+		}
+		_, c := newFakeCluster(t, job)
+
+		err := c.WaitReady([]Object{{FileName: "funcbench.yaml", Object: job}}, time.Now())
+		if errors.Cause(err) != ErrNotReady {
+			t.Fatalf("expected ErrNotReady, got: %v", err)
+		}
+		if expected := "job:default/funcbench is not ready"; !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain %q, got: %v", expected, err)
+		}
+	})
+}
+
+func TestUnknownVersion(t *testing.T) {
+	f, c := newFakeCluster(t)
+
+	for _, resource := range []runtime.Object{
+		&appsV1.Deployment{
+			TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "apps/v1beta9", Kind: "Deployment"},
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus"},
+		},
+		&apiCoreV1.ConfigMap{
+			TypeMeta:   apiMetaV1.TypeMeta{APIVersion: "v2", Kind: "ConfigMap"},
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "config"},
+		},
+	} {
+		for name, fn := range map[string]func(runtime.Object) error{"apply": c.objectApply, "delete": c.objectDelete} {
+			err := fn(resource)
+			if err == nil || !strings.Contains(err.Error(), "unknown object version") {
+				t.Errorf("%v %v: expected an unknown object version error, got: %v", name, resource.GetObjectKind().GroupVersionKind(), err)
+			}
+		}
+	}
+	if actions := append(f.clt.Actions(), f.dynamic.Actions()...); len(actions) != 0 {
+		t.Errorf("expected no requests to the api server, got: %v", actions)
+	}
+}
+
+func TestMissingNodeNames(t *testing.T) {
+	f, c := newFakeCluster(t)
+
+	for name, label := range map[string]string{"node-a": "prometheus-1", "node-b": "main-node", "node-c": ""} {
+		node := &apiCoreV1.Node{ObjectMeta: apiMetaV1.ObjectMeta{Name: name}}
+		if label != "" {
+			node.Labels = map[string]string{NodeNameLabel: label}
+		}
+		f.store(t, node)
+	}
+
+	missing, err := c.MissingNodeNames(map[string]bool{"main-node": true, "prometheus-1": true, "prometheus-2": true, "loadgen": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"loadgen", "prometheus-2"}; !reflect.DeepEqual(expected, missing) {
+		t.Errorf("expected missing node names %v, got %v", expected, missing)
+	}
+}
+
+func TestNodesCreated(t *testing.T) {
+	f, c := newFakeCluster(t)
+
+	start := time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC)
+	for name, node := range map[string]struct {
+		pool    string
+		created time.Time
+	}{
+		"node-a": {pool: "prometheus-1", created: start.Add(time.Hour)},
+		"node-b": {pool: "prometheus-1", created: start},
+		"node-c": {pool: "main-node", created: start.Add(2 * time.Hour)},
+		"node-d": {created: start},
+	} {
+		n := &apiCoreV1.Node{ObjectMeta: apiMetaV1.ObjectMeta{Name: name, CreationTimestamp: apiMetaV1.NewTime(node.created)}}
+		if node.pool != "" {
+			n.Labels = map[string]string{"pool": node.pool}
+		}
+		f.store(t, n)
+	}
+
+	created, err := c.NodesCreated("pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]time.Time{"prometheus-1": start, "main-node": start.Add(2 * time.Hour)}
+	if len(created) != len(expected) {
+		t.Fatalf("expected creation times %v, got %v", expected, created)
+	}
+	for pool, want := range expected {
+		if !created[pool].Equal(want) {
+			t.Errorf("%v: expected creation time %v, got %v", pool, want, created[pool])
+		}
+	}
+}
+
+func TestBenchmarkNamespaces(t *testing.T) {
+	f, c := newFakeCluster(t)
+
+	created := time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC)
+	for _, name := range []string{"default", "prombench-1234", "prombench-test"} {
+		f.store(t, &apiCoreV1.Namespace{
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: name, CreationTimestamp: apiMetaV1.NewTime(created)},
+		})
+	}
+
+	objects, err := c.BenchmarkNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != "prombench-1234" || objects[0].PR != "1234" || !objects[0].Created.Equal(created) {
+		t.Errorf("expected only the prombench-1234 namespace, got %+v", objects)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// LoadKubeconfig loads the kubeconfig file and selects the context.
+// When path is empty the file is found like kubectl does, using the KUBECONFIG env variable or ~/.kube/config,
+// and when context is empty the current context of the file is used.
+func LoadKubeconfig(path, context string) (*clientcmdapi.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	config, err := rules.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading the kubeconfig")
+	}
+	if context != "" {
+		if _, ok := config.Contexts[context]; !ok {
+			return nil, fmt.Errorf("context %q is not in the kubeconfig", context)
+		}
+		config.CurrentContext = context
+	}
+	if config.CurrentContext == "" {
+		return nil, fmt.Errorf("no kubeconfig context selected")
+	}
+	return config, nil
+}
+
+// MinifyKubeconfig returns a kubeconfig file with only the current context of the config.
+// The certificates and keys from other files are embedded so the file can be used on its own.
+func MinifyKubeconfig(config *clientcmdapi.Config) ([]byte, error) {
+	config = config.DeepCopy()
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return nil, errors.Wrap(err, "minifying the kubeconfig")
+	}
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return nil, errors.Wrap(err, "embedding the kubeconfig files")
+	}
+	return clientcmd.Write(*config)
+}
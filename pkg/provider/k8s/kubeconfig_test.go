@@ -0,0 +1,106 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ca.crt"), []byte("ca data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(`
+apiVersion: v1
+kind: Config
+current-context: kind-dev
+clusters:
+- name: kind-dev
+  cluster:
+    server: https://127.0.0.1:6443
+- name: kind-prombench
+  cluster:
+    server: https://127.0.0.1:7443
+    certificate-authority: ca.crt
+contexts:
+- name: kind-dev
+  context:
+    cluster: kind-dev
+    user: kind-dev
+- name: kind-prombench
+  context:
+    cluster: kind-prombench
+    user: kind-prombench
+users:
+- name: kind-dev
+  user:
+    token: dev
+- name: kind-prombench
+  user:
+    token: prombench
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadKubeconfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CurrentContext != "kind-dev" {
+		t.Errorf("expected the current context of the file, got %q", config.CurrentContext)
+	}
+
+	if _, err := LoadKubeconfig(path, "kind-missing"); err == nil {
+		t.Error("expected an error for a missing context")
+	}
+
+	config, err = LoadKubeconfig(path, "kind-prombench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := MinifyKubeconfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minified, err := clientcmd.Load(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minified.CurrentContext != "kind-prombench" {
+		t.Errorf("expected the selected context, got %q", minified.CurrentContext)
+	}
+	if len(minified.Clusters) != 1 || len(minified.Contexts) != 1 || len(minified.AuthInfos) != 1 {
+		t.Errorf("expected only the selected context, got clusters:%v contexts:%v users:%v", len(minified.Clusters), len(minified.Contexts), len(minified.AuthInfos))
+	}
+	cluster := minified.Clusters["kind-prombench"]
+	if cluster == nil || !reflect.DeepEqual(cluster.CertificateAuthorityData, []byte("ca data")) {
+		t.Errorf("expected the ca certificate to be embedded, got %+v", cluster)
+	}
+	if config.Clusters["kind-dev"] == nil {
+		t.Error("expected the loaded config to be left unchanged")
+	}
+}
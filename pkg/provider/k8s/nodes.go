@@ -0,0 +1,77 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MissingNodeNames returns the node names, in sorted order, that no node of the cluster has as its node-name label.
+func (c *K8s) MissingNodeNames(names map[string]bool) ([]string, error) {
+	nodes, err := c.clt.CoreV1().Nodes().List(c.ctx, apiMetaV1.ListOptions{LabelSelector: NodeNameLabel})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing the nodes")
+	}
+	found := map[string]bool{}
+	for _, n := range nodes.Items {
+		found[n.Labels[NodeNameLabel]] = true
+	}
+
+	var missing []string
+	for name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// NodeCounts returns the number of nodes of the cluster for every value of the label.
+func (c *K8s) NodeCounts(label string) (map[string]int, error) {
+	nodes, err := c.clt.CoreV1().Nodes().List(c.ctx, apiMetaV1.ListOptions{LabelSelector: label})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing the nodes")
+	}
+	counts := map[string]int{}
+	for _, n := range nodes.Items {
+		if v, ok := n.Labels[label]; ok {
+			counts[v]++
+		}
+	}
+	return counts, nil
+}
+
+// NodesCreated returns the creation time of the oldest node of the cluster for every value of the label.
+func (c *K8s) NodesCreated(label string) (map[string]time.Time, error) {
+	nodes, err := c.clt.CoreV1().Nodes().List(c.ctx, apiMetaV1.ListOptions{LabelSelector: label})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing the nodes")
+	}
+	created := map[string]time.Time{}
+	for _, n := range nodes.Items {
+		v, ok := n.Labels[label]
+		if !ok {
+			continue
+		}
+		if t, ok := created[v]; !ok || n.CreationTimestamp.Time.Before(t) {
+			created[v] = n.CreationTimestamp.Time
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DefaultParallelism is the default number of objects in the same tier that are applied or deleted at the same time.
+const DefaultParallelism = 5
+
+// kindTiers sets the order in which the kinds are applied.
+// Objects in a lower tier are applied before the ones in a higher tier and deleted after them.
+// Kinds that are not listed here, like the objects of custom resources, are applied last.
+var kindTiers = map[string]int{
+	"namespace":                0,
+	"customresourcedefinition": 0,
+
+	"serviceaccount":     1,
+	"clusterrole":        1,
+	"role":               1,
+	"clusterrolebinding": 2,
+	"rolebinding":        2,
+
+	"configmap":             3,
+	"secret":                3,
+	"persistentvolumeclaim": 3,
+	"priorityclass":         3,
+
+	"service": 4,
+
+	"daemonset":   5,
+	"deployment":  5,
+	"statefulset": 5,
+	"job":         5,
+	"cronjob":     5,
+
+	"ingress": 6,
+}
+
+var unknownKindTier = len(kindTiers)
+
+// Object is a single k8s object together with the file it was parsed from.
+type Object struct {
+	FileName string
+	FileSet  string
+	Object   runtime.Object
+}
+
+func (o Object) kind() string {
+	return strings.ToLower(o.Object.GetObjectKind().GroupVersionKind().Kind)
+}
+
+func kindTier(kind string) int {
+	if t, ok := kindTiers[kind]; ok {
+		return t
+	}
+	return unknownKindTier
+}
+
+// ApplyOrder groups the objects in tiers based on their kind so that every object is applied after the objects it depends on.
+// Objects of the same tier don't depend on each other and keep the order of the deployment files.
+func ApplyOrder(deployments []Resource) [][]Object {
+	var objects []Object
+	for _, deployment := range deployments {
+		for _, resource := range deployment.Objects {
+			objects = append(objects, Object{FileName: deployment.FileName, FileSet: deployment.FileSet, Object: resource})
+		}
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return kindTier(objects[i].kind()) < kindTier(objects[j].kind())
+	})
+
+	var tiers [][]Object
+	for i, o := range objects {
+		if i == 0 || kindTier(objects[i-1].kind()) != kindTier(o.kind()) {
+			tiers = append(tiers, nil)
+		}
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], o)
+	}
+	return tiers
+}
+
+// DeleteOrder returns the ApplyOrder tiers in reverse so that no object is deleted before the objects that depend on it.
+func DeleteOrder(deployments []Resource) [][]Object {
+	tiers := ApplyOrder(deployments)
+	for i, j := 0, len(tiers)-1; i < j; i, j = i+1, j-1 {
+		tiers[i], tiers[j] = tiers[j], tiers[i]
+	}
+	return tiers
+}
+
+// forEach calls fn for all objects in parallel, with at most parallelism calls at the same time,
+// and waits for all of them to finish.
+func forEach(objects []Object, parallelism int, fn func(Object) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, parallelism)
+		errs = make([]error, len(objects))
+	)
+	for i, o := range objects {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, o Object) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(o)
+		}(i, o)
+	}
+	wg.Wait()
+	return joinErrors(errs)
+}
+
+// joinErrors returns a single error with the messages of all non nil errors.
+func joinErrors(errs []error) error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	}
+	msgs := make([]string, len(failed))
+	for i, err := range failed {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("%d errors:\n%v", len(failed), strings.Join(msgs, "\n"))
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testObject(kind, name string) runtime.Object {
+	u := &unstructured.Unstructured{}
+	u.SetKind(kind)
+	u.SetName(name)
+	return u
+}
+
+func tierNames(tiers [][]Object) [][]string {
+	var names [][]string
+	for _, tier := range tiers {
+		var n []string
+		for _, o := range tier {
+			n = append(n, o.Object.(*unstructured.Unstructured).GetName())
+		}
+		names = append(names, n)
+	}
+	return names
+}
+
+func TestApplyOrder(t *testing.T) {
+	deployments := []Resource{
+		{FileName: "1_prometheus.yaml", Objects: []runtime.Object{
+			testObject("Deployment", "prometheus"),
+			testObject("Service", "prometheus"),
+			testObject("ConfigMap", "prometheus-config"),
+		}},
+		{FileName: "2_ingress.yaml", Objects: []runtime.Object{
+			testObject("Ingress", "ingress"),
+			testObject("ServiceMonitor", "monitor"),
+		}},
+		{FileName: "3_namespace.yaml", Objects: []runtime.Object{
+			testObject("Namespace", "prombench"),
+			testObject("ClusterRoleBinding", "prometheus"),
+			testObject("ServiceAccount", "prometheus"),
+			testObject("Deployment", "loadgen"),
+		}},
+	}
+
+	expected := [][]string{
+		{"prombench"},
+		{"prometheus"},
+		{"prometheus"},
+		{"prometheus-config"},
+		{"prometheus"},
+		{"prometheus", "loadgen"},
+		{"ingress"},
+		{"monitor"},
+	}
+	if names := tierNames(ApplyOrder(deployments)); !reflect.DeepEqual(expected, names) {
+		t.Errorf("expect %v, got %v", expected, names)
+	}
+
+	for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+		expected[i], expected[j] = expected[j], expected[i]
+	}
+	if names := tierNames(DeleteOrder(deployments)); !reflect.DeepEqual(expected, names) {
+		t.Errorf("expect %v, got %v", expected, names)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	objects := []Object{
+		{Object: testObject("Deployment", "a")},
+		{Object: testObject("Deployment", "b")},
+		{Object: testObject("Deployment", "c")},
+	}
+
+	var running, maxRunning, called int32
+	err := forEach(objects, 2, func(o Object) error {
+		atomic.AddInt32(&called, 1)
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if o.Object.(*unstructured.Unstructured).GetName() != "a" {
+			return errors.New("failed " + o.Object.(*unstructured.Unstructured).GetName())
+		}
+		return nil
+	})
+	if maxRunning != 2 {
+		t.Errorf("expected at most 2 parallel calls, got %d", maxRunning)
+	}
+	if called != 3 {
+		t.Errorf("expected all objects to be processed, got %d calls", called)
+	}
+	if err == nil || err.Error() != "2 errors:\nfailed b\nfailed c" {
+		t.Errorf("expected the errors of all failed calls, got %v", err)
+	}
+}
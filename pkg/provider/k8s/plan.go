@@ -0,0 +1,404 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PlanAction is the change that applying or deleting an object would make.
+type PlanAction string
+
+// The possible plan actions.
+const (
+	PlanCreate    PlanAction = "create"
+	PlanUpdate    PlanAction = "update"
+	PlanUnchanged PlanAction = "unchanged"
+	PlanDelete    PlanAction = "delete"
+)
+
+// FieldDiff is a single field that differs between the live and the desired object.
+// A nil Live value means that the field is not set on the live object.
+type FieldDiff struct {
+	Path    string
+	Live    interface{}
+	Desired interface{}
+}
+
+// ObjectPlan holds the planned change for a single object in a manifest file.
+type ObjectPlan struct {
+	FileName  string
+	Kind      string
+	Namespace string
+	Name      string
+	Action    PlanAction
+	Diff      []FieldDiff
+}
+
+// Changed returns whether the plan would change the object.
+func (p ObjectPlan) Changed() bool {
+	return p.Action != PlanUnchanged
+}
+
+func (p ObjectPlan) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%v %v", p.Kind, p.Name)
+	}
+	return fmt.Sprintf("%v %v/%v", p.Kind, p.Namespace, p.Name)
+}
+
+// ResourcePlan compares the k8s objects with their live versions in the cluster
+// and returns what ResourceApply would change, or ResourceDelete when del is true.
+// The input is a slice of structs containing the filename and the slice of k8s objects present in the file.
+func (c *K8s) ResourcePlan(deployments []Resource, del bool) ([]ObjectPlan, error) {
+	var plans []ObjectPlan
+	for _, deployment := range deployments {
+		for _, resource := range deployment.Objects {
+			p, err := c.objectPlan(resource, deployment.FileName, deployment.FileSet, del)
+			if err != nil {
+				return nil, fmt.Errorf("error planning '%v' err:%v", deployment.FileName, err)
+			}
+			p.FileName = deployment.FileName
+			plans = append(plans, p)
+		}
+	}
+	return plans, nil
+}
+
+func (c *K8s) objectPlan(resource runtime.Object, fileName, fileSet string, del bool) (ObjectPlan, error) {
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return ObjectPlan{}, err
+	}
+	p := ObjectPlan{
+		Kind: resource.GetObjectKind().GroupVersionKind().Kind,
+		Name: obj.GetName(),
+	}
+
+	client, _, err := c.resourceClient(resource)
+	if err != nil {
+		// The kind might be defined by a CRD that hasn't been created yet.
+		if meta.IsNoMatchError(err) {
+			p.Action = PlanCreate
+			if del {
+				p.Action = PlanUnchanged
+			}
+			return p, nil
+		}
+		return p, errors.Wrapf(err, "getting the resource mapping - kind: %v, name: %v", p.Kind, p.Name)
+	}
+	p.Namespace = obj.GetNamespace()
+
+	live, err := client.Get(c.ctx, p.Name, apiMetaV1.GetOptions{})
+	if err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return p, errors.Wrapf(err, "getting the live resource - kind: %v, name: %v", p.Kind, p.Name)
+		}
+		p.Action = PlanCreate
+		if del {
+			p.Action = PlanUnchanged
+		}
+		return p, nil
+	}
+
+	if del {
+		p.Action = PlanDelete
+		return p, nil
+	}
+
+	if err := setOwnership(resource, fileName, fileSet); err != nil {
+		return p, err
+	}
+	desired, err := applyFields(resource)
+	if err != nil {
+		return p, errors.Wrapf(err, "converting the resource - kind: %v, name: %v", p.Kind, p.Name)
+	}
+	p.Diff = DiffFields(desired, live.Object)
+	p.Action = PlanUnchanged
+	if len(p.Diff) > 0 {
+		p.Action = PlanUpdate
+	}
+	return p, nil
+}
+
+// ignoredFields are set by the api server and are never compared.
+var ignoredFields = map[string]bool{
+	"status":                     true,
+	"metadata.creationTimestamp": true,
+	"metadata.resourceVersion":   true,
+	"metadata.uid":               true,
+	"metadata.generation":        true,
+	"metadata.selfLink":          true,
+	"metadata.managedFields":     true,
+}
+
+// DiffFields returns the fields of the desired object that differ from the live object.
+// Fields that are only set on the live object are ignored
+// as these are usually defaults populated by the api server.
+// The desired values are compared in the form that the api server stores them:
+// the stringData of a Secret as its data and resource quantities, like 1000m and 1, in their canonical form.
+func DiffFields(desired, live map[string]interface{}) []FieldDiff {
+	if desired["kind"] == "Secret" {
+		desired = secretData(desired)
+	}
+	return diffFields("", desired, live)
+}
+
+// secretData returns a copy of the Secret with its stringData merged into its data,
+// the same as the api server does as the live Secret only has data.
+func secretData(secret map[string]interface{}) map[string]interface{} {
+	stringData, ok := secret["stringData"].(map[string]interface{})
+	if !ok {
+		return secret
+	}
+	merged := map[string]interface{}{}
+	for k, v := range secret {
+		merged[k] = v
+	}
+	data := map[string]interface{}{}
+	if d, ok := secret["data"].(map[string]interface{}); ok {
+		for k, v := range d {
+			data[k] = v
+		}
+	}
+	for k, v := range stringData {
+		if s, ok := v.(string); ok {
+			data[k] = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+	}
+	merged["data"] = data
+	delete(merged, "stringData")
+	return merged
+}
+
+func diffFields(path string, desired, live interface{}) []FieldDiff {
+	if ignoredFields[path] {
+		return nil
+	}
+	switch d := desired.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		l, _ := live.(map[string]interface{})
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var diffs []FieldDiff
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			diffs = append(diffs, diffFields(p, d[k], l[k])...)
+		}
+		return diffs
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if len(d) == 0 && len(l) == 0 {
+			return nil
+		}
+		if !ok || len(l) != len(d) {
+			return []FieldDiff{{Path: path, Live: live, Desired: desired}}
+		}
+		var diffs []FieldDiff
+		for i := range d {
+			diffs = append(diffs, diffFields(fmt.Sprintf("%v[%d]", path, i), d[i], l[i])...)
+		}
+		return diffs
+	default:
+		d, l := normaliseValue(desired), normaliseValue(live)
+		if isQuantityField(path) {
+			d, l = canonicalQuantity(d), canonicalQuantity(l)
+		}
+		if !reflect.DeepEqual(d, l) {
+			return []FieldDiff{{Path: path, Live: live, Desired: desired}}
+		}
+		return nil
+	}
+}
+
+// quantityFields are the fields that hold a resource quantity and the fields that hold maps of them,
+// like the sizeLimit of an emptyDir and the limits of a container.
+var quantityFields = map[string]bool{
+	"requests":       true,
+	"limits":         true,
+	"hard":           true,
+	"capacity":       true,
+	"overhead":       true,
+	"min":            true,
+	"max":            true,
+	"default":        true,
+	"defaultRequest": true,
+	"sizeLimit":      true,
+}
+
+// isQuantityField returns whether the field at the path holds a resource quantity.
+func isQuantityField(path string) bool {
+	parts := strings.Split(path, ".")
+	if quantityFields[parts[len(parts)-1]] {
+		return true
+	}
+	return len(parts) > 1 && quantityFields[parts[len(parts)-2]]
+}
+
+// canonicalQuantity returns the canonical form of a resource quantity,
+// the value itself when it isn't a quantity.
+func canonicalQuantity(v interface{}) interface{} {
+	var s string
+	switch n := v.(type) {
+	case string:
+		s = n
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return v
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return v
+	}
+	return q.String()
+}
+
+// normaliseValue converts all numbers to float64 so that
+// values decoded from json and from the typed objects compare equal.
+func normaliseValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+// PrintPlan writes a human readable summary of the plans and
+// returns whether any of the objects would be changed.
+// The values of Secrets are never printed.
+func PrintPlan(w io.Writer, plans []ObjectPlan) bool {
+	counts := map[PlanAction]int{}
+	for _, p := range plans {
+		counts[p.Action]++
+
+		var symbol string
+		switch p.Action {
+		case PlanCreate:
+			symbol = "+"
+		case PlanUpdate:
+			symbol = "~"
+		case PlanDelete:
+			symbol = "-"
+		default:
+			symbol = "="
+		}
+		fmt.Fprintf(w, "%v %-9v %v (%v)\n", symbol, p.Action, p, p.FileName)
+
+		for _, d := range p.Diff {
+			if p.Kind == "Secret" {
+				fmt.Fprintf(w, "    ~ %v: (sensitive value)\n", d.Path)
+				continue
+			}
+			live, liveIsString := d.Live.(string)
+			desired, desiredIsString := d.Desired.(string)
+			if liveIsString && desiredIsString && (strings.Contains(live, "\n") || strings.Contains(desired, "\n")) {
+				fmt.Fprintf(w, "    ~ %v:\n", d.Path)
+				for _, l := range lineDiff(live, desired) {
+					fmt.Fprintf(w, "        %v\n", l)
+				}
+				continue
+			}
+			fmt.Fprintf(w, "    ~ %v: %v => %v\n", d.Path, formatValue(d.Live), formatValue(d.Desired))
+		}
+	}
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		counts[PlanCreate], counts[PlanUpdate], counts[PlanDelete], counts[PlanUnchanged])
+
+	return counts[PlanCreate]+counts[PlanUpdate]+counts[PlanDelete] > 0
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// lineDiff returns the removed and the added lines between two multi line strings.
+// The removed lines are prefixed with "-" and the added ones with "+".
+func lineDiff(a, b string) []string {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of al[i:] and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+al[i])
+			i++
+		default:
+			diff = append(diff, "+ "+bl[j])
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		diff = append(diff, "- "+al[i])
+	}
+	for ; j < len(bl); j++ {
+		diff = append(diff, "+ "+bl[j])
+	}
+	return diff
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffFields(t *testing.T) {
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "prometheus",
+			"resourceVersion": "123",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			// Populated by the api server.
+			"revisionHistoryLimit": int64(10),
+			"containers": []interface{}{
+				map[string]interface{}{"image": "prom/prometheus:v2.19.0", "imagePullPolicy": "IfNotPresent"},
+			},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}
+
+	testCases := []struct {
+		desired map[string]interface{}
+		diff    []FieldDiff
+	}{
+		{
+			desired: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "prometheus", "creationTimestamp": nil},
+				"spec": map[string]interface{}{
+					"replicas":   int64(1),
+					"containers": []interface{}{map[string]interface{}{"image": "prom/prometheus:v2.19.0"}},
+				},
+				"status": map[string]interface{}{},
+			},
+		},
+		{
+			desired: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "prometheus", "labels": map[string]interface{}{"app": "prometheus"}},
+				"spec": map[string]interface{}{
+					"replicas":   int32(2),
+					"containers": []interface{}{map[string]interface{}{"image": "prom/prometheus:v2.20.0"}},
+				},
+			},
+			diff: []FieldDiff{
+				{Path: "metadata.labels.app", Live: nil, Desired: "prometheus"},
+				{Path: "spec.containers[0].image", Live: "prom/prometheus:v2.19.0", Desired: "prom/prometheus:v2.20.0"},
+				{Path: "spec.replicas", Live: int64(1), Desired: int32(2)},
+			},
+		},
+		{
+			desired: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{}, map[string]interface{}{}},
+				},
+			},
+			diff: []FieldDiff{
+				{
+					Path:    "spec.containers",
+					Live:    live["spec"].(map[string]interface{})["containers"],
+					Desired: []interface{}{map[string]interface{}{}, map[string]interface{}{}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		if diff := DiffFields(tc.desired, live); !reflect.DeepEqual(tc.diff, diff) {
+			t.Errorf("\nexpect %#v\ngot %#v", tc.diff, diff)
+		}
+	}
+}
+
+func TestDiffFieldsAsStored(t *testing.T) {
+	testCases := []struct {
+		name          string
+		desired, live map[string]interface{}
+		diff          []FieldDiff
+	}{
+		{
+			name: "secret string data",
+			desired: map[string]interface{}{
+				"kind":       "Secret",
+				"data":       map[string]interface{}{"user": "cHJvbWV0aGV1cw=="},
+				"stringData": map[string]interface{}{"token": "abc", "password": "changed"},
+			},
+			live: map[string]interface{}{
+				"kind": "Secret",
+				"data": map[string]interface{}{"user": "cHJvbWV0aGV1cw==", "token": "YWJj", "password": "c2VjcmV0"},
+			},
+			diff: []FieldDiff{{Path: "data.password", Live: "c2VjcmV0", Desired: "Y2hhbmdlZA=="}},
+		},
+		{
+			name: "quantities",
+			desired: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{"cpu": "1000m", "memory": "1024Mi"},
+							"limits":   map[string]interface{}{"cpu": int64(2), "memory": "2Gi"},
+						},
+					}},
+					"volumes": []interface{}{map[string]interface{}{
+						"emptyDir": map[string]interface{}{"sizeLimit": "0.5Gi"},
+					}},
+				},
+			},
+			live: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+							"limits":   map[string]interface{}{"cpu": "2", "memory": "4Gi"},
+						},
+					}},
+					"volumes": []interface{}{map[string]interface{}{
+						"emptyDir": map[string]interface{}{"sizeLimit": "512Mi"},
+					}},
+				},
+			},
+			diff: []FieldDiff{{Path: "spec.containers[0].resources.limits.memory", Live: "4Gi", Desired: "2Gi"}},
+		},
+		{
+			name:    "numbers in other fields",
+			desired: map[string]interface{}{"data": map[string]interface{}{"cpu": "1000m"}},
+			live:    map[string]interface{}{"data": map[string]interface{}{"cpu": "1"}},
+			diff:    []FieldDiff{{Path: "data.cpu", Live: "1", Desired: "1000m"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := DiffFields(tc.desired, tc.live); !reflect.DeepEqual(tc.diff, diff) {
+				t.Errorf("\nexpect %#v\ngot %#v", tc.diff, diff)
+			}
+		})
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	a := "global:\n  scrape_interval: 15s\nscrape_configs:\n- job_name: a"
+	b := "global:\n  scrape_interval: 30s\nscrape_configs:\n- job_name: a\n- job_name: b"
+
+	expected := []string{
+		"-   scrape_interval: 15s",
+		"+   scrape_interval: 30s",
+		"+ - job_name: b",
+	}
+	if diff := lineDiff(a, b); !reflect.DeepEqual(expected, diff) {
+		t.Errorf("\nexpect %q\ngot %q", expected, diff)
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	plans := []ObjectPlan{
+		{FileName: "1.yaml", Kind: "Namespace", Name: "prombench", Action: PlanUnchanged},
+		{
+			FileName: "2.yaml", Kind: "Secret", Namespace: "prombench", Name: "token", Action: PlanUpdate,
+			Diff: []FieldDiff{{Path: "data.token", Live: "b2xk", Desired: "bmV3"}},
+		},
+	}
+
+	var out bytes.Buffer
+	if !PrintPlan(&out, plans) {
+		t.Error("expected the plan to have changes")
+	}
+	if strings.Contains(out.String(), "bmV3") || !strings.Contains(out.String(), "data.token: (sensitive value)") {
+		t.Errorf("secret values must be masked, got:\n%v", out.String())
+	}
+	if !strings.Contains(out.String(), "Plan: 0 to create, 1 to update, 0 to delete, 1 unchanged.") {
+		t.Errorf("unexpected summary, got:\n%v", out.String())
+	}
+
+	out.Reset()
+	if PrintPlan(&out, plans[:1]) {
+		t.Error("expected the plan to have no changes")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// The labels and the annotation added to every object applied by ResourceApply.
+const (
+	OwnerLabel           = "app.kubernetes.io/managed-by"
+	OwnerLabelValue      = "prometheus-test-infra"
+	FileSetLabel         = "test-infra.prometheus.io/file-set"
+	SourceFileAnnotation = "test-infra.prometheus.io/source-file"
+)
+
+// FileSet returns the value of the file set label for the deployment files passed with -f.
+// It is the same for the same files in any order and from any working directory, see stablePath.
+func FileSet(files []string) string {
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, stablePath(f))
+	}
+	sort.Strings(paths)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(paths, "\n"))))[:16]
+}
+
+// stablePath returns the path relative to the root of the git repository that it is in,
+// so that it is the same in every checkout of the repository.
+// Paths outside of a git repository are absolute.
+func stablePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			rel, err := filepath.Rel(dir, abs)
+			if err == nil {
+				return filepath.ToSlash(rel)
+			}
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	return filepath.ToSlash(abs)
+}
+
+// setFileSet sets the file set of the resources parsed from the given deployment files.
+func setFileSet(resources []Resource, files []string) {
+	fileSet := FileSet(files)
+	for i := range resources {
+		resources[i].FileSet = fileSet
+	}
+}
+
+// setOwnership adds the ownership and the file set labels and the source file annotation to the object.
+func setOwnership(resource runtime.Object, fileName, fileSet string) error {
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return err
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OwnerLabel] = OwnerLabelValue
+	if fileSet != "" {
+		labels[FileSetLabel] = fileSet
+	}
+	obj.SetLabels(labels)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SourceFileAnnotation] = fileName
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// objectKey identifies an object regardless of its api group and version
+// as some kinds are served by more than one group, like the Deployments in the apps and the extensions groups.
+func objectKey(kind, namespace, name string) string {
+	return strings.Join([]string{kind, namespace, name}, "/")
+}
+
+// pruneTargets returns the keys of the objects in the deployments and the namespaces these are in.
+// Namespaced objects without a namespace are in the default namespace.
+func (c *K8s) pruneTargets(deployments []Resource) (map[string]bool, []string, error) {
+	keys := map[string]bool{}
+	namespaces := map[string]bool{}
+	for _, deployment := range deployments {
+		for _, resource := range deployment.Objects {
+			obj, err := meta.Accessor(resource)
+			if err != nil {
+				return nil, nil, err
+			}
+			gvk := resource.GetObjectKind().GroupVersionKind()
+			if gvk.Kind == "Namespace" {
+				namespaces[obj.GetName()] = true
+				continue
+			}
+
+			namespace := obj.GetNamespace()
+			if mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil && mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+				namespace = namespaceOrDefault(namespace)
+			}
+			if namespace != "" {
+				namespaces[namespace] = true
+			}
+			keys[objectKey(gvk.Kind, namespace, obj.GetName())] = true
+		}
+	}
+
+	list := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		list = append(list, ns)
+	}
+	sort.Strings(list)
+	return keys, list, nil
+}
+
+// ResourcePrune deletes the objects previously applied by ResourceApply that are no longer in the deployments.
+// Only namespaced objects in the namespaces of the deployments and with the same file set are checked,
+// so applying other files to the same namespace never deletes each other's objects.
+// Cluster scoped objects are never pruned as the same files are applied to many namespaces,
+// like the ClusterRoleBindings of the benchmarks of different pull requests.
+// Objects owned by other objects are never deleted as these are managed by their owner.
+func (c *K8s) ResourcePrune(deployments []Resource) error {
+	fileSets := map[string]bool{}
+	for _, deployment := range deployments {
+		if deployment.FileSet == "" {
+			return fmt.Errorf("the file %v has no file set, can't select the objects to prune", deployment.FileName)
+		}
+		fileSets[deployment.FileSet] = true
+	}
+	keys, namespaces, err := c.pruneTargets(deployments)
+	if err != nil {
+		return err
+	}
+
+	resourceLists, err := c.clt.Discovery().ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return errors.Wrapf(err, "listing the server resources")
+	}
+	gvrs, err := discovery.GroupVersionResources(discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list", "delete"}}, resourceLists))
+	if err != nil {
+		return errors.Wrapf(err, "parsing the server resources")
+	}
+
+	for fileSet := range fileSets {
+		selector := fmt.Sprintf("%v=%v,%v=%v", OwnerLabel, OwnerLabelValue, FileSetLabel, fileSet)
+		for _, ns := range namespaces {
+			for gvr := range gvrs {
+				list, err := c.dynamicClt.Resource(gvr).Namespace(ns).List(c.ctx, apiMetaV1.ListOptions{LabelSelector: selector})
+				if err != nil {
+					if apiErrors.IsNotFound(err) || apiErrors.IsMethodNotSupported(err) {
+						continue
+					}
+					return errors.Wrapf(err, "listing resource : %v, namespace: %v", gvr.Resource, ns)
+				}
+				for _, item := range list.Items {
+					if !prunable(item, keys) {
+						continue
+					}
+					if err := c.pruneObject(gvr, item); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func prunable(item unstructured.Unstructured, keys map[string]bool) bool {
+	if _, ok := item.GetAnnotations()[SourceFileAnnotation]; !ok || len(item.GetOwnerReferences()) > 0 {
+		return false
+	}
+	return !keys[objectKey(item.GetKind(), item.GetNamespace(), item.GetName())]
+}
+
+func (c *K8s) pruneObject(gvr schema.GroupVersionResource, item unstructured.Unstructured) error {
+	delPolicy := c.DeletePropagation
+	err := c.dynamicClt.Resource(gvr).Namespace(item.GetNamespace()).Delete(c.ctx, item.GetName(), apiMetaV1.DeleteOptions{PropagationPolicy: &delPolicy})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return errors.Wrapf(err, "resource prune failed - kind: %v, namespace: %v, name: %v", item.GetKind(), item.GetNamespace(), item.GetName())
+	}
+	log.Printf("resource pruned - kind: %v, namespace: %v, name: %v, source file: %v", item.GetKind(), item.GetNamespace(), item.GetName(), item.GetAnnotations()[SourceFileAnnotation])
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+func TestSetOwnership(t *testing.T) {
+	cm := &apiCoreV1.ConfigMap{ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus", Labels: map[string]string{"app": "prometheus"}}}
+	if err := setOwnership(cm, "manifests/1_prometheus.yaml", "0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Labels["app"] != "prometheus" || cm.Labels[OwnerLabel] != OwnerLabelValue || cm.Labels[FileSetLabel] != "0123456789abcdef" {
+		t.Errorf("unexpected labels %v", cm.Labels)
+	}
+	if cm.Annotations[SourceFileAnnotation] != "manifests/1_prometheus.yaml" {
+		t.Errorf("unexpected annotations %v", cm.Annotations)
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	infra := FileSet([]string{"manifests/cluster-infra/", "manifests/extra.yaml"})
+	if infra != FileSet([]string{"manifests/extra.yaml", "manifests/cluster-infra"}) {
+		t.Error("expected the same file set for the same files in another order")
+	}
+	if infra == FileSet([]string{"manifests/prombench/benchmark"}) {
+		t.Error("expected different file sets for different files")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if infra != FileSet([]string{"./manifests/extra.yaml", filepath.Join(wd, "manifests/cluster-infra")}) {
+		t.Error("expected the same file set for relative and absolute paths of the same files")
+	}
+	if len(infra) > 63 {
+		t.Errorf("the file set %v is too long for a label value", infra)
+	}
+}
+
+func TestStablePath(t *testing.T) {
+	repo, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "manifests", "prombench"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := stablePath(filepath.Join(repo, "manifests", "prombench")); p != "manifests/prombench" {
+		t.Errorf("expected the path relative to the repository root, got: %v", p)
+	}
+	if p := stablePath(filepath.Join(repo, "manifests", "..", "manifests", "prombench", "")); p != "manifests/prombench" {
+		t.Errorf("expected the cleaned path relative to the repository root, got: %v", p)
+	}
+}
+
+func TestPrunable(t *testing.T) {
+	keys := map[string]bool{objectKey("ConfigMap", "default", "kept"): true}
+	live := func(name string, annotated, owned bool) unstructured.Unstructured {
+		u := unstructured.Unstructured{}
+		u.SetKind("ConfigMap")
+		u.SetNamespace("default")
+		u.SetName(name)
+		u.SetLabels(map[string]string{OwnerLabel: OwnerLabelValue})
+		if annotated {
+			u.SetAnnotations(map[string]string{SourceFileAnnotation: "1_config.yaml"})
+		}
+		if owned {
+			u.SetOwnerReferences([]apiMetaV1.OwnerReference{{Kind: "Service", Name: name}})
+		}
+		return u
+	}
+
+	for _, tc := range []struct {
+		item     unstructured.Unstructured
+		prunable bool
+	}{
+		{item: live("kept", true, false), prunable: false},
+		{item: live("removed", true, false), prunable: true},
+		// Endpoints copy the labels of their Service, but not the annotations.
+		{item: live("copied-labels", false, false), prunable: false},
+		{item: live("owned", true, true), prunable: false},
+	} {
+		if p := prunable(tc.item, keys); p != tc.prunable {
+			t.Errorf("%v: expected prunable %v, got %v", tc.item.GetName(), tc.prunable, p)
+		}
+	}
+}
+
+func TestResourcePruneFileSet(t *testing.T) {
+	configMap := func(name, fileSet string) *apiCoreV1.ConfigMap {
+		return &apiCoreV1.ConfigMap{
+			TypeMeta: apiMetaV1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: apiMetaV1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Labels:      map[string]string{OwnerLabel: OwnerLabelValue, FileSetLabel: fileSet},
+				Annotations: map[string]string{SourceFileAnnotation: name + ".yaml"},
+			},
+		}
+	}
+	infra, benchmark := FileSet([]string{"manifests/cluster-infra"}), FileSet([]string{"manifests/prombench"})
+	f, c := newFakeCluster(t,
+		configMap("kept", infra),
+		configMap("removed", infra),
+		configMap("benchmark", benchmark),
+	)
+
+	err := c.ResourcePrune([]Resource{{
+		FileName: "kept.yaml",
+		FileSet:  infra,
+		Objects:  []runtime.Object{configMap("kept", infra)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deleted []string
+	for _, action := range f.dynamic.Actions() {
+		if d, ok := action.(k8sTesting.DeleteAction); ok {
+			deleted = append(deleted, d.GetName())
+		}
+	}
+	if !reflect.DeepEqual([]string{"removed"}, deleted) {
+		t.Errorf("expected only the removed object of the same file set to be pruned, got: %v", deleted)
+	}
+}
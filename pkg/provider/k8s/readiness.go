@@ -0,0 +1,415 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// DefaultReadyTimeout is the default time to wait for all workloads to finish their rollout.
+	DefaultReadyTimeout = 10 * time.Minute
+	// reportEventsCount is the number of most recent events shown for every unready pod.
+	reportEventsCount = 5
+)
+
+// ErrNotReady is returned when some workloads haven't finished their rollout before the deadline.
+var ErrNotReady = errors.New("workloads not ready before the deadline")
+
+// WaitReady waits in parallel until the workloads are ready or the deadline is reached.
+// Deployments, StatefulSets and DaemonSets are ready when their rollout is finished,
+// LoadBalancer Services when they have an address and Jobs when they are complete.
+// All of them have to be ready before the same deadline.
+// When the deadline is reached, the error includes a report for every unready pod.
+func (c *K8s) WaitReady(objects []Object, deadline time.Time) error {
+	var (
+		wg      sync.WaitGroup
+		mtx     sync.Mutex
+		errs    []error
+		unready []Object
+	)
+	for _, o := range objects {
+		if !hasRollout(o.Object) {
+			continue
+		}
+		wg.Add(1)
+		go func(o Object) {
+			defer wg.Done()
+			ready, err := c.waitRollout(o.Object, deadline)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error waiting for '%v' err:%v", o.FileName, err))
+			} else if !ready {
+				unready = append(unready, o)
+			}
+		}(o)
+	}
+	wg.Wait()
+
+	if len(unready) > 0 {
+		var report strings.Builder
+		for _, o := range unready {
+			c.unreadyReport(&report, o.Object)
+		}
+		log.Print(report.String())
+		errs = append(errs, errors.Wrap(ErrNotReady, report.String()))
+	}
+	return joinErrors(errs)
+}
+
+func hasRollout(resource runtime.Object) bool {
+	switch r := resource.(type) {
+	case *appsV1.Deployment, *appsV1.StatefulSet, *appsV1.DaemonSet, *batchV1.Job:
+		return true
+	case *apiCoreV1.Service:
+		return r.Spec.Type == apiCoreV1.ServiceTypeLoadBalancer
+	}
+	return false
+}
+
+// waitRollout watches the object until its rollout is done, fails or the deadline is reached.
+func (c *K8s) waitRollout(resource runtime.Object, deadline time.Time) (bool, error) {
+	ctx, cancel := context.WithDeadline(c.ctx, deadline)
+	defer cancel()
+
+	var lastMsg string
+	done, err := c.watchObject(ctx, resource, func(live *unstructured.Unstructured) (bool, error) {
+		if live == nil {
+			return false, nil
+		}
+		obj, err := toTyped(resource, live)
+		if err != nil {
+			return false, err
+		}
+		done, msg, err := statusOf(obj)
+		if err != nil {
+			return false, err
+		}
+		if done {
+			if svc, ok := obj.(*apiCoreV1.Service); ok {
+				logServiceAddresses(svc)
+			}
+			return true, nil
+		}
+		if msg != lastMsg {
+			log.Printf("Request for '%v' is in progress - %v", objectName(resource), msg)
+			lastMsg = msg
+		}
+		return false, nil
+	})
+	if done {
+		log.Printf("Request for '%v' is done!", objectName(resource))
+	}
+	return done, err
+}
+
+// toTyped converts the live object to the type of the resource.
+func toTyped(resource runtime.Object, live *unstructured.Unstructured) (runtime.Object, error) {
+	obj := reflect.New(reflect.TypeOf(resource).Elem()).Interface().(runtime.Object)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, obj); err != nil {
+		return nil, errors.Wrapf(err, "converting the live object of '%v'", objectName(resource))
+	}
+	return obj, nil
+}
+
+func objectName(resource runtime.Object) string {
+	kind := strings.ToLower(resource.GetObjectKind().GroupVersionKind().Kind)
+	switch r := resource.(type) {
+	case *appsV1.Deployment:
+		return fmt.Sprintf("%v:%v/%v", kind, r.Namespace, r.Name)
+	case *appsV1.StatefulSet:
+		return fmt.Sprintf("%v:%v/%v", kind, r.Namespace, r.Name)
+	case *appsV1.DaemonSet:
+		return fmt.Sprintf("%v:%v/%v", kind, r.Namespace, r.Name)
+	case *batchV1.Job:
+		return fmt.Sprintf("%v:%v/%v", kind, r.Namespace, r.Name)
+	case *apiCoreV1.Service:
+		return fmt.Sprintf("%v:%v/%v", kind, r.Namespace, r.Name)
+	}
+	return kind
+}
+
+// rolloutStatus gets the live object and returns whether its rollout is done
+// with a message describing what it is waiting for.
+func (c *K8s) rolloutStatus(resource runtime.Object) (bool, string, error) {
+	var (
+		live runtime.Object
+		err  error
+	)
+	switch req := resource.(type) {
+	case *appsV1.Deployment:
+		live, err = c.clt.AppsV1().Deployments(req.Namespace).Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
+	case *appsV1.StatefulSet:
+		live, err = c.clt.AppsV1().StatefulSets(req.Namespace).Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
+	case *appsV1.DaemonSet:
+		live, err = c.clt.AppsV1().DaemonSets(req.Namespace).Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
+	case *batchV1.Job:
+		live, err = c.clt.BatchV1().Jobs(req.Namespace).Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
+	case *apiCoreV1.Service:
+		live, err = c.clt.CoreV1().Services(req.Namespace).Get(c.ctx, req.Name, apiMetaV1.GetOptions{})
+	default:
+		return true, "", nil
+	}
+	if err != nil {
+		return false, "", errors.Wrapf(err, "Checking '%v' status failed", objectName(resource))
+	}
+	return statusOf(live)
+}
+
+// statusOf returns whether the rollout of the live object is done
+// with a message describing what it is waiting for.
+func statusOf(live runtime.Object) (bool, string, error) {
+	switch l := live.(type) {
+	case *appsV1.Deployment:
+		return deploymentRolloutStatus(l)
+	case *appsV1.StatefulSet:
+		return statefulSetRolloutStatus(l)
+	case *appsV1.DaemonSet:
+		return daemonSetRolloutStatus(l)
+	case *batchV1.Job:
+		return jobStatus(l)
+	case *apiCoreV1.Service:
+		return serviceStatus(l)
+	}
+	return true, "", nil
+}
+
+// deploymentRolloutStatus follows the semantics of kubectl rollout status.
+// The rollout is done when the controller has seen the latest spec,
+// all replicas are updated, no old replicas are left and all updated replicas are available.
+func deploymentRolloutStatus(d *appsV1.Deployment) (bool, string, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for the deployment spec update to be observed", nil
+	}
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == appsV1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return false, "", fmt.Errorf("deployment %q exceeded its progress deadline", d.Name)
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d out of %d new replicas have been updated", d.Status.UpdatedReplicas, replicas), nil
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas), nil
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas), nil
+	}
+	return true, "", nil
+}
+
+// statefulSetRolloutStatus follows the semantics of kubectl rollout status.
+func statefulSetRolloutStatus(s *appsV1.StatefulSet) (bool, string, error) {
+	if s.Status.ObservedGeneration == 0 || s.Generation > s.Status.ObservedGeneration {
+		return false, "waiting for the statefulset spec update to be observed", nil
+	}
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	if s.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d of %d pods are ready", s.Status.ReadyReplicas, replicas), nil
+	}
+	if s.Spec.UpdateStrategy.Type != appsV1.RollingUpdateStatefulSetStrategyType {
+		return true, "", nil
+	}
+	if ru := s.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil && *ru.Partition > 0 {
+		if s.Status.UpdatedReplicas < replicas-*ru.Partition {
+			return false, fmt.Sprintf("%d of %d pods updated in the partition", s.Status.UpdatedReplicas, replicas-*ru.Partition), nil
+		}
+		return true, "", nil
+	}
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d of %d pods are at the update revision %v", s.Status.UpdatedReplicas, replicas, s.Status.UpdateRevision), nil
+	}
+	return true, "", nil
+}
+
+// daemonSetRolloutStatus follows the semantics of kubectl rollout status.
+func daemonSetRolloutStatus(d *appsV1.DaemonSet) (bool, string, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for the daemonset spec update to be observed", nil
+	}
+	if d.Spec.UpdateStrategy.Type == appsV1.RollingUpdateDaemonSetStrategyType &&
+		d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d updated pods are scheduled", d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled), nil
+	}
+	if d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d pods are available", d.Status.NumberAvailable, d.Status.DesiredNumberScheduled), nil
+	}
+	return true, "", nil
+}
+
+// jobStatus returns true when the job has completed and an error when it has failed.
+func jobStatus(j *batchV1.Job) (bool, string, error) {
+	for _, cond := range j.Status.Conditions {
+		if cond.Status != apiCoreV1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchV1.JobComplete:
+			return true, "", nil
+		case batchV1.JobFailed:
+			return false, "", fmt.Errorf("Job %v has failed: %v", j.Name, cond.Message)
+		}
+	}
+	return false, fmt.Sprintf("%d active, %d succeeded, %d failed pods", j.Status.Active, j.Status.Succeeded, j.Status.Failed), nil
+}
+
+// serviceStatus returns true when a LoadBalancer Service has an address.
+// Services of other types are always ready.
+func serviceStatus(s *apiCoreV1.Service) (bool, string, error) {
+	if s.Spec.Type != apiCoreV1.ServiceTypeLoadBalancer || len(s.Status.LoadBalancer.Ingress) > 0 {
+		return true, "", nil
+	}
+	return false, "waiting for the load balancer address", nil
+}
+
+func logServiceAddresses(s *apiCoreV1.Service) {
+	log.Printf("\tService %s Details", s.Name)
+	for _, x := range s.Status.LoadBalancer.Ingress {
+		addr := x.IP
+		if addr == "" {
+			addr = x.Hostname
+		}
+		for _, p := range s.Spec.Ports {
+			log.Printf("\t\thttp://%s:%d", addr, p.Port)
+		}
+	}
+}
+
+// podSelector returns the label selector for the pods of the workload.
+func podSelector(resource runtime.Object) (string, string) {
+	switch r := resource.(type) {
+	case *appsV1.Deployment:
+		return r.Namespace, apiMetaV1.FormatLabelSelector(r.Spec.Selector)
+	case *appsV1.StatefulSet:
+		return r.Namespace, apiMetaV1.FormatLabelSelector(r.Spec.Selector)
+	case *appsV1.DaemonSet:
+		return r.Namespace, apiMetaV1.FormatLabelSelector(r.Spec.Selector)
+	case *batchV1.Job:
+		// The job controller adds this label to all pods of a job.
+		return r.Namespace, "job-name=" + r.Name
+	}
+	return "", ""
+}
+
+// unreadyReport writes the state of every unready pod of the workload with its recent events.
+func (c *K8s) unreadyReport(w io.Writer, resource runtime.Object) {
+	fmt.Fprintf(w, "\n%v is not ready:\n", objectName(resource))
+	if _, msg, err := c.rolloutStatus(resource); err == nil && msg != "" {
+		fmt.Fprintf(w, "  %v\n", msg)
+	}
+
+	namespace, selector := podSelector(resource)
+	if selector == "" {
+		return
+	}
+	pods, err := c.clt.CoreV1().Pods(namespace).List(c.ctx, apiMetaV1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		fmt.Fprintf(w, "  error listing the pods: %v\n", err)
+		return
+	}
+	for _, pod := range pods.Items {
+		if podReady(pod) {
+			continue
+		}
+		events, err := c.clt.CoreV1().Events(namespace).List(c.ctx, apiMetaV1.ListOptions{
+			FieldSelector: fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": pod.Name}.String(),
+		})
+		if err != nil {
+			fmt.Fprintf(w, "  error listing the events for pod %v: %v\n", pod.Name, err)
+			events = &apiCoreV1.EventList{}
+		}
+		writePodReport(w, pod, events.Items)
+	}
+}
+
+func podReady(pod apiCoreV1.Pod) bool {
+	if pod.Status.Phase == apiCoreV1.PodSucceeded {
+		return true
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == apiCoreV1.PodReady {
+			return cond.Status == apiCoreV1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// writePodReport writes the phase of the pod, the state and restarts of every container and the most recent events.
+func writePodReport(w io.Writer, pod apiCoreV1.Pod, events []apiCoreV1.Event) {
+	fmt.Fprintf(w, "  pod %v phase:%v node:%v\n", pod.Name, pod.Status.Phase, pod.Spec.NodeName)
+	statuses := append(append([]apiCoreV1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		fmt.Fprintf(w, "    container %v ready:%v restarts:%d state:%v", cs.Name, cs.Ready, cs.RestartCount, containerState(cs.State))
+		if cs.LastTerminationState.Terminated != nil {
+			fmt.Fprintf(w, " last state:%v", containerState(cs.LastTerminationState))
+		}
+		fmt.Fprintln(w)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if len(events) > reportEventsCount {
+		events = events[len(events)-reportEventsCount:]
+	}
+	for _, e := range events {
+		fmt.Fprintf(w, "    event %v %v %v: %v\n", eventTime(e).Format(time.RFC3339), e.Type, e.Reason, e.Message)
+	}
+}
+
+func eventTime(e apiCoreV1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
+
+func containerState(s apiCoreV1.ContainerState) string {
+	switch {
+	case s.Waiting != nil:
+		return strings.TrimSpace(fmt.Sprintf("Waiting(%v) %v", s.Waiting.Reason, s.Waiting.Message))
+	case s.Terminated != nil:
+		return strings.TrimSpace(fmt.Sprintf("Terminated(%v, exit code %d) %v", s.Terminated.Reason, s.Terminated.ExitCode, s.Terminated.Message))
+	case s.Running != nil:
+		return "Running"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	apiCoreV1 "k8s.io/api/core/v1"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	replicas := int32(2)
+	deployment := func(generation, observed int64, status appsV1.DeploymentStatus) *appsV1.Deployment {
+		status.ObservedGeneration = observed
+		return &appsV1.Deployment{
+			ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus", Generation: generation},
+			Spec:       appsV1.DeploymentSpec{Replicas: &replicas},
+			Status:     status,
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		d     *appsV1.Deployment
+		ready bool
+		err   bool
+	}{
+		{
+			name: "spec not observed",
+			d:    deployment(2, 1, appsV1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+		},
+		{
+			// The old version is still available so the previous check would count it as ready.
+			name: "old replicas still running",
+			d:    deployment(2, 2, appsV1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2}),
+		},
+		{
+			name: "updated replicas not available",
+			d:    deployment(2, 2, appsV1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}),
+		},
+		{
+			name: "progress deadline exceeded",
+			d: deployment(2, 2, appsV1.DeploymentStatus{Conditions: []appsV1.DeploymentCondition{
+				{Type: appsV1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"},
+			}}),
+			err: true,
+		},
+		{
+			name:  "done",
+			d:     deployment(2, 2, appsV1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			ready: true,
+		},
+	} {
+		ready, _, err := deploymentRolloutStatus(tc.d)
+		if ready != tc.ready || (err != nil) != tc.err {
+			t.Errorf("%v: expected ready:%v err:%v, got ready:%v err:%v", tc.name, tc.ready, tc.err, ready, err)
+		}
+	}
+}
+
+func TestStatefulSetAndDaemonSetRolloutStatus(t *testing.T) {
+	replicas := int32(1)
+	sts := &appsV1.StatefulSet{
+		ObjectMeta: apiMetaV1.ObjectMeta{Generation: 1},
+		Spec: appsV1.StatefulSetSpec{
+			Replicas:       &replicas,
+			UpdateStrategy: appsV1.StatefulSetUpdateStrategy{Type: appsV1.RollingUpdateStatefulSetStrategyType},
+		},
+		Status: appsV1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 1, CurrentRevision: "a", UpdateRevision: "b"},
+	}
+	if ready, _, _ := statefulSetRolloutStatus(sts); ready {
+		t.Error("a statefulset with pods at the old revision must not be ready")
+	}
+	sts.Status.CurrentRevision = "b"
+	if ready, _, _ := statefulSetRolloutStatus(sts); !ready {
+		t.Error("expected the statefulset to be ready")
+	}
+
+	ds := &appsV1.DaemonSet{
+		ObjectMeta: apiMetaV1.ObjectMeta{Generation: 1},
+		Spec:       appsV1.DaemonSetSpec{UpdateStrategy: appsV1.DaemonSetUpdateStrategy{Type: appsV1.RollingUpdateDaemonSetStrategyType}},
+		Status:     appsV1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 2},
+	}
+	if ready, _, _ := daemonSetRolloutStatus(ds); ready {
+		t.Error("a daemonset with unavailable pods must not be ready")
+	}
+	ds.Status.NumberAvailable = 3
+	if ready, _, _ := daemonSetRolloutStatus(ds); !ready {
+		t.Error("expected the daemonset to be ready")
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	job := &batchV1.Job{ObjectMeta: apiMetaV1.ObjectMeta{Name: "funcbench"}}
+	if done, _, err := jobStatus(job); done || err != nil {
+		t.Errorf("expected a running job, got done:%v err:%v", done, err)
+	}
+	job.Status.Conditions = []batchV1.JobCondition{{Type: batchV1.JobFailed, Status: apiCoreV1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+	if _, _, err := jobStatus(job); err == nil {
+		t.Error("expected an error for a failed job")
+	}
+	job.Status.Conditions = []batchV1.JobCondition{{Type: batchV1.JobComplete, Status: apiCoreV1.ConditionTrue}}
+	if done, _, err := jobStatus(job); !done || err != nil {
+		t.Errorf("expected a completed job, got done:%v err:%v", done, err)
+	}
+}
+
+func TestWritePodReport(t *testing.T) {
+	now := time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC)
+	pod := apiCoreV1.Pod{
+		ObjectMeta: apiMetaV1.ObjectMeta{Name: "prometheus-0"},
+		Spec:       apiCoreV1.PodSpec{NodeName: "nodes-1"},
+		Status: apiCoreV1.PodStatus{
+			Phase: apiCoreV1.PodRunning,
+			ContainerStatuses: []apiCoreV1.ContainerStatus{{
+				Name:                 "prometheus",
+				RestartCount:         4,
+				State:                apiCoreV1.ContainerState{Waiting: &apiCoreV1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: apiCoreV1.ContainerState{Terminated: &apiCoreV1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+			}},
+		},
+	}
+	var events []apiCoreV1.Event
+	for i := 0; i < reportEventsCount+2; i++ {
+		events = append(events, apiCoreV1.Event{
+			Type:          apiCoreV1.EventTypeWarning,
+			Reason:        "BackOff",
+			Message:       "restarting failed container",
+			LastTimestamp: apiMetaV1.NewTime(now.Add(time.Duration(-i) * time.Minute)),
+		})
+	}
+
+	var out bytes.Buffer
+	writePodReport(&out, pod, events)
+	for _, expected := range []string{
+		"pod prometheus-0 phase:Running node:nodes-1",
+		"container prometheus ready:false restarts:4 state:Waiting(CrashLoopBackOff) last state:Terminated(Error, exit code 1)",
+		"event 2020-07-01T10:00:00Z Warning BackOff: restarting failed container",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the report:\n%v", expected, out.String())
+		}
+	}
+	if n := strings.Count(out.String(), "event "); n != reportEventsCount {
+		t.Errorf("expected the last %d events, got %d", reportEventsCount, n)
+	}
+}
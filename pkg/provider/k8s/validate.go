@@ -0,0 +1,217 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	yamlGo "gopkg.in/yaml.v2"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	apiCoreV1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+// NodeNameLabel is the node label used by the manifests to select the node pool the pods run on.
+const NodeNameLabel = "node-name"
+
+// builtinNamespaces always exist so don't need a namespace manifest.
+var builtinNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// ValidationError is a problem found in a manifest file.
+type ValidationError struct {
+	FileName string
+	Object   string
+	Err      error
+}
+
+func (e ValidationError) Error() string {
+	if e.Object == "" {
+		return fmt.Sprintf("%v: %v", e.FileName, e.Err)
+	}
+	return fmt.Sprintf("%v: %v: %v", e.FileName, e.Object, e.Err)
+}
+
+// DecodeAndValidate decodes the parsed deployment files the same way K8SDeploymentsParse does
+// and runs Validate on the result. Unlike DecodeResources it doesn't stop at the first file that can't be decoded.
+func DecodeAndValidate(deploymentResource []provider.Resource, nodeNames map[string]bool) []error {
+	var (
+		errs      []error
+		resources []Resource
+	)
+	for _, deployment := range deploymentResource {
+		r, err := DecodeResources([]provider.Resource{deployment})
+		if err != nil {
+			errs = append(errs, ValidationError{FileName: deployment.FileName, Err: err})
+			continue
+		}
+		resources = append(resources, r...)
+	}
+	return append(errs, Validate(resources, nodeNames)...)
+}
+
+// Validate checks the k8s objects for references that no other object defines:
+//   - namespaces that are not created by any Namespace object.
+//   - service accounts that are not created by any ServiceAccount object.
+//   - node-name node selectors that don't match any of the nodeNames.
+//
+// The node selector check is skipped when nodeNames is nil.
+func Validate(resources []Resource, nodeNames map[string]bool) []error {
+	namespaces := map[string]bool{}
+	serviceAccounts := map[string]bool{}
+	for _, deployment := range resources {
+		for _, resource := range deployment.Objects {
+			switch r := resource.(type) {
+			case *apiCoreV1.Namespace:
+				namespaces[r.Name] = true
+			case *apiCoreV1.ServiceAccount:
+				serviceAccounts[namespaceOrDefault(r.Namespace)+"/"+r.Name] = true
+			}
+		}
+	}
+
+	var errs []error
+	for _, deployment := range resources {
+		for _, resource := range deployment.Objects {
+			obj, err := meta.Accessor(resource)
+			if err != nil {
+				errs = append(errs, ValidationError{FileName: deployment.FileName, Err: err})
+				continue
+			}
+			kind := resource.GetObjectKind().GroupVersionKind().Kind
+			name := fmt.Sprintf("%v %v", kind, obj.GetName())
+			addErr := func(format string, a ...interface{}) {
+				errs = append(errs, ValidationError{FileName: deployment.FileName, Object: name, Err: fmt.Errorf(format, a...)})
+			}
+
+			if ns := obj.GetNamespace(); ns != "" && !builtinNamespaces[ns] && !namespaces[ns] {
+				addErr("namespace %q is not defined by any Namespace manifest", ns)
+			}
+
+			if spec := podSpec(resource); spec != nil {
+				if sa := spec.ServiceAccountName; sa != "" && sa != "default" && !serviceAccounts[namespaceOrDefault(obj.GetNamespace())+"/"+sa] {
+					addErr("service account %q is not defined by any ServiceAccount manifest in namespace %q", sa, namespaceOrDefault(obj.GetNamespace()))
+				}
+				if nodeName, ok := spec.NodeSelector[NodeNameLabel]; ok && nodeNames != nil && !nodeNames[nodeName] {
+					addErr("nodeSelector %v=%q is not defined by any node pool", NodeNameLabel, nodeName)
+				}
+			}
+
+			for _, s := range bindingSubjects(resource) {
+				if s.Kind != rbac.ServiceAccountKind || s.Name == "default" {
+					continue
+				}
+				if !serviceAccounts[namespaceOrDefault(s.Namespace)+"/"+s.Name] {
+					addErr("subject service account %q is not defined by any ServiceAccount manifest in namespace %q", s.Name, namespaceOrDefault(s.Namespace))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func namespaceOrDefault(ns string) string {
+	if ns == "" {
+		return "default"
+	}
+	return ns
+}
+
+// podSpec returns the pod spec of the objects that run pods.
+func podSpec(resource runtime.Object) *apiCoreV1.PodSpec {
+	switch r := resource.(type) {
+	case *apiCoreV1.Pod:
+		return &r.Spec
+	case *appsV1.Deployment:
+		return &r.Spec.Template.Spec
+	case *appsV1.StatefulSet:
+		return &r.Spec.Template.Spec
+	case *appsV1.DaemonSet:
+		return &r.Spec.Template.Spec
+	case *batchV1.Job:
+		return &r.Spec.Template.Spec
+	}
+	return nil
+}
+
+func bindingSubjects(resource runtime.Object) []rbac.Subject {
+	switch r := resource.(type) {
+	case *rbac.RoleBinding:
+		return r.Subjects
+	case *rbac.ClusterRoleBinding:
+		return r.Subjects
+	}
+	return nil
+}
+
+// NodeNames returns the values of the node-name labels defined in the parsed node pool deployment files.
+// It understands any file format that defines the labels either as a yaml map or as
+// a comma separated node-labels string like the KIND cluster config.
+func NodeNames(deploymentResource []provider.Resource) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, deployment := range deploymentResource {
+		for _, text := range strings.Split(string(deployment.Content), provider.Separator) {
+			var content interface{}
+			if err := yamlGo.Unmarshal([]byte(text), &content); err != nil {
+				return nil, fmt.Errorf("error parsing the node pool deployment file %s:%v", deployment.FileName, err)
+			}
+			collectNodeNames(content, names)
+		}
+	}
+	return names, nil
+}
+
+func collectNodeNames(v interface{}, names map[string]bool) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, val := range v {
+			if k == NodeNameLabel {
+				if s, ok := val.(string); ok {
+					names[s] = true
+				}
+				continue
+			}
+			collectNodeNames(val, names)
+		}
+	case []interface{}:
+		for _, val := range v {
+			collectNodeNames(val, names)
+		}
+	case string:
+		// Nested yaml documents like the kubeadm config patches.
+		if strings.Contains(v, "\n") {
+			var content interface{}
+			if err := yamlGo.Unmarshal([]byte(v), &content); err == nil {
+				collectNodeNames(content, names)
+			}
+			return
+		}
+		// Labels passed as a string - "isolation=none,node-name=nodes-1".
+		for _, l := range strings.Split(v, ",") {
+			if kv := strings.SplitN(strings.TrimSpace(l), "=", 2); len(kv) == 2 && kv[0] == NodeNameLabel {
+				names[kv[1]] = true
+			}
+		}
+	}
+}
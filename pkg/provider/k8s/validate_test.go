@@ -0,0 +1,144 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/test-infra/pkg/provider"
+)
+
+func TestNodeNames(t *testing.T) {
+	nodes := []provider.Resource{
+		{
+			FileName: "nodes_gke.yaml",
+			Content: []byte(`
+nodepools:
+- name: prometheus
+  config:
+    labels:
+      isolation: prometheus
+      node-name: prometheus-1
+`),
+		},
+		{
+			FileName: "cluster_kind.yaml",
+			Content: []byte(`
+nodes:
+- role: worker
+  kubeadmConfigPatches:
+  - |
+    kind: JoinConfiguration
+    nodeRegistration:
+      kubeletExtraArgs:
+        node-labels: "isolation=none,node-name=main-node"
+`),
+		},
+	}
+
+	names, err := NodeNames(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"prometheus-1": true, "main-node": true}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("expect %v, got %v", expected, names)
+	}
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	resources := []provider.Resource{
+		{
+			FileName: "1_namespace.yaml",
+			Content: []byte(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: prombench
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: prometheus
+  namespace: prombench
+`),
+		},
+		{
+			FileName: "2_prometheus.yaml",
+			Content: []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: prometheus
+  namespace: prombench
+spec:
+  template:
+    spec:
+      serviceAccountName: prometheus
+      nodeSelector:
+        node-name: prometheus-1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: loadgen
+  namespace: loadgen
+spec:
+  template:
+    spec:
+      serviceAccountName: loadgen
+      nodeSelector:
+        node-name: missing-node
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: prometheus
+subjects:
+- kind: ServiceAccount
+  name: prometheus
+  namespace: default
+`),
+		},
+		{
+			FileName: "3_broken.yaml",
+			Content:  []byte("apiVersion: v1\nkind: ConfigMap\ndata: [\n"),
+		},
+	}
+
+	expected := []string{
+		"3_broken.yaml: ",
+		`2_prometheus.yaml: Deployment loadgen: namespace "loadgen" is not defined by any Namespace manifest`,
+		`2_prometheus.yaml: Deployment loadgen: service account "loadgen" is not defined by any ServiceAccount manifest in namespace "loadgen"`,
+		`2_prometheus.yaml: Deployment loadgen: nodeSelector node-name="missing-node" is not defined by any node pool`,
+		`2_prometheus.yaml: ClusterRoleBinding prometheus: subject service account "prometheus" is not defined by any ServiceAccount manifest in namespace "default"`,
+	}
+
+	errs := DecodeAndValidate(resources, map[string]bool{"prometheus-1": true})
+	if len(errs) != len(expected) {
+		t.Fatalf("expect %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), expected[i]) {
+			t.Errorf("expect error %q, got %q", expected[i], err)
+		}
+	}
+
+	// The node selectors are not checked without any node pools.
+	if errs := DecodeAndValidate(resources, nil); len(errs) != len(expected)-1 {
+		t.Errorf("expect %d errors, got %d: %v", len(expected)-1, len(errs), errs)
+	}
+}
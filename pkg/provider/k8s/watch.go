@@ -0,0 +1,89 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	apiMetaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// watchObject watches the object until cond returns true or an error, or the context is done.
+// cond is called with the live object after every change and with nil when the object doesn't exist.
+// It returns false without an error when the context is done first.
+//
+// Only the object itself is listed and watched so the load on the api server doesn't depend on the size of the namespace.
+// The watch is restarted when it expires or the connection is lost.
+func (c *K8s) watchObject(ctx context.Context, resource runtime.Object, cond func(*unstructured.Unstructured) (bool, error)) (bool, error) {
+	client, _, err := c.resourceClient(resource)
+	if err != nil {
+		return false, err
+	}
+	obj, err := meta.Accessor(resource)
+	if err != nil {
+		return false, err
+	}
+	key := obj.GetName()
+	if obj.GetNamespace() != "" {
+		key = obj.GetNamespace() + "/" + key
+	}
+
+	selector := fields.OneTermEqualSelector("metadata.name", obj.GetName()).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options apiMetaV1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return client.List(ctx, options)
+		},
+		WatchFunc: func(options apiMetaV1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return client.Watch(ctx, options)
+		},
+	}
+	precondition := func(store cache.Store) (bool, error) {
+		item, exists, err := store.GetByKey(key)
+		if err != nil || !exists {
+			return cond(nil)
+		}
+		return cond(item.(*unstructured.Unstructured))
+	}
+	condition := func(e watch.Event) (bool, error) {
+		switch e.Type {
+		case watch.Deleted:
+			return cond(nil)
+		case watch.Added, watch.Modified:
+			live, ok := e.Object.(*unstructured.Unstructured)
+			if !ok {
+				return false, fmt.Errorf("unexpected object in the watch event: %T", e.Object)
+			}
+			return cond(live)
+		}
+		return false, nil
+	}
+
+	if _, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, precondition, condition); err != nil {
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kind
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// localImages returns the images set with the LOCAL_IMAGES variable.
+// The images are separated by SEPARATOR like the other list variables.
+func (c *KIND) localImages() []string {
+	var images []string
+	for _, image := range strings.Split(c.DeploymentVars["LOCAL_IMAGES"], c.DeploymentVars["SEPARATOR"]) {
+		if image = strings.TrimSpace(image); image != "" {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// ImageLoad streams the images from the local docker daemon into every node of the cluster
+// so that they can be used without pushing them to a registry.
+// Nodes that already have the same image are skipped.
+func (c *KIND) ImageLoad(images []string) error {
+	clusterName := c.DeploymentVars["CLUSTER_NAME"]
+	if clusterName == "" {
+		return fmt.Errorf("missing required CLUSTER_NAME variable")
+	}
+	nodeList, err := c.kindProvider.ListInternalNodes(clusterName)
+	if err != nil {
+		return errors.Wrapf(err, "listing the nodes of cluster %v", clusterName)
+	}
+	if len(nodeList) == 0 {
+		return fmt.Errorf("no nodes found for cluster %v", clusterName)
+	}
+
+	ctx := c.DeploymentResource.Context()
+	for _, image := range images {
+		id, err := localImageID(ctx, image)
+		if err != nil {
+			return errors.Wrapf(err, "image %v is not present in the local docker daemon", image)
+		}
+
+		errs := make(chan error, len(nodeList))
+		for _, node := range nodeList {
+			go func(node nodes.Node) {
+				errs <- loadImage(ctx, image, id, node)
+			}(node)
+		}
+		for range nodeList {
+			if e := <-errs; e != nil && err == nil {
+				err = e
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadImage pipes docker save into the containerd of the node.
+func loadImage(ctx context.Context, image, id string, node nodes.Node) error {
+	if nodeID, err := nodeutils.ImageID(node, image); err == nil && nodeID == id {
+		log.Printf("image %v is already present on node %v", image, node)
+		return nil
+	}
+	log.Printf("loading image %v into node %v", image, node)
+
+	save := exec.CommandContext(ctx, "docker", "save", image)
+	if err := exec.RunWithStdoutReader(save, func(r io.Reader) error {
+		return nodeutils.LoadImageArchive(node, r)
+	}); err != nil {
+		return errors.Wrapf(err, "loading image %v into node %v", image, node)
+	}
+	return nil
+}
+
+// localImageID returns the id of the image in the local docker daemon.
+func localImageID(ctx context.Context, image string) (string, error) {
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, "docker", "image", "inspect", "-f", "{{ .Id }}", image))
+	if err != nil {
+		return "", err
+	}
+	if len(lines) != 1 {
+		return "", fmt.Errorf("unexpected docker image inspect output: %v", lines)
+	}
+	return lines[0], nil
+}
@@ -14,149 +14,88 @@
 package kind
 
 import (
-	"context"
 	"fmt"
-	"strings"
+	"log"
+	"path/filepath"
 
-	"github.com/pkg/errors"
 	"github.com/prometheus/test-infra/pkg/provider"
 	k8sProvider "github.com/prometheus/test-infra/pkg/provider/k8s"
 	"gopkg.in/alecthomas/kingpin.v2"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	apiCoreV1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/kind/pkg/cluster"
 	"sigs.k8s.io/kind/pkg/cmd"
 )
 
-type Resource = provider.Resource
+func init() {
+	provider.Register("kind", "Kubernetes In Docker (KIND) provider - https://kind.sigs.k8s.io/docs/user/quick-start/", func(dr *provider.DeploymentResource) provider.Provider {
+		return New(dr)
+	})
+}
+
+var (
+	_ provider.ClusterProvider    = (*KIND)(nil)
+	_ provider.ResourceProvider   = (*KIND)(nil)
+	_ provider.ImageLoader        = (*KIND)(nil)
+	_ provider.KubeconfigProvider = (*KIND)(nil)
+	_ provider.Destroyer          = (*KIND)(nil)
+)
 
 // KIND holds the fields used to generate an API request.
 type KIND struct {
+	k8sProvider.Base
 
-	// The k8s provider used when we work with the manifest files.
-	k8sProvider *k8sProvider.K8s
 	// The kind provider used to instantiate a new provider.
 	kindProvider *cluster.Provider
-	// Final DeploymentFiles files.
-	DeploymentFiles []string
-	// Final DeploymentVars.
-	DeploymentVars map[string]string
-	// DeployResource to construct DeploymentVars and DeploymentFiles
-	DeploymentResource *provider.DeploymentResource
-	// Content bytes after parsing the template variables, grouped by filename.
-	kindResources []Resource
-	// K8s resource.runtime objects after parsing the template variables, grouped by filename.
-	k8sResources []k8sProvider.Resource
-
-	ctx context.Context
-	// KIND kuberconfig file
+
+	// The kubeconfig file that the cluster credentials are written to,
+	// the default loading rules of kubectl are used when empty.
 	kubeconfig string
 }
 
 // New is the KIND constructor.
 func New(dr *provider.DeploymentResource) *KIND {
+	base := k8sProvider.NewBase(dr, "CLUSTER_NAME")
+	base.CustomDeploymentVars = map[string]string{
+		"NGINX_SERVICE_TYPE":        "NodePort",
+		"LOADGEN_SCALE_UP_REPLICAS": "2",
+		"LOCAL_IMAGES":              "",
+	}
 	return &KIND{
-		DeploymentResource: dr,
+		Base: base,
 		kindProvider: cluster.NewProvider(
 			cluster.ProviderWithLogger(cmd.NewLogger()),
 		),
-		ctx:        context.Background(),
-		kubeconfig: homedir.HomeDir() + "/.kube/config",
-	}
-}
-
-// SetupDeploymentResources Sets up DeploymentVars and DeploymentFiles
-func (c *KIND) SetupDeploymentResources(*kingpin.ParseContext) error {
-	customDeploymentVars := map[string]string{
-		"NGINX_SERVICE_TYPE":        "NodePort",
-		"LOADGEN_SCALE_UP_REPLICAS": "2",
-	}
-
-	c.DeploymentFiles = c.DeploymentResource.DeploymentFiles
-	c.DeploymentVars = provider.MergeDeploymentVars(
-		c.DeploymentResource.DefaultDeploymentVars,
-		customDeploymentVars,
-		c.DeploymentResource.FlagDeploymentVars,
-	)
-	return nil
-}
-
-// KINDDeploymentsParse parses the environment/kind deployment files and saves the result as bytes grouped by the filename.
-// Any DeploymentVar will be replaced in the resources files following the golang text template format.
-func (c *KIND) KINDDeploymentsParse(*kingpin.ParseContext) error {
-	if err := c.checkDeploymentVarsAndFiles(); err != nil {
-		return err
-	}
-
-	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
-	if err != nil {
-		return err
-	}
-	c.kindResources = deploymentResource
-	return nil
-}
-
-func (c *KIND) K8SDeploymentsParse(*kingpin.ParseContext) error {
-	if err := c.checkDeploymentVarsAndFiles(); err != nil {
-		return err
-	}
-
-	deploymentResource, err := provider.DeploymentsParse(c.DeploymentFiles, c.DeploymentVars)
-	if err != nil {
-		return err
-	}
-	for _, deployment := range deploymentResource {
-
-		decode := scheme.Codecs.UniversalDeserializer().Decode
-		k8sObjects := make([]runtime.Object, 0)
-
-		for _, text := range strings.Split(string(deployment.Content), provider.Separator) {
-			text = strings.TrimSpace(text)
-			if len(text) == 0 {
-				continue
-			}
-
-			resource, _, err := decode([]byte(text), nil, nil)
-			if err != nil {
-				return errors.Wrapf(err, "decoding the resource file:%v, section:%v...", deployment.FileName, text[:100])
-			}
-			if resource == nil {
-				continue
-			}
-			k8sObjects = append(k8sObjects, resource)
-		}
-		if len(k8sObjects) > 0 {
-			c.k8sResources = append(c.k8sResources, k8sProvider.Resource{FileName: deployment.FileName, Objects: k8sObjects})
-		}
 	}
-	return nil
 }
 
-// checkDeploymentVarsAndFiles checks whether the requied deployment vars are passed.
-func (c *KIND) checkDeploymentVarsAndFiles() error {
-	reqDepVars := []string{"CLUSTER_NAME"}
-	for _, k := range reqDepVars {
-		if v, ok := c.DeploymentVars[k]; !ok || v == "" {
-			return fmt.Errorf("missing required %v variable", k)
-		}
-	}
-	if len(c.DeploymentFiles) == 0 {
-		return fmt.Errorf("missing deployment file(s)")
-	}
-	return nil
+// Flags adds the flag that selects the kubeconfig file.
+func (c *KIND) Flags(cmd *kingpin.CmdClause) {
+	cmd.Flag("kubeconfig", "kubeconfig file that the cluster credentials are written to and read from, use a separate file per cluster to keep them isolated. Defaults to the KUBECONFIG env variable or ~/.kube/config like kubectl.").
+		PlaceHolder("file").
+		StringVar(&c.kubeconfig)
 }
 
 // ClusterCreate create a new cluster or applies changes to an existing cluster.
 func (c *KIND) ClusterCreate(*kingpin.ParseContext) error {
-	for _, deployment := range c.kindResources {
+	for _, deployment := range c.ProviderResources {
 		CreateWithConfigFile := cluster.CreateWithRawConfig(deployment.Content)
 
-		err := c.kindProvider.Create(c.DeploymentVars["CLUSTER_NAME"], CreateWithConfigFile)
+		err := c.kindProvider.Create(c.DeploymentVars["CLUSTER_NAME"], CreateWithConfigFile, cluster.CreateWithKubeconfigPath(c.kubeconfig))
+		if err != nil {
+			return err
+		}
+
+		settings, err := c.stateSettings()
 		if err != nil {
 			return err
 		}
+		if err := c.Record(provider.StateEntry{
+			Kind:     provider.StateCluster,
+			Name:     c.DeploymentVars["CLUSTER_NAME"],
+			Settings: settings,
+		}); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -167,45 +106,91 @@ func (c *KIND) ClusterDelete(*kingpin.ParseContext) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.Forget(provider.StateEntry{Kind: provider.StateCluster, Name: c.DeploymentVars["CLUSTER_NAME"]})
+}
+
+// stateSettings returns the kubeconfig file recorded with the objects in the state file,
+// as an absolute path so destroy can find it from any directory.
+func (c *KIND) stateSettings() (map[string]string, error) {
+	if c.kubeconfig == "" {
+		return nil, nil
+	}
+	kubeconfig, err := filepath.Abs(c.kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"kubeconfig": kubeconfig}, nil
+}
+
+// contextName is the kubeconfig context that KIND creates for the cluster.
+func (c *KIND) contextName() string {
+	return "kind-" + c.DeploymentVars["CLUSTER_NAME"]
 }
 
 // NewK8sProvider sets the k8s provider used for deploying k8s manifests.
 func (c *KIND) NewK8sProvider(*kingpin.ParseContext) error {
-	var err error
-	apiConfig, err := clientcmd.LoadFromFile(c.kubeconfig)
+	apiConfig, err := k8sProvider.LoadKubeconfig(c.kubeconfig, c.contextName())
 	if err != nil {
 		return err
 	}
+	if c.StateSettings, err = c.stateSettings(); err != nil {
+		return err
+	}
 
-	c.k8sProvider, err = k8sProvider.New(c.ctx, apiConfig)
+	c.K8sProvider, err = k8sProvider.New(c.DeploymentResource.Context(), apiConfig)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// ResourceApply calls k8s.ResourceApply to apply the k8s objects in the manifest files.
-func (c *KIND) ResourceApply(*kingpin.ParseContext) error {
-	if err := c.k8sProvider.ResourceApply(c.k8sResources); err != nil {
-		return err
+// Kubeconfig returns the kubeconfig of the cluster from the KIND nodes,
+// it doesn't need the kubeconfig file that was written when creating the cluster.
+func (c *KIND) Kubeconfig() ([]byte, error) {
+	if err := c.CheckDeploymentVars(); err != nil {
+		return nil, err
 	}
-	return nil
+	kubeconfig, err := c.kindProvider.KubeConfig(c.DeploymentVars["CLUSTER_NAME"], false)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(kubeconfig), nil
 }
 
-// ResourceDelete calls k8s.ResourceDelete to apply the k8s objects in the manifest files.
-func (c *KIND) ResourceDelete(*kingpin.ParseContext) error {
-	if err := c.k8sProvider.ResourceDelete(c.k8sResources); err != nil {
+// Destroy deletes a cluster or namespace recorded in the state file.
+func (c *KIND) Destroy(e provider.StateEntry) error {
+	c.kubeconfig = e.Settings["kubeconfig"]
+	switch e.Kind {
+	case provider.StateCluster:
+		return c.kindProvider.Delete(e.Name, c.kubeconfig)
+	case provider.StateNamespace:
+		if err := c.NewK8sProvider(nil); err != nil {
+			return err
+		}
+		return c.K8sProvider.NamespaceDelete(e.Name)
+	}
+	return fmt.Errorf("unknown state entry kind %q", e.Kind)
+}
+
+// K8SDeploymentsParse parses the k8s manifest files and sets the pull policy of the containers
+// that run one of the LOCAL_IMAGES to IfNotPresent so the images loaded into the nodes are used.
+func (c *KIND) K8SDeploymentsParse(ctx *kingpin.ParseContext) error {
+	if err := c.Base.K8SDeploymentsParse(ctx); err != nil {
 		return err
 	}
+	if images := c.localImages(); len(images) > 0 {
+		n := k8sProvider.SetImagePullPolicy(c.K8sResources, images, apiCoreV1.PullIfNotPresent)
+		log.Printf("image pull policy set to %v for %v containers using the local images", apiCoreV1.PullIfNotPresent, n)
+	}
 	return nil
 }
 
-// GetDeploymentVars shows deployment variables.
-func (c *KIND) GetDeploymentVars(parseContext *kingpin.ParseContext) error {
-	fmt.Print("-------------------\n   DeploymentVars   \n------------------- \n")
-	for key, value := range c.DeploymentVars {
-		fmt.Println(key, ": ", value)
+// ResourceApply loads the LOCAL_IMAGES into the cluster nodes and then applies the k8s objects.
+func (c *KIND) ResourceApply(opts provider.ApplyOptions) error {
+	if images := c.localImages(); len(images) > 0 {
+		if err := c.ImageLoad(images); err != nil {
+			return err
+		}
 	}
-	return nil
+	return c.Base.ResourceApply(opts)
 }
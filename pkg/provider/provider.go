@@ -15,9 +15,12 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,11 +28,25 @@ import (
 	"time"
 )
 
-const (
-	EKSRetryCount    = 100
-	GlobalRetryCount = 50
-	Separator        = "---"
-	globalRetryTime  = 10 * time.Second
+const Separator = "---"
+
+var (
+	// DefaultBackoff is used when waiting for the cloud operations to complete.
+	DefaultBackoff = Backoff{
+		Initial: 10 * time.Second,
+		Max:     30 * time.Second,
+		Factor:  1.5,
+		Jitter:  0.2,
+		Timeout: 10 * time.Minute,
+	}
+	// EKSBackoff is used when waiting for EKS clusters and node groups which take longer to create.
+	EKSBackoff = Backoff{
+		Initial: 10 * time.Second,
+		Max:     30 * time.Second,
+		Factor:  1.5,
+		Jitter:  0.2,
+		Timeout: 20 * time.Minute,
+	}
 )
 
 // DeploymentResource holds list of variables and corresponding files.
@@ -38,8 +55,47 @@ type DeploymentResource struct {
 	DeploymentFiles []string
 	// DeploymentVars provided from the cli.
 	FlagDeploymentVars map[string]string
+	// VarsFiles provided from the cli.
+	VarsFiles []string
+	// DeploymentVars loaded from the VarsFiles in the same order.
+	FileDeploymentVars []VarsSource
 	// Default DeploymentVars.
 	DefaultDeploymentVars map[string]string
+	// StateFile records the created objects so that they can be destroyed later.
+	// Nothing is recorded when it is empty.
+	StateFile string
+	// ProviderName is the name of the running provider command, it is recorded in the state file.
+	ProviderName string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Context returns the context of the running command, a background context when none was set.
+// The providers use it for all their requests so that cancelling it stops the command.
+func (d *DeploymentResource) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// SetContext sets the context of the running command.
+func (d *DeploymentResource) SetContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+// SetTimeout limits the total time of the running command.
+// The context returned by Context is cancelled when the timeout is reached.
+func (d *DeploymentResource) SetTimeout(timeout time.Duration) {
+	d.ctx, d.cancel = context.WithTimeout(d.Context(), timeout)
+}
+
+// Cancel releases the context set by SetTimeout, it is called when the command returns.
+func (d *DeploymentResource) Cancel() {
+	if d.cancel != nil {
+		d.cancel()
+	}
 }
 
 // NewDeploymentResource returns DeploymentResource with default values.
@@ -62,36 +118,84 @@ type Resource struct {
 	Content  []byte
 }
 
+// Backoff sets how long RetryUntilTrue waits between the checks.
+type Backoff struct {
+	// Initial is the wait before the first check.
+	Initial time.Duration
+	// Max caps the wait between the checks.
+	Max time.Duration
+	// Factor multiplies the wait after every check.
+	Factor float64
+	// Jitter adds a random wait of up to Jitter times the wait
+	// so that parallel checks don't all hit the API at the same time.
+	Jitter float64
+	// Timeout is the total time to retry for, no limit when zero.
+	Timeout time.Duration
+}
+
+// next returns the wait that follows the given wait.
+func (b Backoff) next(wait time.Duration) time.Duration {
+	next := time.Duration(float64(wait) * b.Factor)
+	if b.Max > 0 && next > b.Max {
+		return b.Max
+	}
+	return next
+}
+
+// jitter returns the wait with the random jitter added.
+func (b Backoff) jitter(wait time.Duration) time.Duration {
+	if b.Jitter <= 0 {
+		return wait
+	}
+	return wait + time.Duration(rand.Float64()*b.Jitter*float64(wait))
+}
+
 // RetryUntilTrue returns when there is an error or the requested operation returns true.
-func RetryUntilTrue(name string, retryCount int, fn func() (bool, error)) error {
-	for i := 1; i <= retryCount; i++ {
-		time.Sleep(globalRetryTime)
+// It waits before every check as set by the backoff and returns an error
+// when the context is cancelled or the backoff timeout is reached first.
+func RetryUntilTrue(ctx context.Context, name string, b Backoff, fn func() (bool, error)) error {
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	for wait := b.Initial; ; wait = b.next(wait) {
+		delay := b.jitter(wait)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("Request for '%v' hasn't completed after %v: %v", name, time.Since(start).Round(time.Second), ctx.Err())
+		case <-timer.C:
+		}
+
 		if ready, err := fn(); err != nil {
 			return err
 		} else if !ready {
-			log.Printf("Request for '%v' is in progress. Checking in %v", name, globalRetryTime)
+			log.Printf("Request for '%v' is in progress. Checking in %v", name, b.next(wait).Round(time.Second))
 			continue
 		}
 		log.Printf("Request for '%v' is done!", name)
 		return nil
 	}
-	return fmt.Errorf("Request for '%v' hasn't completed after retrying %d times", name, retryCount)
+}
+
+// parseTemplate parses the deployment file content as a golang template.
+// Executing it fails on any variable that is not set, use missingVars to report all of them first.
+func parseTemplate(content []byte) (*template.Template, error) {
+	t, err := template.New("resource").Option("missingkey=error").Funcs(templateFuncs()).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse file err: %s", err)
+	}
+	return t, nil
 }
 
 // applyTemplateVars applies golang templates to deployment files.
-func applyTemplateVars(content []byte, deploymentVars map[string]string) ([]byte, error) {
+// The optional variables that are not set are empty so that they can be handled with default.
+func applyTemplateVars(t *template.Template, deploymentVars map[string]string) ([]byte, error) {
 	fileContentParsed := bytes.NewBufferString("")
-	t := template.New("resource").Option("missingkey=error")
-	t = t.Funcs(template.FuncMap{
-		// k8s objects can't have dots(.) se we add a custom function to allow normalising the variable values.
-		"normalise": func(t string) string {
-			return strings.Replace(t, ".", "-", -1)
-		},
-		"split": func(rangeVars, separator string) []string {
-			return strings.Split(rangeVars, separator)
-		},
-	})
-	if err := template.Must(t.Parse(string(content))).Execute(fileContentParsed, deploymentVars); err != nil {
+	if err := t.Execute(fileContentParsed, executionVars(t, deploymentVars)); err != nil {
 		return nil, fmt.Errorf("Failed to execute parse file err: %s", err)
 	}
 	return fileContentParsed.Bytes(), nil
@@ -99,6 +203,7 @@ func applyTemplateVars(content []byte, deploymentVars map[string]string) ([]byte
 
 // DeploymentsParse parses the deployment files and returns the result as bytes grouped by the filename.
 // Any variables passed to the cli will be replaced in the resources files following the golang text template format.
+// All variables that are used in the files but not set are reported together in a MissingVarsError.
 func DeploymentsParse(deploymentFiles []string, deploymentVars map[string]string) ([]Resource, error) {
 	var fileList []string
 	for _, name := range deploymentFiles {
@@ -117,31 +222,73 @@ func DeploymentsParse(deploymentFiles []string, deploymentVars map[string]string
 	}
 
 	deploymentObjects := make([]Resource, 0)
+	templates := make(map[string]*template.Template)
+	missing := MissingVarsError{}
 	for _, name := range fileList {
 		absFileName := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
 		content, err := ioutil.ReadFile(name)
 		if err != nil {
 			log.Fatalf("Error reading file %v:%v", name, err)
 		}
+		deploymentObjects = append(deploymentObjects, Resource{FileName: name, Content: content})
+
 		// Don't parse file with the suffix "noparse".
-		if !strings.HasSuffix(absFileName, "noparse") {
-			content, err = applyTemplateVars(content, deploymentVars)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't apply template to file %s: %v", name, err)
-			}
+		if strings.HasSuffix(absFileName, "noparse") {
+			continue
 		}
-		deploymentObjects = append(deploymentObjects, Resource{FileName: name, Content: content})
+		t, err := parseTemplate(content)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't apply template to file %s: %v", name, err)
+		}
+		templates[name] = t
+		if m := missingVars(t, deploymentVars); len(m) > 0 {
+			missing[name] = m
+		}
+	}
+	if len(missing) > 0 {
+		return nil, missing
+	}
+
+	for i, r := range deploymentObjects {
+		t, ok := templates[r.FileName]
+		if !ok {
+			continue
+		}
+		content, err := applyTemplateVars(t, deploymentVars)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't apply template to file %s: %v", r.FileName, err)
+		}
+		deploymentObjects[i].Content = content
 	}
 	return deploymentObjects, nil
 }
 
-// MergeDeploymentVars merges multiple maps based on the order.
-func MergeDeploymentVars(ms ...map[string]string) map[string]string {
-	res := map[string]string{}
-	for _, m := range ms {
-		for k, v := range m {
-			res[k] = v
+// RenderResources writes the parsed resources to outDir keeping the path of the source files,
+// or to w separated by the yaml document separator when outDir is empty.
+func RenderResources(w io.Writer, outDir string, resources []Resource) error {
+	for i, r := range resources {
+		if outDir == "" {
+			if i > 0 {
+				fmt.Fprintln(w, Separator)
+			}
+			fmt.Fprintf(w, "# Source: %v\n", r.FileName)
+			if _, err := w.Write(r.Content); err != nil {
+				return err
+			}
+			if !bytes.HasSuffix(r.Content, []byte("\n")) {
+				fmt.Fprintln(w)
+			}
+			continue
+		}
+
+		// Absolute and parent paths are kept inside the output folder.
+		name := filepath.Join(outDir, filepath.Clean("/"+r.FileName))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return fmt.Errorf("error creating the output directory: %v", err)
+		}
+		if err := ioutil.WriteFile(name, r.Content, 0644); err != nil {
+			return fmt.Errorf("error writing file %v: %v", name, err)
 		}
 	}
-	return res
+	return nil
 }
@@ -14,46 +14,121 @@
 package provider
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
-func TestMergeDeploymentVars(t *testing.T) {
-	dv1 := map[string]string{
-		"foo": "apple",
-		"bar": "orange",
-	}
-	dv2 := map[string]string{
-		"foo":  "mango",
-		"baz":  "banana",
-		"buzz": "jackfruit",
-	}
-	dv3 := map[string]string{
-		"foo": "grape",
-		"baz": "blueberry",
-	}
-	testCases := []struct {
-		vars   []map[string]string
-		merged map[string]string
-	}{
-		{
-			vars:   []map[string]string{dv1, dv2, dv3},
-			merged: map[string]string{"bar": "orange", "baz": "blueberry", "buzz": "jackfruit", "foo": "grape"},
-		},
-		{
-			vars:   []map[string]string{dv3, dv2, dv1},
-			merged: map[string]string{"bar": "orange", "baz": "banana", "buzz": "jackfruit", "foo": "apple"},
-		},
-		{
-			vars:   []map[string]string{dv3, dv1, dv2},
-			merged: map[string]string{"bar": "orange", "baz": "banana", "buzz": "jackfruit", "foo": "mango"},
-		},
-	}
-
-	for _, tc := range testCases {
-		r := MergeDeploymentVars(tc.vars...)
-		if eq := reflect.DeepEqual(tc.merged, r); !eq {
-			t.Errorf("\nexpect %#v\ngot %#v", tc.merged, r)
+func TestRenderResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"manifests/1_namespace.yaml":          "name: prombench-{{ .PR_NUMBER }}",
+		"manifests/2_dashboards_noparse.yaml": "expr: '{{ $labels.instance }}'\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resources, err := DeploymentsParse([]string{filepath.Join(dir, "manifests")}, map[string]string{"PR_NUMBER": "123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := RenderResources(&out, "", resources); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("# Source: %[1]v/manifests/1_namespace.yaml\nname: prombench-123\n---\n# Source: %[1]v/manifests/2_dashboards_noparse.yaml\nexpr: '{{ $labels.instance }}'\n", dir)
+	if out.String() != expected {
+		t.Errorf("\nexpect %q\ngot %q", expected, out.String())
+	}
+
+	outDir := filepath.Join(dir, "out")
+	if err := RenderResources(nil, outDir, resources); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(outDir, dir, "manifests/2_dashboards_noparse.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != files["manifests/2_dashboards_noparse.yaml"] {
+		t.Errorf("noparse file should be written unchanged, got %q", content)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: 10 * time.Second, Max: 30 * time.Second, Factor: 2, Jitter: 0.5}
+
+	var waits []time.Duration
+	for wait, i := b.Initial, 0; i < 4; wait, i = b.next(wait), i+1 {
+		waits = append(waits, wait)
+	}
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	if !reflect.DeepEqual(expected, waits) {
+		t.Errorf("expected waits %v, got %v", expected, waits)
+	}
+
+	for i := 0; i < 100; i++ {
+		if d := b.jitter(10 * time.Second); d < 10*time.Second || d > 15*time.Second {
+			t.Fatalf("jittered wait %v is out of the range [10s, 15s]", d)
 		}
 	}
 }
+
+func TestRetryUntilTrue(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Factor: 2, Jitter: 0.1}
+
+	checks := 0
+	err := RetryUntilTrue(context.Background(), "done", b, func() (bool, error) {
+		checks++
+		return checks == 3, nil
+	})
+	if err != nil || checks != 3 {
+		t.Errorf("expected 3 checks without an error, got %v checks and err:%v", checks, err)
+	}
+
+	errCheck := fmt.Errorf("check failed")
+	if err := RetryUntilTrue(context.Background(), "failing", b, func() (bool, error) {
+		return false, errCheck
+	}); err != errCheck {
+		t.Errorf("expected the error of the check, got %v", err)
+	}
+
+	b.Timeout = 20 * time.Millisecond
+	if err := RetryUntilTrue(context.Background(), "timeout", b, func() (bool, error) {
+		return false, nil
+	}); err == nil {
+		t.Error("expected an error when the timeout is reached")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b = Backoff{Initial: time.Hour}
+	go cancel()
+	start := time.Now()
+	if err := RetryUntilTrue(ctx, "cancelled", b, func() (bool, error) {
+		t.Error("unexpected check after the context was cancelled")
+		return false, nil
+	}); err == nil {
+		t.Error("expected an error when the context is cancelled")
+	}
+	if time.Since(start) > time.Minute {
+		t.Error("expected to return right after the context is cancelled")
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Provider is implemented by every provider that the infra cli can manage.
+// Each lifecycle is optional and is enabled by implementing
+// ClusterProvider, NodePoolProvider or ResourceProvider.
+type Provider interface {
+	// Flags adds the provider specific flags to the provider command.
+	Flags(*kingpin.CmdClause)
+	// SetupDeploymentResources sets up the final DeploymentVars and DeploymentFiles.
+	SetupDeploymentResources(*kingpin.ParseContext) error
+	// GetDeploymentVars shows the final deployment variables.
+	GetDeploymentVars(*kingpin.ParseContext) error
+	// NewClient sets the client used when talking to the provider API.
+	NewClient(*kingpin.ParseContext) error
+	// DeploymentsParse parses the cluster and node pool deployment files.
+	DeploymentsParse(*kingpin.ParseContext) error
+}
+
+// ClusterProvider is implemented by providers that manage the cluster lifecycle.
+type ClusterProvider interface {
+	Provider
+	ClusterCreate(*kingpin.ParseContext) error
+	ClusterDelete(*kingpin.ParseContext) error
+}
+
+// KubeconfigProvider is implemented by providers that can export the kubeconfig of their cluster.
+type KubeconfigProvider interface {
+	Provider
+	// Kubeconfig returns a kubeconfig file with the credentials to access the cluster.
+	Kubeconfig() ([]byte, error)
+}
+
+// NodePoolProvider is implemented by providers that manage the node pool lifecycle.
+type NodePoolProvider interface {
+	Provider
+	NodesCreate(*kingpin.ParseContext) error
+	NodesDelete(*kingpin.ParseContext) error
+	AllNodesRunning(*kingpin.ParseContext) error
+	AllNodesDeleted(*kingpin.ParseContext) error
+}
+
+// NodePoolApplier is implemented by node pool providers that can change
+// the existing node pools to match the deployment files.
+type NodePoolApplier interface {
+	NodePoolProvider
+	// NodesApply creates the missing node pools and updates, or recreates when that is the only way, the changed ones.
+	// With DryRun it only shows the changes and returns ErrPendingChanges when there are any.
+	NodesApply(opts NodesApplyOptions) error
+}
+
+// NodePoolCoster is implemented by node pool providers that can tell
+// the machine types of the node pools in the deployment files.
+type NodePoolCoster interface {
+	NodePoolProvider
+	// NodePoolSpecs returns the machine type and the number of nodes of every node pool in the deployment files.
+	NodePoolSpecs() ([]NodePoolSpec, error)
+}
+
+// ImageLoader is implemented by providers that can copy container images
+// from the local docker daemon to the cluster nodes.
+type ImageLoader interface {
+	Provider
+	// ImageLoad loads the images into every node of the cluster.
+	ImageLoad(images []string) error
+}
+
+// Destroyer is implemented by providers that record the objects they create in the state file.
+type Destroyer interface {
+	Provider
+	// Destroy deletes the recorded object and waits for it to be gone.
+	// It is not an error when the object doesn't exist anymore.
+	Destroy(StateEntry) error
+}
+
+// GarbageCollector is implemented by providers that can find the node pools and namespaces
+// of benchmarks which weren't cleaned up.
+type GarbageCollector interface {
+	Provider
+	// GC deletes the benchmark node pools and namespaces that are older than the options allow.
+	// With DryRun it only shows them and returns ErrPendingChanges when there are any.
+	GC(GCOptions) error
+}
+
+// ResourceProvider is implemented by providers that deploy k8s manifests.
+type ResourceProvider interface {
+	Provider
+	// K8SDeploymentsParse parses the k8s manifest files into k8s objects.
+	K8SDeploymentsParse(*kingpin.ParseContext) error
+	// NewK8sProvider sets the k8s client used to apply and delete the k8s objects.
+	NewK8sProvider(*kingpin.ParseContext) error
+	ResourceApply(ApplyOptions) error
+	ResourceDelete(DeleteOptions) error
+	// ResourcePlan shows the changes that applying, or deleting when del is true, the k8s objects would make.
+	// It returns ErrPendingChanges when there are any changes.
+	ResourcePlan(del bool) error
+}
+
+// ApplyOptions changes the behaviour of ResourceProvider.ResourceApply.
+type ApplyOptions struct {
+	// Prune deletes the previously applied objects that are no longer in the manifest files.
+	Prune bool
+	// ArtifactsDir is where a tarball with debugging information is written when applying fails.
+	// Nothing is written when it is empty.
+	ArtifactsDir string
+}
+
+// NodesApplyOptions changes the behaviour of NodePoolApplier.NodesApply.
+type NodesApplyOptions struct {
+	// DryRun only shows the changes to the node pools.
+	DryRun bool
+	// Recreate allows recreating the node pools with changes that could be made in place
+	// but aren't supported by the provider api, otherwise these are refused.
+	Recreate bool
+}
+
+// DeleteOptions changes the behaviour of ResourceProvider.ResourceDelete.
+type DeleteOptions struct {
+	// PropagationPolicy sets how the dependents of the deleted objects are deleted,
+	// one of foreground, background or orphan.
+	PropagationPolicy string
+	// WaitTimeout is how long to wait for all deleted objects to be gone.
+	WaitTimeout time.Duration
+}
+
+// ErrPendingChanges is returned by ResourcePlan when applying the k8s objects would change the cluster.
+var ErrPendingChanges = errors.New("the plan has pending changes")
+
+// Factory returns a new provider which uses the given DeploymentResource.
+type Factory func(*DeploymentResource) Provider
+
+type registration struct {
+	name string
+	help string
+	new  Factory
+}
+
+var (
+	registryMtx sync.Mutex
+	registry    = map[string]registration{}
+)
+
+// Register makes a provider available to the infra cli under the given name.
+// It panics when called twice with the same name.
+func Register(name, help string, f Factory) {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("provider %q already registered", name))
+	}
+	registry[name] = registration{name: name, help: help, new: f}
+}
+
+// Registered returns the names of all registered providers in sorted order.
+func Registered() []string {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterCommands adds a command for every registered provider to the app.
+// The sub commands are added based on the lifecycles the provider implements.
+// It also adds the destroy command which deletes everything recorded in the state file.
+func RegisterCommands(app *kingpin.Application, dr *DeploymentResource) {
+	for _, name := range Registered() {
+		registryMtx.Lock()
+		r := registry[name]
+		registryMtx.Unlock()
+
+		addProviderCommands(app, r, r.new(dr), dr)
+	}
+
+	timeoutFlag(app.Command("destroy", "destroy --state FILE. Deletes everything recorded in the state file in the reverse order of creation, the deployment files are not needed. The provider credentials are taken from their env variables.").
+		Action(func(*kingpin.ParseContext) error {
+			return Destroy(dr)
+		}), dr)
+}
+
+// timeoutFlag adds a --timeout flag which limits the total time of the command.
+func timeoutFlag(cmd *kingpin.CmdClause, dr *DeploymentResource) {
+	var timeout time.Duration
+	cmd.Flag("timeout", "How long the command may take before it is cancelled, no limit when zero.").
+		Default("0").
+		DurationVar(&timeout)
+	// Pre actions run before all actions so the timeout also covers setting up the clients.
+	cmd.PreAction(func(*kingpin.ParseContext) error {
+		if timeout > 0 {
+			dr.SetTimeout(timeout)
+		}
+		return nil
+	})
+}
+
+func addProviderCommands(app *kingpin.Application, r registration, p Provider, dr *DeploymentResource) {
+	cmd := app.Command(r.name, r.help).
+		PreAction(func(*kingpin.ParseContext) error {
+			dr.ProviderName = r.name
+			return nil
+		}).
+		Action(p.SetupDeploymentResources)
+	p.Flags(cmd)
+
+	timeoutFlag(cmd.Command("info", fmt.Sprintf("%s info -v hashStable:COMMIT1 -v hashTesting:COMMIT2", r.name)).
+		Action(p.GetDeploymentVars), dr)
+
+	// Cluster operations.
+	c, isCluster := p.(ClusterProvider)
+	k, isKubeconfig := p.(KubeconfigProvider)
+	if isCluster || isKubeconfig {
+		k8sCluster := cmd.Command("cluster", fmt.Sprintf("manage %s clusters", r.name))
+		if isCluster {
+			timeoutFlag(k8sCluster.Command("create", fmt.Sprintf("%s cluster create -f FileOrFolder", r.name)).
+				Action(c.NewClient).
+				Action(c.DeploymentsParse).
+				Action(c.ClusterCreate), dr)
+			timeoutFlag(k8sCluster.Command("delete", fmt.Sprintf("%s cluster delete -f FileOrFolder", r.name)).
+				Action(c.NewClient).
+				Action(c.DeploymentsParse).
+				Action(c.ClusterDelete), dr)
+		}
+		if isKubeconfig {
+			// Only the client is needed, not the deployment files.
+			k8sKubeconfig := k8sCluster.Command("kubeconfig", fmt.Sprintf("%s cluster kubeconfig --out FILE. Writes a kubeconfig file to access the cluster with kubectl.", r.name))
+			out := k8sKubeconfig.Flag("out", "File to write the kubeconfig to, stdout when not set.").
+				PlaceHolder("FILE").
+				String()
+			k8sKubeconfig.Action(k.NewClient).
+				Action(func(*kingpin.ParseContext) error {
+					return writeKubeconfig(k, *out)
+				})
+			timeoutFlag(k8sKubeconfig, dr)
+		}
+	}
+
+	// Cluster node-pool operations.
+	if n, ok := p.(NodePoolProvider); ok {
+		k8sNodes := cmd.Command("nodes", fmt.Sprintf("manage %s cluster nodepools", r.name))
+		timeoutFlag(k8sNodes.Command("create", fmt.Sprintf("%s nodes create -f FileOrFolder", r.name)).
+			Action(n.NewClient).
+			Action(n.DeploymentsParse).
+			Action(n.NodesCreate), dr)
+		timeoutFlag(k8sNodes.Command("delete", fmt.Sprintf("%s nodes delete -f FileOrFolder", r.name)).
+			Action(n.NewClient).
+			Action(n.DeploymentsParse).
+			Action(n.NodesDelete), dr)
+		timeoutFlag(k8sNodes.Command("check-running", fmt.Sprintf("%s nodes check-running -f FileOrFolder", r.name)).
+			Action(n.NewClient).
+			Action(n.DeploymentsParse).
+			Action(n.AllNodesRunning), dr)
+		timeoutFlag(k8sNodes.Command("check-deleted", fmt.Sprintf("%s nodes check-deleted -f FileOrFolder", r.name)).
+			Action(n.NewClient).
+			Action(n.DeploymentsParse).
+			Action(n.AllNodesDeleted), dr)
+
+		if a, ok := p.(NodePoolApplier); ok {
+			k8sNodesApply := k8sNodes.Command("apply", fmt.Sprintf("%s nodes apply -f FileOrFolder. Creates the missing node pools and updates the changed ones, exits with status 3 with --dry-run when there are any changes.", r.name))
+			var opts NodesApplyOptions
+			k8sNodesApply.Flag("dry-run", "Only show the changes to the node pools.").
+				BoolVar(&opts.DryRun)
+			k8sNodesApply.Flag("recreate", "Delete and create again the node pools with changes that the provider api can't make in place, like the labels or the taints of a GKE node pool. Without it these changes are refused.").
+				BoolVar(&opts.Recreate)
+			k8sNodesApply.Action(a.NewClient).
+				Action(a.DeploymentsParse).
+				Action(func(*kingpin.ParseContext) error {
+					return a.NodesApply(opts)
+				})
+			timeoutFlag(k8sNodesApply, dr)
+		}
+
+		if nc, ok := p.(NodePoolCoster); ok {
+			// Only the deployment files are needed, not the provider credentials.
+			k8sNodesCost := k8sNodes.Command("cost", fmt.Sprintf("%s nodes cost -f FileOrFolder --prices FILE. Prints the hourly and projected cost of the node pools as a markdown table.", r.name))
+			pricesFile := k8sNodesCost.Flag("prices", "yaml file with the hourly price of the machine types by provider.").
+				Required().
+				PlaceHolder("FILE").
+				ExistingFile()
+			duration := k8sNodesCost.Flag("duration", "How long the node pools are expected to run for the projected cost.").
+				Default("24h").
+				Duration()
+			k8sNodesCost.Action(nc.DeploymentsParse).
+				Action(func(*kingpin.ParseContext) error {
+					prices, err := LoadPriceTable(*pricesFile)
+					if err != nil {
+						return err
+					}
+					pools, err := nc.NodePoolSpecs()
+					if err != nil {
+						return err
+					}
+					return WriteNodesCost(os.Stdout, r.name, pools, prices, *duration)
+				})
+		}
+	}
+
+	// Local image operations.
+	if l, ok := p.(ImageLoader); ok {
+		k8sImage := cmd.Command("image", fmt.Sprintf("manage the images of %s cluster nodes", r.name))
+		k8sImageLoad := k8sImage.Command("load", fmt.Sprintf("%s image load -v CLUSTER_NAME:prombench prom/prometheus:dev. Loads images from the local docker daemon into every cluster node.", r.name))
+		images := k8sImageLoad.Arg("image", "The images to load.").Required().Strings()
+		k8sImageLoad.Action(func(*kingpin.ParseContext) error {
+			return l.ImageLoad(*images)
+		})
+		timeoutFlag(k8sImageLoad, dr)
+	}
+
+	// Stale benchmark operations.
+	if g, ok := p.(GarbageCollector); ok {
+		var gcOpts GCOptions
+		// Only the client is needed, the objects are found by their names.
+		k8sGC := cmd.Command("gc", fmt.Sprintf("%s gc --older-than 72h. Deletes the prometheus-<PR> and nodes-<PR> node pools and the prombench-<PR> namespaces older than the given age, exits with status 3 with --dry-run when there are any. Node pools and namespaces whose age is unknown are kept.", r.name)).
+			Action(g.NewClient).
+			Action(func(*kingpin.ParseContext) error {
+				return g.GC(gcOpts)
+			})
+		k8sGC.Flag("older-than", "Age after which the benchmark node pools and namespaces are deleted.").
+			Required().
+			DurationVar(&gcOpts.OlderThan)
+		k8sGC.Flag("open-pr", "Number of a pull request that is still open, its benchmark is kept whatever its age. Can be repeated or a comma separated list.").
+			PlaceHolder("PR").
+			StringsVar(&gcOpts.OpenPRs)
+		k8sGC.Flag("dry-run", "Only show the node pools and namespaces that would be deleted.").
+			BoolVar(&gcOpts.DryRun)
+		timeoutFlag(k8sGC, dr)
+	}
+
+	// K8s resource operations.
+	if res, ok := p.(ResourceProvider); ok {
+		k8sResource := cmd.Command("resource", "Apply and delete different k8s resources - deployments, services, config maps etc.").
+			Action(res.NewClient).
+			Action(res.K8SDeploymentsParse).
+			Action(res.NewK8sProvider)
+		var applyOpts ApplyOptions
+		k8sApply := k8sResource.Command("apply", fmt.Sprintf("%s resource apply -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2", r.name)).
+			Action(func(*kingpin.ParseContext) error {
+				return res.ResourceApply(applyOpts)
+			})
+		timeoutFlag(k8sApply, dr)
+		k8sApply.Flag("prune", "Delete the objects in the namespaces of the manifest files that were applied before from the same files but are no longer in them. Cluster scoped objects, like ClusterRoles, are never pruned.").
+			BoolVar(&applyOpts.Prune)
+		k8sApply.Flag("artifacts-dir", "When applying fails write a tarball with the pod logs, events, failing objects and node conditions to this folder.").
+			StringVar(&applyOpts.ArtifactsDir)
+		var deleteOpts DeleteOptions
+		k8sDelete := k8sResource.Command("delete", fmt.Sprintf("%s resource delete -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2", r.name)).
+			Action(func(*kingpin.ParseContext) error {
+				return res.ResourceDelete(deleteOpts)
+			})
+		k8sDelete.Flag("propagation-policy", "How the dependents of the deleted objects are deleted, the deletion waits for them with foreground.").
+			Default("foreground").
+			EnumVar(&deleteOpts.PropagationPolicy, "foreground", "background", "orphan")
+		timeoutFlag(k8sDelete, dr)
+		k8sDelete.Flag("wait-timeout", "How long to wait for all deleted objects to be gone before reporting the ones that are stuck.").
+			Default("15m").
+			DurationVar(&deleteOpts.WaitTimeout)
+		k8sPlan := k8sResource.Command("plan", fmt.Sprintf("%s resource plan -f manifestsFileOrFolder -v hashStable:COMMIT1 -v hashTesting:COMMIT2. Shows the changes that would be made to the cluster and exits with status 3 when there are any.", r.name))
+		planDelete := k8sPlan.Flag("delete", "Plan the deletion of the resources instead of applying them.").Bool()
+		timeoutFlag(k8sPlan, dr)
+		k8sPlan.Action(func(*kingpin.ParseContext) error {
+			return res.ResourcePlan(*planDelete)
+		})
+	}
+}
+
+// writeKubeconfig writes the kubeconfig of the provider to the file or to stdout when it is empty.
+// The file is only readable by the owner as it contains credentials.
+func writeKubeconfig(k KubeconfigProvider, out string) error {
+	kubeconfig, err := k.Kubeconfig()
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(kubeconfig)
+		return err
+	}
+	if err := ioutil.WriteFile(out, kubeconfig, 0600); err != nil {
+		return fmt.Errorf("writing the kubeconfig file: %v", err)
+	}
+	log.Printf("kubeconfig written to %v", out)
+	return nil
+}
@@ -0,0 +1,288 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// fakeProvider records the actions called by the cli.
+type fakeProvider struct {
+	auth  string
+	calls []string
+}
+
+func (p *fakeProvider) record(name string) kingpin.Action {
+	return func(*kingpin.ParseContext) error {
+		p.calls = append(p.calls, name)
+		return nil
+	}
+}
+
+func (p *fakeProvider) Flags(cmd *kingpin.CmdClause) {
+	cmd.Flag("auth", "").Short('a').StringVar(&p.auth)
+}
+func (p *fakeProvider) SetupDeploymentResources(c *kingpin.ParseContext) error {
+	return p.record("setup")(c)
+}
+func (p *fakeProvider) GetDeploymentVars(c *kingpin.ParseContext) error { return p.record("info")(c) }
+func (p *fakeProvider) NewClient(c *kingpin.ParseContext) error         { return p.record("client")(c) }
+func (p *fakeProvider) DeploymentsParse(c *kingpin.ParseContext) error  { return p.record("parse")(c) }
+
+// fakeClusterProvider only implements the cluster lifecycle.
+type fakeClusterProvider struct {
+	fakeProvider
+}
+
+func (p *fakeClusterProvider) ClusterCreate(c *kingpin.ParseContext) error {
+	return p.record("cluster create")(c)
+}
+func (p *fakeClusterProvider) ClusterDelete(c *kingpin.ParseContext) error {
+	return p.record("cluster delete")(c)
+}
+
+func commands(app *kingpin.Application) []string {
+	var cmds []string
+	for _, c := range app.Model().FlattenedCommands() {
+		cmds = append(cmds, c.FullCommand)
+	}
+	sort.Strings(cmds)
+	return cmds
+}
+
+func TestRegisterCommands(t *testing.T) {
+	p := &fakeClusterProvider{}
+	app := kingpin.New("test", "")
+	dr := NewDeploymentResource()
+	addProviderCommands(app, registration{name: "fake"}, p, dr)
+
+	expected := []string{"fake cluster create", "fake cluster delete", "fake info"}
+	if cmds := commands(app); !reflect.DeepEqual(expected, cmds) {
+		t.Fatalf("\nexpect commands %v\ngot %v", expected, cmds)
+	}
+
+	if _, err := app.Parse([]string{"fake", "-a", "secret", "cluster", "create", "--timeout", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.auth != "secret" {
+		t.Errorf("expect provider flag to be set, got %q", p.auth)
+	}
+	expected = []string{"setup", "client", "parse", "cluster create"}
+	if !reflect.DeepEqual(expected, p.calls) {
+		t.Errorf("\nexpect actions %v\ngot %v", expected, p.calls)
+	}
+	if _, ok := dr.Context().Deadline(); !ok {
+		t.Error("expect the timeout flag to set a deadline")
+	}
+	dr.Cancel()
+	if err := dr.Context().Err(); err != context.Canceled {
+		t.Errorf("expect Cancel to cancel the context of the command, got %v", err)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	f := func(*DeploymentResource) Provider { return &fakeProvider{} }
+	Register("duplicate", "", f)
+	defer func() {
+		registryMtx.Lock()
+		delete(registry, "duplicate")
+		registryMtx.Unlock()
+		if recover() == nil {
+			t.Error("expected a panic when registering a provider twice")
+		}
+	}()
+	Register("duplicate", "", f)
+}
+
+// fakeImageLoader records the loaded images.
+type fakeImageLoader struct {
+	fakeProvider
+	images []string
+}
+
+func (p *fakeImageLoader) ImageLoad(images []string) error {
+	p.images = images
+	return nil
+}
+
+func TestRegisterImageCommands(t *testing.T) {
+	p := &fakeImageLoader{}
+	app := kingpin.New("test", "")
+	addProviderCommands(app, registration{name: "fake"}, p, NewDeploymentResource())
+
+	expected := []string{"fake image load", "fake info"}
+	if cmds := commands(app); !reflect.DeepEqual(expected, cmds) {
+		t.Fatalf("\nexpect commands %v\ngot %v", expected, cmds)
+	}
+
+	if _, err := app.Parse([]string{"fake", "image", "load", "prom/prometheus:dev", "prom/alertmanager:dev"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"prom/prometheus:dev", "prom/alertmanager:dev"}
+	if !reflect.DeepEqual(expected, p.images) {
+		t.Errorf("\nexpect images %v\ngot %v", expected, p.images)
+	}
+}
+
+// fakeKubeconfigProvider returns a static kubeconfig.
+type fakeKubeconfigProvider struct {
+	fakeProvider
+}
+
+func (p *fakeKubeconfigProvider) Kubeconfig() ([]byte, error) {
+	return []byte("apiVersion: v1\nkind: Config\n"), nil
+}
+
+func TestRegisterKubeconfigCommand(t *testing.T) {
+	p := &fakeKubeconfigProvider{}
+	app := kingpin.New("test", "")
+	addProviderCommands(app, registration{name: "fake"}, p, NewDeploymentResource())
+
+	expected := []string{"fake cluster kubeconfig", "fake info"}
+	if cmds := commands(app); !reflect.DeepEqual(expected, cmds) {
+		t.Fatalf("\nexpect commands %v\ngot %v", expected, cmds)
+	}
+
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "kubeconfig")
+
+	if _, err := app.Parse([]string{"fake", "cluster", "kubeconfig", "--out", out}); err != nil {
+		t.Fatal(err)
+	}
+	// The deployment files are not parsed as they aren't needed.
+	expected = []string{"setup", "client"}
+	if !reflect.DeepEqual(expected, p.calls) {
+		t.Errorf("\nexpect actions %v\ngot %v", expected, p.calls)
+	}
+	content, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "apiVersion: v1\nkind: Config\n" {
+		t.Errorf("unexpected kubeconfig content: %q", content)
+	}
+}
+
+// fakeGarbageCollector records the gc options.
+type fakeGarbageCollector struct {
+	fakeProvider
+	opts GCOptions
+}
+
+func (p *fakeGarbageCollector) GC(opts GCOptions) error {
+	p.opts = opts
+	return nil
+}
+
+func TestRegisterGCCommand(t *testing.T) {
+	p := &fakeGarbageCollector{}
+	app := kingpin.New("test", "")
+	addProviderCommands(app, registration{name: "fake"}, p, NewDeploymentResource())
+
+	expected := []string{"fake gc", "fake info"}
+	if cmds := commands(app); !reflect.DeepEqual(expected, cmds) {
+		t.Fatalf("\nexpect commands %v\ngot %v", expected, cmds)
+	}
+
+	if _, err := app.Parse([]string{"fake", "gc", "--older-than", "72h", "--open-pr", "1234", "--open-pr", "1235", "--dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	expectedOpts := GCOptions{OlderThan: 72 * time.Hour, OpenPRs: []string{"1234", "1235"}, DryRun: true}
+	if !reflect.DeepEqual(expectedOpts, p.opts) {
+		t.Errorf("\nexpect options %+v\ngot %+v", expectedOpts, p.opts)
+	}
+	// The deployment files are not parsed as the objects are found by their names.
+	expectedCalls := []string{"setup", "client"}
+	if !reflect.DeepEqual(expectedCalls, p.calls) {
+		t.Errorf("\nexpect actions %v\ngot %v", expectedCalls, p.calls)
+	}
+
+	if _, err := app.Parse([]string{"fake", "gc"}); err == nil {
+		t.Error("expected an error without --older-than")
+	}
+}
+
+// fakeNodePoolCoster only implements the node pool lifecycle and returns static node pools.
+type fakeNodePoolCoster struct {
+	fakeProvider
+}
+
+func (p *fakeNodePoolCoster) NodesCreate(c *kingpin.ParseContext) error {
+	return p.record("nodes create")(c)
+}
+func (p *fakeNodePoolCoster) NodesDelete(c *kingpin.ParseContext) error {
+	return p.record("nodes delete")(c)
+}
+func (p *fakeNodePoolCoster) AllNodesRunning(c *kingpin.ParseContext) error {
+	return p.record("nodes check-running")(c)
+}
+func (p *fakeNodePoolCoster) AllNodesDeleted(c *kingpin.ParseContext) error {
+	return p.record("nodes check-deleted")(c)
+}
+func (p *fakeNodePoolCoster) NodePoolSpecs() ([]NodePoolSpec, error) {
+	p.calls = append(p.calls, "specs")
+	return []NodePoolSpec{{Name: "nodes-1", MachineType: "c5.4xlarge", Nodes: 1}}, nil
+}
+
+func TestRegisterNodesCostCommand(t *testing.T) {
+	p := &fakeNodePoolCoster{}
+	app := kingpin.New("test", "")
+	addProviderCommands(app, registration{name: "fake"}, p, NewDeploymentResource())
+
+	expected := []string{"fake info", "fake nodes check-deleted", "fake nodes check-running", "fake nodes cost", "fake nodes create", "fake nodes delete"}
+	if cmds := commands(app); !reflect.DeepEqual(expected, cmds) {
+		t.Fatalf("\nexpect commands %v\ngot %v", expected, cmds)
+	}
+
+	dir, err := ioutil.TempDir("", "prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prices := filepath.Join(dir, "prices.yaml")
+	if err := ioutil.WriteFile(prices, []byte("version: \"2020-10-01\"\ncurrency: USD\nprices:\n  fake:\n    c5.4xlarge: 0.68\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.Parse([]string{"fake", "nodes", "cost", "--prices", prices}); err != nil {
+		t.Fatal(err)
+	}
+	// The provider client is not needed.
+	expected = []string{"setup", "parse", "specs"}
+	if !reflect.DeepEqual(expected, p.calls) {
+		t.Errorf("\nexpect actions %v\ngot %v", expected, p.calls)
+	}
+
+	p.calls = nil
+	if _, err := app.Parse([]string{"fake", "nodes", "create"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"setup", "client", "parse", "nodes create"}
+	if !reflect.DeepEqual(expected, p.calls) {
+		t.Errorf("\nexpect actions %v\ngot %v", expected, p.calls)
+	}
+}
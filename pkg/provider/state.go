@@ -0,0 +1,212 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The kinds of the objects recorded in the state file.
+const (
+	StateCluster   = "cluster"
+	StateNodePool  = "nodepool"
+	StateNamespace = "namespace"
+)
+
+// StateEntry is an object created by the infra cli.
+type StateEntry struct {
+	// Provider is the name of the provider that created the object.
+	Provider string `json:"provider"`
+	// Kind is one of StateCluster, StateNodePool or StateNamespace.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Cluster is the cluster of a node pool or a namespace.
+	Cluster string `json:"cluster,omitempty"`
+	// Settings are the provider specific values needed to find the object again which aren't deployment vars,
+	// like the GKE project and zone from the cluster file or the kubeconfig file of the cluster.
+	Settings map[string]string `json:"settings,omitempty"`
+	// Vars are the deployment vars used when creating the object, without the ones that look like secrets.
+	Vars    map[string]string `json:"vars,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+func (e StateEntry) String() string {
+	if e.Cluster != "" {
+		return fmt.Sprintf("%v %v %v/%v", e.Provider, e.Kind, e.Cluster, e.Name)
+	}
+	return fmt.Sprintf("%v %v %v", e.Provider, e.Kind, e.Name)
+}
+
+// same returns whether both entries are for the same object.
+func (e StateEntry) same(o StateEntry) bool {
+	return e.Provider == o.Provider && e.Kind == o.Kind && e.Name == o.Name && e.Cluster == o.Cluster
+}
+
+// State holds the objects created by the infra cli in the order of their creation.
+type State struct {
+	Entries []StateEntry `json:"entries"`
+}
+
+// LoadState reads the state file. A file that doesn't exist yet is an empty state.
+func LoadState(path string) (*State, error) {
+	s := &State{}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the state file")
+	}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, errors.Wrapf(err, "parsing the state file %v", path)
+	}
+	return s, nil
+}
+
+// Save writes the state file. The file is replaced at once so it is never left half written.
+func (s *State) Save(path string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "writing the state file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "writing the state file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "writing the state file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "writing the state file")
+}
+
+// Add records the object. An object that is already recorded keeps its place
+// so it is still destroyed before everything that was created before it.
+func (s *State) Add(e StateEntry) {
+	for i := range s.Entries {
+		if s.Entries[i].same(e) {
+			s.Entries[i] = e
+			return
+		}
+	}
+	s.Entries = append(s.Entries, e)
+}
+
+// Remove removes the object, and everything in it when it is a cluster.
+func (s *State) Remove(e StateEntry) {
+	entries := s.Entries[:0]
+	for _, o := range s.Entries {
+		if o.same(e) || e.Kind == StateCluster && o.Provider == e.Provider && o.Cluster == e.Name {
+			continue
+		}
+		entries = append(entries, o)
+	}
+	s.Entries = entries
+}
+
+// Record adds the object to the state file when one is set.
+func (d *DeploymentResource) Record(e StateEntry) error {
+	if d.StateFile == "" {
+		return nil
+	}
+	e.Provider = d.ProviderName
+	e.Created = time.Now().UTC()
+	return d.updateState(func(s *State) { s.Add(e) })
+}
+
+// Forget removes the deleted object from the state file when one is set.
+func (d *DeploymentResource) Forget(e StateEntry) error {
+	if d.StateFile == "" {
+		return nil
+	}
+	e.Provider = d.ProviderName
+	return d.updateState(func(s *State) { s.Remove(e) })
+}
+
+func (d *DeploymentResource) updateState(update func(*State)) error {
+	s, err := LoadState(d.StateFile)
+	if err != nil {
+		return err
+	}
+	update(s)
+	return s.Save(d.StateFile)
+}
+
+// Destroy deletes the objects recorded in the state file of dr in the reverse order of their creation.
+// Every object is deleted by its provider using the recorded deployment vars, the deployment files aren't needed.
+// Deleted objects are removed from the state file right away so an interrupted destroy can be run again.
+func Destroy(dr *DeploymentResource) error {
+	if dr.StateFile == "" {
+		return fmt.Errorf("missing the state file, set the state flag")
+	}
+	state, err := LoadState(dr.StateFile)
+	if err != nil {
+		return err
+	}
+	for len(state.Entries) > 0 {
+		e := state.Entries[len(state.Entries)-1]
+		if err := destroyEntry(dr, e); err != nil {
+			return errors.Wrapf(err, "destroying %v", e)
+		}
+		state.Remove(e)
+		if err := state.Save(dr.StateFile); err != nil {
+			return err
+		}
+	}
+	log.Printf("Everything in the state file %v is destroyed", dr.StateFile)
+	return nil
+}
+
+func destroyEntry(dr *DeploymentResource, e StateEntry) error {
+	registryMtx.Lock()
+	r, ok := registry[e.Provider]
+	registryMtx.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown provider %q", e.Provider)
+	}
+
+	// The provider gets the recorded vars and no state file so it doesn't change the state itself.
+	edr := NewDeploymentResource()
+	edr.ProviderName = e.Provider
+	for k, v := range e.Vars {
+		edr.FlagDeploymentVars[k] = v
+	}
+	edr.SetContext(dr.Context())
+
+	p := r.new(edr)
+	d, ok := p.(Destroyer)
+	if !ok {
+		return fmt.Errorf("provider %v can't destroy objects from the state file", e.Provider)
+	}
+	log.Printf("Destroying %v", e)
+	if err := p.SetupDeploymentResources(nil); err != nil {
+		return err
+	}
+	if err := p.NewClient(nil); err != nil {
+		return err
+	}
+	return d.Destroy(e)
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dr := NewDeploymentResource()
+	dr.ProviderName = "gke"
+
+	// Nothing is recorded without a state file.
+	if err := dr.Record(StateEntry{Kind: StateCluster, Name: "prombench"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dr.StateFile = filepath.Join(dir, "state.json")
+	for _, e := range []StateEntry{
+		{Kind: StateCluster, Name: "prombench", Vars: map[string]string{"CLUSTER_NAME": "prombench"}},
+		{Kind: StateNodePool, Name: "main-node", Cluster: "prombench"},
+		{Kind: StateNamespace, Name: "prombench-1", Cluster: "prombench"},
+		{Kind: StateCluster, Name: "other"},
+		// Recording an object again keeps its place.
+		{Kind: StateNodePool, Name: "main-node", Cluster: "prombench"},
+	} {
+		if err := dr.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dr.Forget(StateEntry{Kind: StateNamespace, Name: "prombench-1", Cluster: "prombench"}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadState(dr.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"gke cluster prombench", "gke nodepool prombench/main-node", "gke cluster other"}
+	if got := stateEntries(state); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("\nexpect entries %v\ngot %v", expected, got)
+	}
+	if state.Entries[0].Vars["CLUSTER_NAME"] != "prombench" || state.Entries[0].Created.IsZero() {
+		t.Errorf("expect the vars and the creation time to be recorded, got %+v", state.Entries[0])
+	}
+
+	// Deleting a cluster removes its node pools and namespaces as well.
+	if err := dr.Forget(StateEntry{Kind: StateCluster, Name: "prombench"}); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = LoadState(dr.StateFile); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"gke cluster other"}
+	if got := stateEntries(state); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("\nexpect entries %v\ngot %v", expected, got)
+	}
+}
+
+// fakeDestroyer records the destroyed objects with the vars it was created with.
+type fakeDestroyer struct {
+	fakeProvider
+	dr        *DeploymentResource
+	destroyed *[]string
+}
+
+func (p *fakeDestroyer) Destroy(e StateEntry) error {
+	*p.destroyed = append(*p.destroyed, e.String()+" "+p.dr.FlagDeploymentVars["CLUSTER_NAME"])
+	return nil
+}
+
+func TestDestroy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var destroyed []string
+	Register("fake", "", func(dr *DeploymentResource) Provider {
+		return &fakeDestroyer{dr: dr, destroyed: &destroyed}
+	})
+	defer func() {
+		registryMtx.Lock()
+		delete(registry, "fake")
+		registryMtx.Unlock()
+	}()
+
+	dr := NewDeploymentResource()
+	dr.ProviderName = "fake"
+	dr.StateFile = filepath.Join(dir, "state.json")
+	vars := map[string]string{"CLUSTER_NAME": "prombench"}
+	for _, e := range []StateEntry{
+		{Kind: StateCluster, Name: "prombench", Vars: vars},
+		{Kind: StateNodePool, Name: "main-node", Cluster: "prombench", Vars: vars},
+		{Kind: StateNamespace, Name: "prombench-1", Cluster: "prombench", Vars: vars},
+	} {
+		if err := dr.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Destroy(dr); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"fake namespace prombench/prombench-1 prombench",
+		"fake nodepool prombench/main-node prombench",
+		"fake cluster prombench prombench",
+	}
+	if !reflect.DeepEqual(expected, destroyed) {
+		t.Errorf("\nexpect destroyed %v\ngot %v", expected, destroyed)
+	}
+
+	state, err := LoadState(dr.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Entries) != 0 {
+		t.Errorf("expect an empty state file, got %v", stateEntries(state))
+	}
+}
+
+func stateEntries(s *State) []string {
+	var entries []string
+	for _, e := range s.Entries {
+		entries = append(entries, e.String())
+	}
+	return entries
+}
@@ -0,0 +1,289 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	yamlGo "gopkg.in/yaml.v2"
+)
+
+// templateFuncs are the functions available in the deployment files.
+// The argument order follows the Helm(sprig) functions with the same name
+// so that the piped value is always the last argument - {{ .RELEASE | default "master" }}.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// k8s objects can't have dots(.) se we add a custom function to allow normalising the variable values.
+		"normalise": func(t string) string {
+			return strings.Replace(t, ".", "-", -1)
+		},
+		"split": func(rangeVars, separator string) []string {
+			return strings.Split(rangeVars, separator)
+		},
+		// default returns def when the value is empty or the variable is not set.
+		"default": func(def string, v interface{}) interface{} {
+			if isEmpty(v) {
+				return def
+			}
+			return v
+		},
+		// required fails the parsing with the given message when the value is empty.
+		"required": func(msg string, v interface{}) (interface{}, error) {
+			if isEmpty(v) {
+				return nil, errors.New(msg)
+			}
+			return v, nil
+		},
+		"toYaml": func(v interface{}) (string, error) {
+			out, err := yamlGo.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(string(out), "\n"), nil
+		},
+		"indent": indent,
+		"nindent": func(spaces int, s string) string {
+			return "\n" + indent(spaces, s)
+		},
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"b64dec": func(s string) (string, error) {
+			out, err := base64.StdEncoding.DecodeString(s)
+			return string(out), err
+		},
+		"sha256": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+		"replace": func(old, new, s string) string {
+			return strings.Replace(s, old, new, -1)
+		},
+		"quote": func(s string) string {
+			return fmt.Sprintf("%q", s)
+		},
+	}
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// MissingVarsError lists the variables used in the deployment files that are not set, grouped by the filename.
+type MissingVarsError map[string][]string
+
+func (e MissingVarsError) Error() string {
+	files := make([]string, 0, len(e))
+	for f := range e {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	var b strings.Builder
+	b.WriteString("missing deployment variables:")
+	for _, f := range files {
+		fmt.Fprintf(&b, "\n  %v: %v", f, strings.Join(e[f], ", "))
+	}
+	return b.String()
+}
+
+// missingVars returns the variables used in the template that are not in vars.
+// Optional variables aren't reported, see templateVars.
+func missingVars(t *template.Template, vars map[string]string) []string {
+	required, _ := templateVars(t)
+	var missing []string
+	for name := range required {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// executionVars returns vars with the optional variables that are not set added as empty values.
+// All other variables that are not set fail the execution of the template.
+func executionVars(t *template.Template, vars map[string]string) map[string]string {
+	required, optional := templateVars(t)
+	out := make(map[string]string, len(vars)+len(optional))
+	for k, v := range vars {
+		out[k] = v
+	}
+	for name := range optional {
+		if _, ok := out[name]; !ok && !required[name] {
+			out[name] = ""
+		}
+	}
+	return out
+}
+
+// templateVars returns the variables that the template reads from the root data.
+// A variable is optional when it is the whole condition of an if or with block, like {{ if .DEBUG }},
+// or the value piped to default. Every other use makes it required.
+// The templates called with the template action are followed as well.
+func templateVars(t *template.Template) (required, optional map[string]bool) {
+	w := &varWalker{
+		t:        t,
+		required: map[string]bool{},
+		optional: map[string]bool{},
+		visited:  map[string]bool{},
+	}
+	if t.Tree != nil {
+		w.visited[t.Name()] = true
+		w.node(t.Tree.Root, true, true)
+	}
+	return w.required, w.optional
+}
+
+// varWalker collects the variables read from the root data.
+// dot and dollar tell whether . and $ are the root data in the walked node.
+type varWalker struct {
+	t        *template.Template
+	required map[string]bool
+	optional map[string]bool
+	visited  map[string]bool
+}
+
+func (w *varWalker) found(name string, optional bool) {
+	if optional {
+		w.optional[name] = true
+		return
+	}
+	w.required[name] = true
+}
+
+func (w *varWalker) node(node parse.Node, dot, dollar bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			w.node(c, dot, dollar)
+		}
+	case *parse.ActionNode:
+		w.pipe(n.Pipe, dot, dollar)
+	case *parse.IfNode:
+		w.condition(n.Pipe, dot, dollar)
+		w.node(n.List, dot, dollar)
+		w.node(n.ElseList, dot, dollar)
+	case *parse.WithNode:
+		w.condition(n.Pipe, dot, dollar)
+		w.node(n.List, false, dollar)
+		w.node(n.ElseList, dot, dollar)
+	case *parse.RangeNode:
+		w.pipe(n.Pipe, dot, dollar)
+		w.node(n.List, false, dollar)
+		w.node(n.ElseList, dot, dollar)
+	case *parse.TemplateNode:
+		w.pipe(n.Pipe, dot, dollar)
+		// The called template reads the root data only when it is called with the root data, {{ template "name" . }}.
+		root := n.Pipe != nil && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 && isRoot(n.Pipe.Cmds[0].Args[0], dot, dollar)
+		if called := w.t.Lookup(n.Name); called != nil && called.Tree != nil && root && !w.visited[n.Name] {
+			w.visited[n.Name] = true
+			w.node(called.Tree.Root, true, true)
+		}
+	}
+}
+
+// condition walks the pipe of an if or with block, a single variable is optional.
+func (w *varWalker) condition(pipe *parse.PipeNode, dot, dollar bool) {
+	if pipe != nil && len(pipe.Decl) == 0 && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		w.arg(pipe.Cmds[0].Args[0], dot, dollar, true)
+		return
+	}
+	w.pipe(pipe, dot, dollar)
+}
+
+func (w *varWalker) pipe(pipe *parse.PipeNode, dot, dollar bool) {
+	if pipe == nil {
+		return
+	}
+	for i, cmd := range pipe.Cmds {
+		// {{ index . "NAME" }}
+		if len(cmd.Args) == 3 && isIdent(cmd.Args[0], "index") && isRoot(cmd.Args[1], dot, dollar) {
+			if s, ok := cmd.Args[2].(*parse.StringNode); ok {
+				w.found(s.Text, false)
+				continue
+			}
+		}
+		// The value piped to default, {{ .NAME | default "x" }} or {{ default "x" .NAME }}.
+		pipedToDefault := len(cmd.Args) == 1 && i+1 < len(pipe.Cmds) && isIdent(pipe.Cmds[i+1].Args[0], "default")
+		for j, arg := range cmd.Args {
+			optional := pipedToDefault || isIdent(cmd.Args[0], "default") && j == 2
+			w.arg(arg, dot, dollar, optional)
+		}
+	}
+}
+
+func (w *varWalker) arg(arg parse.Node, dot, dollar, optional bool) {
+	switch a := arg.(type) {
+	case *parse.FieldNode:
+		if dot {
+			w.found(a.Ident[0], optional)
+		}
+	case *parse.VariableNode:
+		if a.Ident[0] == "$" && len(a.Ident) > 1 && dollar {
+			w.found(a.Ident[1], optional)
+		}
+	case *parse.ChainNode:
+		// {{ (.NAME).field }}
+		w.arg(a.Node, dot, dollar, false)
+	case *parse.PipeNode:
+		w.pipe(a, dot, dollar)
+	}
+}
+
+func isIdent(node parse.Node, name string) bool {
+	id, ok := node.(*parse.IdentifierNode)
+	return ok && id.Ident == name
+}
+
+// isRoot returns whether the node is the root data, . or $.
+func isRoot(node parse.Node, dot, dollar bool) bool {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.VariableNode:
+		return len(n.Ident) == 1 && n.Ident[0] == "$" && dollar
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	vars := map[string]string{
+		"RELEASE":    "v2.20.0",
+		"PR_NUMBER":  "123",
+		"SUBNET_IDS": "a,b",
+		"EMPTY":      "",
+	}
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{template: `{{ normalise .RELEASE }}`, expected: "v2-20-0"},
+		{template: `{{ .MISSING | default "master" }}`, expected: "master"},
+		{template: `{{ .EMPTY | default "master" }}`, expected: "master"},
+		{template: `{{ .RELEASE | default "master" }}`, expected: "v2.20.0"},
+		{template: `{{ required "PR_NUMBER must be set" .PR_NUMBER }}`, expected: "123"},
+		{template: `ids:{{ split .SUBNET_IDS "," | toYaml | nindent 2 }}`, expected: "ids:\n  - a\n  - b"},
+		{template: `{{ .PR_NUMBER | b64enc }}`, expected: "MTIz"},
+		{template: `{{ "MTIz" | b64dec }}`, expected: "123"},
+		{template: `{{ .PR_NUMBER | sha256 }}`, expected: "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3"},
+		{template: `{{ "PromBench" | lower }}`, expected: "prombench"},
+		{template: `{{ .RELEASE | replace "." "_" | upper | quote }}`, expected: `"V2_20_0"`},
+		{template: `{{ if .MISSING }}set{{ else }}unset{{ end }}`, expected: "unset"},
+	}
+
+	for _, tc := range testCases {
+		tmpl, err := parseTemplate([]byte(tc.template))
+		if err != nil {
+			t.Fatalf("%v: %v", tc.template, err)
+		}
+		out, err := applyTemplateVars(tmpl, vars)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.template, err)
+		}
+		if string(out) != tc.expected {
+			t.Errorf("%v: expect %q, got %q", tc.template, tc.expected, out)
+		}
+	}
+
+	tmpl, err := parseTemplate([]byte(`{{ required "EMPTY must be set" .EMPTY }}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyTemplateVars(tmpl, vars); err == nil {
+		t.Error("expected an error from required for an empty value")
+	}
+}
+
+func TestMissingVars(t *testing.T) {
+	tmpl, err := parseTemplate([]byte(`
+name: prometheus-{{ .PR_NUMBER }}
+image: {{ .IMAGE | default "prom/prometheus" }}
+{{ if .DEBUG }}debug: true{{ end }}
+{{ range $id := split .SUBNET_IDS .SEPARATOR }}- {{ $id }}-{{ $.ZONE }}{{ end }}
+{{ with .LABELS }}labels: {{ .name }}{{ end }}
+domain: {{ index . "DOMAIN_NAME" }}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"DOMAIN_NAME", "PR_NUMBER", "SUBNET_IDS", "ZONE"}
+	if missing := missingVars(tmpl, map[string]string{"SEPARATOR": ","}); !reflect.DeepEqual(expected, missing) {
+		t.Errorf("expect %v, got %v", expected, missing)
+	}
+
+	for content, expected := range map[string][]string{
+		`{{ if eq .RELEASE "master" }}latest{{ end }}`:                             {"RELEASE"},
+		`{{ with .LABELS | upper }}{{ . }}{{ end }}`:                               {"LABELS"},
+		`{{ define "image" }}{{ .IMAGE }}{{ end }}image: {{ template "image" . }}`: {"IMAGE"},
+		`{{ block "zone" $ }}{{ .ZONE }}{{ end }}`:                                 {"ZONE"},
+		`{{ (.RELEASE).Len }}`:                                                     {"RELEASE"},
+		`{{ default "1" .REPLICAS }}{{ if .DEBUG }}{{ .DEBUG }}{{ end }}`:          {"DEBUG"},
+	} {
+		tmpl, err := parseTemplate([]byte(content))
+		if err != nil {
+			t.Fatalf("%v: %v", content, err)
+		}
+		if missing := missingVars(tmpl, map[string]string{}); !reflect.DeepEqual(expected, missing) {
+			t.Errorf("%v: expect %v, got %v", content, expected, missing)
+		}
+	}
+}
+
+func TestApplyTemplateVarsFailsOnMissingVars(t *testing.T) {
+	// The variable read through $root isn't seen by missingVars, executing the template still fails.
+	tmpl, err := parseTemplate([]byte(`{{ with $root := . }}{{ $root.RELEASE }}{{ end }}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"PR_NUMBER": "123"}
+	if missing := missingVars(tmpl, vars); len(missing) != 0 {
+		t.Fatalf("expect no missing vars, got %v", missing)
+	}
+	if _, err := applyTemplateVars(tmpl, vars); err == nil {
+		t.Error("expected an error for the missing RELEASE variable")
+	}
+}
+
+func TestDeploymentsParseMissingVars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"1_namespace.yaml":          "name: prombench-{{ .PR_NUMBER }}",
+		"2_prometheus.yaml":         "image: {{ .RELEASE }}\nzone: {{ .ZONE }}",
+		"3_dashboards_noparse.yaml": "legend: {{ .MISSING }}",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = DeploymentsParse([]string{dir}, map[string]string{"RELEASE": "master"})
+	expected := MissingVarsError{
+		filepath.Join(dir, "1_namespace.yaml"):  {"PR_NUMBER"},
+		filepath.Join(dir, "2_prometheus.yaml"): {"ZONE"},
+	}
+	if !reflect.DeepEqual(expected, err) {
+		t.Errorf("expect %v, got %v", expected, err)
+	}
+}
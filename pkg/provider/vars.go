@@ -0,0 +1,160 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yamlGo "gopkg.in/yaml.v2"
+)
+
+// The names of the deployment vars sources that are not files.
+const (
+	SourceDefault  = "default"
+	SourceProvider = "provider"
+	SourceFlag     = "flag"
+)
+
+// VarsSource holds deployment vars and where they were loaded from.
+type VarsSource struct {
+	Name string
+	Vars map[string]string
+}
+
+// LoadVarsFiles reads the VarsFiles into FileDeploymentVars.
+// Files with a .yaml or .yml extension must hold a map of scalar values,
+// all other files are read as dotenv files with a KEY=VALUE pair on every line.
+func (d *DeploymentResource) LoadVarsFiles() error {
+	d.FileDeploymentVars = nil
+	for _, name := range d.VarsFiles {
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("error reading vars file %v:%v", name, err)
+		}
+
+		var vars map[string]string
+		switch filepath.Ext(name) {
+		case ".yaml", ".yml":
+			vars, err = parseYAMLVars(content)
+		default:
+			vars, err = parseDotenvVars(content)
+		}
+		if err != nil {
+			return fmt.Errorf("error parsing vars file %v:%v", name, err)
+		}
+		d.FileDeploymentVars = append(d.FileDeploymentVars, VarsSource{Name: name, Vars: vars})
+	}
+	return nil
+}
+
+// VarsSources returns all deployment vars sources in the order of precedence,
+// each one overrides the ones before it:
+// the defaults, the provider custom vars, the vars files in the order given and the cli flags.
+func (d *DeploymentResource) VarsSources(customVars map[string]string) []VarsSource {
+	sources := []VarsSource{
+		{Name: SourceDefault, Vars: d.DefaultDeploymentVars},
+		{Name: SourceProvider, Vars: customVars},
+	}
+	sources = append(sources, d.FileDeploymentVars...)
+	return append(sources, VarsSource{Name: SourceFlag, Vars: d.FlagDeploymentVars})
+}
+
+// MergeVarsSources merges the sources based on the order and
+// returns the final vars together with the name of the source every value came from.
+func MergeVarsSources(sources []VarsSource) (vars, origin map[string]string) {
+	vars = map[string]string{}
+	origin = map[string]string{}
+	for _, s := range sources {
+		for k, v := range s.Vars {
+			vars[k] = v
+			origin[k] = s.Name
+		}
+	}
+	return vars, origin
+}
+
+// secretVarKeywords are the words of a deployment var name that mark its value as secret.
+var secretVarKeywords = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "CREDENTIAL", "PRIVATE", "AUTH", "KEY"}
+
+// IsSecretVar returns whether the deployment var name looks like it holds a secret value.
+// The name is split into words at the underscores so that GITHUB_TOKEN is a secret and AUTHOR is not.
+func IsSecretVar(name string) bool {
+	for _, word := range strings.Split(strings.ToUpper(name), "_") {
+		for _, k := range secretVarKeywords {
+			if word == k || word == k+"S" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseYAMLVars(content []byte) (map[string]string, error) {
+	// The values are checked first so that the error names the key of a value that isn't a scalar.
+	var items yamlGo.MapSlice
+	if err := yamlGo.Unmarshal(content, &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		switch item.Value.(type) {
+		case yamlGo.MapSlice, []interface{}:
+			return nil, fmt.Errorf("the value of %v must be a scalar", item.Key)
+		}
+	}
+
+	// Decoding into strings keeps the scalars as they are written,
+	// a version like 2.20 would be the number 2.2 otherwise.
+	vars := map[string]string{}
+	if err := yamlGo.Unmarshal(content, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseDotenvVars(content []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", n, err)
+				}
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
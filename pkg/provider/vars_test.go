@@ -0,0 +1,122 @@
+// Copyright 2020 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVarsFilesPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"common.yaml": "ZONE: us-east1-b\nPR_NUMBER: 1\nRELEASE: master\nCLUSTER_NAME: prombench\n",
+		"pr.env":      "# overrides for a single PR\nexport PR_NUMBER=2\nRELEASE=\"v2.20.0\"\nGITHUB_TOKEN='abc=123'\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dr := NewDeploymentResource()
+	dr.DefaultDeploymentVars = map[string]string{"NGINX_SERVICE_TYPE": "LoadBalancer", "CLUSTER_NAME": "default"}
+	dr.VarsFiles = []string{filepath.Join(dir, "common.yaml"), filepath.Join(dir, "pr.env")}
+	dr.FlagDeploymentVars = map[string]string{"RELEASE": "v2.21.0"}
+	if err := dr.LoadVarsFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, origin := MergeVarsSources(dr.VarsSources(map[string]string{"NGINX_SERVICE_TYPE": "NodePort"}))
+	expectedVars := map[string]string{
+		"NGINX_SERVICE_TYPE": "NodePort",
+		"CLUSTER_NAME":       "prombench",
+		"ZONE":               "us-east1-b",
+		"PR_NUMBER":          "2",
+		"RELEASE":            "v2.21.0",
+		"GITHUB_TOKEN":       "abc=123",
+	}
+	expectedOrigin := map[string]string{
+		"NGINX_SERVICE_TYPE": SourceProvider,
+		"CLUSTER_NAME":       dr.VarsFiles[0],
+		"ZONE":               dr.VarsFiles[0],
+		"PR_NUMBER":          dr.VarsFiles[1],
+		"RELEASE":            SourceFlag,
+		"GITHUB_TOKEN":       dr.VarsFiles[1],
+	}
+	if !reflect.DeepEqual(expectedVars, vars) {
+		t.Errorf("expect vars %v, got %v", expectedVars, vars)
+	}
+	if !reflect.DeepEqual(expectedOrigin, origin) {
+		t.Errorf("expect origin %v, got %v", expectedOrigin, origin)
+	}
+}
+
+func TestParseYAMLVarsKeepsScalars(t *testing.T) {
+	vars, err := parseYAMLVars([]byte("RELEASE: 2.20\nPR_NUMBER: 0123\nSAMPLES: 1e6\nENABLED: yes\nEMPTY:\nQUOTED: \"v2.20.0\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"RELEASE":   "2.20",
+		"PR_NUMBER": "0123",
+		"SAMPLES":   "1e6",
+		"ENABLED":   "yes",
+		"EMPTY":     "",
+		"QUOTED":    "v2.20.0",
+	}
+	if !reflect.DeepEqual(expected, vars) {
+		t.Errorf("expect vars %v, got %v", expected, vars)
+	}
+}
+
+func TestParseVarsErrors(t *testing.T) {
+	if _, err := parseDotenvVars([]byte("ZONE=us-east1-b\nPR_NUMBER\n")); err == nil {
+		t.Error("expected an error for a dotenv line without a value")
+	}
+	for _, content := range []string{"NODES:\n- a\n- b\n", "NODES:\n  a: b\n"} {
+		if _, err := parseYAMLVars([]byte(content)); err == nil || !strings.Contains(err.Error(), "NODES") {
+			t.Errorf("expected an error naming the key for a yaml value that is not a scalar, got %v", err)
+		}
+	}
+}
+
+func TestIsSecretVar(t *testing.T) {
+	for name, secret := range map[string]bool{
+		"GITHUB_TOKEN":           true,
+		"GRAFANA_ADMIN_PASSWORD": true,
+		"WH_SECRET":              true,
+		"OAUTH_TOKEN":            true,
+		"AUTH_FILE":              true,
+		"AWS_ACCESS_KEYS":        true,
+		"AUTHOR":                 false,
+		"MONKEY_NAME":            false,
+		"ZONE":                   false,
+		"CLUSTER_NAME":           false,
+		"hashStable":             false,
+	} {
+		if IsSecretVar(name) != secret {
+			t.Errorf("IsSecretVar(%q) expected %v", name, secret)
+		}
+	}
+}
@@ -19,17 +19,17 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
-	appsV1 "k8s.io/api/apps/v1"
-
 	"github.com/pkg/errors"
 	"github.com/prometheus/test-infra/pkg/provider/k8s"
 	"gopkg.in/alecthomas/kingpin.v2"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// fieldManager is the field manager of the replicas set by the scaler,
+// so that they aren't taken over when the deployment files are applied again.
+const fieldManager = "prombench-scaler"
+
 type scale struct {
 	k8sClient *k8s.K8s
 	min       int32
@@ -43,46 +43,25 @@ func newScaler() *scale {
 		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error creating k8s client inside the k8s cluster"))
 		os.Exit(2)
 	}
+	k.FieldManager = fieldManager
 	return &scale{
 		k8sClient: k,
 	}
 }
 
-func (s *scale) updateReplicas(replicas *int32) []k8s.Resource {
-	var k8sResource []k8s.Resource
-	for _, deployment := range s.k8sClient.GetResources() {
-		k8sObjects := make([]runtime.Object, 0)
-
-		for _, resource := range deployment.Objects {
-			if kind := strings.ToLower(resource.GetObjectKind().GroupVersionKind().Kind); kind == "deployment" {
-				req := resource.(*appsV1.Deployment)
-				req.Spec.Replicas = replicas
-				k8sObjects = append(k8sObjects, req.DeepCopyObject())
-			}
-		}
-		if len(k8sObjects) > 0 {
-			k8sResource = append(k8sResource, k8s.Resource{FileName: deployment.FileName, Objects: k8sObjects})
-		}
-	}
-	return k8sResource
-}
-
 func (s *scale) scale(*kingpin.ParseContext) error {
 	log.Printf("Starting Prombench-Scaler:\n\t max: %d\n\t min: %d\n\t interval: %s", s.max, s.min, s.interval)
 
-	maxResourceObjects := s.updateReplicas(&s.max)
-	minResourceObjects := s.updateReplicas(&s.min)
-
 	for {
 		log.Printf("Scaling Deployment to %d", s.max)
-		if err := s.k8sClient.ResourceApply(maxResourceObjects); err != nil {
+		if err := s.k8sClient.DeploymentsScale(s.k8sClient.GetResources(), s.max); err != nil {
 			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error scaling deployment"))
 		}
 
 		time.Sleep(s.interval)
 
 		log.Printf("Scaling Deployment to %d", s.min)
-		if err := s.k8sClient.ResourceApply(minResourceObjects); err != nil {
+		if err := s.k8sClient.DeploymentsScale(s.k8sClient.GetResources(), s.min); err != nil {
 			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error scaling deployment"))
 		}
 